@@ -0,0 +1,183 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Admonition styles for --admonitions
+const (
+	AdmonitionGitHub     = "github"
+	AdmonitionBlockquote = "blockquote"
+	AdmonitionNone       = "none"
+)
+
+// admonitionContainers are classes that mark an element as a callout box:
+// Sphinx/MkDocs/Docusaurus v2 (admonition), Docusaurus v3 (theme-admonition),
+// GitBook (hint), VitePress (custom-block) and generic callouts
+var admonitionContainers = map[string]bool{
+	"admonition":       true,
+	"theme-admonition": true,
+	"hint":             true,
+	"custom-block":     true,
+	"callout":          true,
+}
+
+// admonitionPrefixes are stripped from classes like admonition-warning or alert--danger
+var admonitionPrefixes = []string{"theme-admonition-", "admonition-", "alert--", "hint-", "callout-"}
+
+// admonitionKinds maps theme callout types to GitHub alert types
+var admonitionKinds = map[string]string{
+	"note":        "NOTE",
+	"info":        "NOTE",
+	"information": "NOTE",
+	"abstract":    "NOTE",
+	"summary":     "NOTE",
+	"tldr":        "NOTE",
+	"seealso":     "NOTE",
+	"todo":        "NOTE",
+	"quote":       "NOTE",
+	"example":     "NOTE",
+	"tip":         "TIP",
+	"hint":        "TIP",
+	"success":     "TIP",
+	"check":       "TIP",
+	"done":        "TIP",
+	"important":   "IMPORTANT",
+	"warning":     "WARNING",
+	"warn":        "WARNING",
+	"attention":   "WARNING",
+	"question":    "WARNING",
+	"help":        "WARNING",
+	"faq":         "WARNING",
+	"caution":     "CAUTION",
+	"danger":      "CAUTION",
+	"error":       "CAUTION",
+	"failure":     "CAUTION",
+	"fail":        "CAUTION",
+	"missing":     "CAUTION",
+	"bug":         "CAUTION",
+}
+
+// admonitionType returns the callout type of an element (e.g. "danger"), or
+// false if the element isn't a callout. Callouts without a known type are notes.
+func admonitionType(n *html.Node) (string, bool) {
+	classes := strings.Fields(strings.ToLower(getAttr(n, "class")))
+
+	container := false
+	for _, class := range classes {
+		if admonitionContainers[class] {
+			container = true
+		}
+	}
+
+	// GitBook marks the hint type in an attribute
+	if style := strings.ToLower(getAttr(n, "data-hint-style")); style != "" {
+		if _, ok := admonitionKinds[style]; ok {
+			return style, true
+		}
+		container = true
+	}
+
+	for _, class := range classes {
+		for _, prefix := range admonitionPrefixes {
+			if kind := strings.TrimPrefix(class, prefix); kind != class {
+				if _, ok := admonitionKinds[kind]; ok {
+					return kind, true
+				}
+			}
+		}
+	}
+
+	// Bare type classes count on callouts and MkDocs collapsible blocks
+	if container || n.DataAtom == atom.Details {
+		for _, class := range classes {
+			if _, ok := admonitionKinds[class]; ok && !admonitionContainers[class] {
+				return class, true
+			}
+		}
+	}
+
+	if container {
+		return "note", true
+	}
+	return "", false
+}
+
+// admonitionTitle finds the title element of a callout, if it has one
+func admonitionTitle(n *html.Node) *html.Node {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		if child.DataAtom == atom.Summary {
+			return child
+		}
+		class := getAttr(child, "class")
+		for _, c := range strings.Fields(class) {
+			if c == "admonition-title" || c == "admonition-heading" || c == "custom-block-title" ||
+				strings.HasPrefix(c, "admonitionHeading") {
+				return child
+			}
+		}
+		// Don't look inside nested callouts or the content itself
+		if _, nested := admonitionType(child); nested || strings.Contains(class, "content") {
+			continue
+		}
+		if title := admonitionTitle(child); title != nil {
+			return title
+		}
+	}
+	return nil
+}
+
+// renderAdmonition renders a callout as a GitHub alert or a labelled blockquote
+func (mc *markdownConverter) renderAdmonition(n *html.Node, kind string) ([]mdBlock, bool) {
+	alert := admonitionKinds[kind]
+	if alert == "" {
+		alert = "NOTE"
+	}
+
+	content := cloneNode(n)
+	title := ""
+	if titleNode := admonitionTitle(content); titleNode != nil {
+		title = cleanInline(mc.renderInlineChildren(titleNode))
+		titleNode.Parent.RemoveChild(titleNode)
+	}
+	// Titles that only repeat the type add nothing
+	if strings.EqualFold(title, kind) || strings.EqualFold(title, alert) {
+		title = ""
+	}
+
+	body := joinBlocks(mc.renderBlocks(content), false)
+	if body == "" && title == "" {
+		return nil, false
+	}
+
+	var text string
+	if mc.admonitions == AdmonitionBlockquote {
+		label := alert[:1] + strings.ToLower(alert[1:])
+		if title != "" {
+			label += ": " + title
+		}
+		text = "**" + label + "**"
+		if body != "" {
+			text += "\n\n" + body
+		}
+	} else {
+		text = "[!" + alert + "]"
+		if title != "" {
+			text += "\n**" + title + "**"
+			if body != "" {
+				text += "\n"
+			}
+		}
+		if body != "" {
+			text += "\n" + body
+		}
+	}
+
+	return []mdBlock{{text: prefixLines(text, "> ", ">")}}, true
+}
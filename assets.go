@@ -0,0 +1,383 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Asset types that can be downloaded with --assets
+const (
+	AssetImage = "image"
+	AssetSVG   = "svg"
+	AssetPDF   = "pdf"
+)
+
+const (
+	assetsDir = "assets"
+	// defaultAssetBudget caps the total size of downloaded assets
+	defaultAssetBudget = 200 * 1024 * 1024
+	// assetPlaceholder marks where an asset reference goes until the page is kept
+	assetPlaceholder = "CRAWLDOCSASSET"
+)
+
+// assetPlaceholderRe matches an asset placeholder and the spaces around it
+var assetPlaceholderRe = regexp.MustCompile(`( ?)` + assetPlaceholder + `(\d+)\.( ?)`)
+
+// assetExtensions maps asset content types to file extensions
+var assetExtensions = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/avif":      ".avif",
+	"image/bmp":       ".bmp",
+	"image/x-icon":    ".ico",
+	"image/svg+xml":   ".svg",
+	"application/pdf": ".pdf",
+}
+
+// assetStore downloads same-domain images and attachments into the assets
+// folder, deduplicating files by content hash
+type assetStore struct {
+	client    *http.Client
+	userAgent string
+	domain    string
+	outputDir string
+	types     map[string]bool
+	budget    int64
+	manifest  *CrawlManifest
+	verbose   bool
+
+	mu           sync.Mutex
+	failed       map[string]bool
+	reserved     int64 // bytes of downloads not yet in the manifest
+	budgetWarned bool
+}
+
+// assetRef is an image or attachment link found while converting a page
+type assetRef struct {
+	url     string
+	pageURL string
+	fileDir string
+	text    string // alt text or link text
+	image   bool
+	// fallback is the Markdown used when the asset isn't downloaded
+	fallback string
+}
+
+// assetRefs collects the assets a page references while it is converted,
+// so that they are only downloaded once the page is kept
+type assetRefs struct {
+	refs []assetRef
+}
+
+// add records a reference and returns the placeholder that stands in for it
+func (r *assetRefs) add(ref assetRef) string {
+	r.refs = append(r.refs, ref)
+	return assetPlaceholder + strconv.Itoa(len(r.refs)-1) + "."
+}
+
+// newAssetStore creates an asset store for the given asset types
+func newAssetStore(client *http.Client, domain, outputDir string, config CrawlConfig, manifest *CrawlManifest) (*assetStore, error) {
+	types := make(map[string]bool)
+	for _, t := range config.AssetTypes {
+		switch t {
+		case AssetImage, AssetSVG, AssetPDF:
+			types[t] = true
+		default:
+			return nil, fmt.Errorf("unknown asset type %q (expected %s, %s or %s)", t, AssetImage, AssetSVG, AssetPDF)
+		}
+	}
+	if len(types) == 0 {
+		types[AssetImage] = true
+	}
+
+	budget := config.AssetBudget
+	if budget <= 0 {
+		budget = defaultAssetBudget
+	}
+
+	if err := os.MkdirAll(filepath.Join(outputDir, assetsDir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create assets directory: %w", err)
+	}
+
+	return &assetStore{
+		client:    client,
+		userAgent: config.UserAgent,
+		domain:    domain,
+		outputDir: outputDir,
+		types:     types,
+		budget:    budget,
+		manifest:  manifest,
+		verbose:   config.Verbose,
+		failed:    make(map[string]bool),
+	}, nil
+}
+
+// IsAttachment reports whether a link points at a downloadable attachment
+func (s *assetStore) IsAttachment(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return s.types[AssetPDF] && strings.EqualFold(path.Ext(parsed.Path), ".pdf")
+}
+
+// Accepts reports whether an asset URL can be downloaded, returning it
+// without its fragment. It is false for assets on other domains and of a
+// type that isn't enabled.
+func (s *assetStore) Accepts(rawURL string) (string, bool) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host != s.domain {
+		return "", false
+	}
+	parsed.Fragment = ""
+
+	// Skip disabled types that can be told apart without downloading them
+	if ext := strings.ToLower(path.Ext(parsed.Path)); (ext == ".svg" && !s.types[AssetSVG]) || (ext == ".pdf" && !s.types[AssetPDF]) {
+		return "", false
+	}
+	return parsed.String(), true
+}
+
+// Resolve downloads the assets referenced by a page and replaces their
+// placeholders with links to the local copies. Images that can't be
+// downloaded are dropped; attachment links keep pointing at the site.
+func (s *assetStore) Resolve(text string, refs *assetRefs) string {
+	return replaceAssets(text, refs, func(ref assetRef) (string, bool) {
+		return s.Fetch(ref.url, ref.pageURL)
+	})
+}
+
+// withoutAssets replaces asset placeholders as if none of the assets were
+// downloaded, giving the text a page has before Resolve
+func withoutAssets(text string, refs *assetRefs) string {
+	return replaceAssets(text, refs, nil)
+}
+
+// replaceAssets replaces asset placeholders with links to the paths fetch
+// returns, or with the fallback for assets fetch is nil or fails for
+func replaceAssets(text string, refs *assetRefs, fetch func(assetRef) (string, bool)) string {
+	if refs == nil || len(refs.refs) == 0 {
+		return text
+	}
+	text = assetPlaceholderRe.ReplaceAllStringFunc(text, func(match string) string {
+		groups := assetPlaceholderRe.FindStringSubmatch(match)
+		index, err := strconv.Atoi(groups[2])
+		if err != nil || index >= len(refs.refs) {
+			return match
+		}
+		ref := refs.refs[index]
+
+		rendered := ref.fallback
+		if fetch != nil {
+			if local, ok := fetch(ref); ok {
+				local = escapeURL(relativeAssetPath(ref.fileDir, local))
+				if ref.image {
+					rendered = "![" + ref.text + "](" + local + ")"
+				} else {
+					rendered = "[" + ref.text + "](" + local + ")"
+				}
+			}
+		}
+		if rendered == "" {
+			// Keep a single space between the words around a dropped image
+			if groups[1] != "" && groups[3] != "" {
+				return " "
+			}
+			return ""
+		}
+		return groups[1] + rendered + groups[3]
+	})
+	return normalizeMarkdown(text)
+}
+
+// Fetch downloads an asset referenced from pageURL and returns its path
+// relative to the output directory. ok is false for assets that aren't
+// accepted or that don't fit in the byte budget.
+func (s *assetStore) Fetch(rawURL, pageURL string) (string, bool) {
+	assetURL, ok := s.Accepts(rawURL)
+	if !ok {
+		return "", false
+	}
+
+	if fileName, ok := s.manifest.AssetSource(assetURL, pageURL); ok {
+		return assetsDir + "/" + fileName, true
+	}
+
+	s.mu.Lock()
+	failed := s.failed[assetURL]
+	s.mu.Unlock()
+	if failed {
+		return "", false
+	}
+
+	info, err := s.download(assetURL, pageURL)
+	if err != nil {
+		s.mu.Lock()
+		s.failed[assetURL] = true
+		s.mu.Unlock()
+		if s.verbose {
+			logSkip("Asset %s: %v", assetURL, err)
+		}
+		return "", false
+	}
+
+	s.manifest.AddAsset(info)
+	s.release(info.Size)
+	return assetsDir + "/" + info.FileName, true
+}
+
+// remaining returns the bytes left in the budget, counting downloads that
+// haven't been added to the manifest yet
+func (s *assetStore) remaining() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.budget - s.manifest.AssetBytes() - s.reserved
+}
+
+// reserve sets n bytes of the budget aside for a download, reporting false
+// if they no longer fit. Parallel downloads check and reserve under one lock
+// so together they can't overshoot the budget.
+func (s *assetStore) reserve(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.manifest.AssetBytes()+s.reserved+n > s.budget {
+		return false
+	}
+	s.reserved += n
+	return true
+}
+
+// release returns reserved bytes once a download is in the manifest or has failed
+func (s *assetStore) release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reserved -= n
+}
+
+// download fetches an asset and writes it to the assets folder
+func (s *assetStore) download(assetURL, pageURL string) (*AssetInfo, error) {
+	remaining := s.remaining()
+	if remaining <= 0 {
+		s.warnBudget()
+		return nil, fmt.Errorf("asset budget exhausted")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, assetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !s.types[assetKind(contentType)] {
+		return nil, fmt.Errorf("content type %q not enabled", contentType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, remaining+1))
+	if err != nil {
+		return nil, err
+	}
+	size := int64(len(data))
+	if size > remaining || !s.reserve(size) {
+		s.warnBudget()
+		return nil, fmt.Errorf("exceeds remaining asset budget")
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	fileName := hash[:16] + assetExtension(contentType, assetURL)
+
+	filePath := filepath.Join(s.outputDir, assetsDir, fileName)
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		if err := os.WriteFile(filePath, data, 0644); err != nil {
+			s.release(size)
+			return nil, fmt.Errorf("failed to write %s: %w", fileName, err)
+		}
+	}
+
+	return &AssetInfo{
+		URL:          assetURL,
+		FileName:     fileName,
+		ContentType:  contentType,
+		Size:         size,
+		Hash:         hash,
+		SourcePages:  []string{pageURL},
+		DownloadedAt: time.Now(),
+	}, nil
+}
+
+// relativeAssetPath makes a path relative to the output directory relative
+// to fileDir, the directory of the output file
+func relativeAssetPath(fileDir, p string) string {
+	if fileDir == "" {
+		return p
+	}
+	rel, err := filepath.Rel(fileDir, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// warnBudget logs once that the asset budget has been reached
+func (s *assetStore) warnBudget() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.budgetWarned {
+		s.budgetWarned = true
+		logSkip("Asset budget of %.1f MB reached, skipping further assets", float64(s.budget)/1024/1024)
+	}
+}
+
+// assetKind returns the asset type for a content type
+func assetKind(contentType string) string {
+	switch {
+	case contentType == "image/svg+xml":
+		return AssetSVG
+	case strings.HasPrefix(contentType, "image/"):
+		return AssetImage
+	case contentType == "application/pdf":
+		return AssetPDF
+	}
+	return ""
+}
+
+// assetExtension picks a file extension from the content type, falling back to the URL
+func assetExtension(contentType, assetURL string) string {
+	if ext, ok := assetExtensions[contentType]; ok {
+		return ext
+	}
+	if parsed, err := url.Parse(assetURL); err == nil {
+		if ext := strings.ToLower(path.Ext(parsed.Path)); len(ext) > 1 && len(ext) <= 5 {
+			return ext
+		}
+	}
+	return ""
+}
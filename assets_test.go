@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestAssetDownloads(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\nfake image data")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/img/a.png", "/img/copy.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(png)
+		case "/img/big.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(make([]byte, 4096))
+		case "/img/d.svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			w.Write([]byte("<svg/>"))
+		case "/files/manual.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write([]byte("%PDF-1.4"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	base, _ := url.Parse(server.URL + "/guide/intro")
+	outputDir := t.TempDir()
+	manifest := NewManifest(server.URL, base.Host, outputDir, CrawlConfig{})
+	store, err := newAssetStore(server.Client(), base.Host, outputDir, CrawlConfig{
+		AssetTypes:  []string{AssetImage, AssetPDF},
+		AssetBudget: 1024,
+	}, manifest)
+	if err != nil {
+		t.Fatalf("newAssetStore() error: %v", err)
+	}
+
+	convert := func(fragment string) string {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + fragment + "</body></html>"))
+		if err != nil {
+			t.Fatalf("failed to parse HTML: %v", err)
+		}
+		converter := newMarkdownConverter(base)
+		converter.assets = store
+		return store.Resolve(converter.Convert(doc.Find("body")), converter.assetRefs)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "image",
+			input:    `<p><img src="/img/a.png" alt="Architecture"></p>`,
+			expected: "![Architecture](assets/" + CalculateContentHash(string(png))[:16] + ".png)",
+		},
+		{
+			name:     "same content shares a file",
+			input:    `<p><img src="../img/copy.png" alt="Copy"></p>`,
+			expected: "![Copy](assets/" + CalculateContentHash(string(png))[:16] + ".png)",
+		},
+		{
+			name:     "lazy-loaded image",
+			input:    `<p><img src="data:image/gif;base64,R0lGOD" data-src="/img/a.png" alt="Lazy"></p>`,
+			expected: "![Lazy](assets/" + CalculateContentHash(string(png))[:16] + ".png)",
+		},
+		{
+			name:     "pdf attachment",
+			input:    `<p><a href="/files/manual.pdf">Manual</a></p>`,
+			expected: "[Manual](assets/" + CalculateContentHash("%PDF-1.4")[:16] + ".pdf)",
+		},
+		{
+			name:     "svg not enabled",
+			input:    `<p>Diagram <img src="/img/d.svg" alt="SVG"></p>`,
+			expected: "Diagram",
+		},
+		{
+			name:     "other domain",
+			input:    `<p>Logo <img src="https://cdn.example.com/logo.png" alt="Logo"></p>`,
+			expected: "Logo",
+		},
+		{
+			name:     "over budget",
+			input:    `<p>Big <img src="/img/big.png" alt="Big"></p>`,
+			expected: "Big",
+		},
+		{
+			name:     "missing image",
+			input:    `<p>Gone <img src="/img/missing.png" alt="Gone"></p>`,
+			expected: "Gone",
+		},
+		{
+			name:     "missing image between words",
+			input:    `<p>Before <img src="/img/missing.png" alt="Gone"> after</p>`,
+			expected: "Before after",
+		},
+		{
+			name:     "missing attachment keeps the link",
+			input:    `<p><a href="/files/missing.pdf" title="Old">Old manual</a></p>`,
+			expected: "[Old manual](" + server.URL + "/files/missing.pdf \"Old\")",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := convert(tt.input); result != tt.expected {
+				t.Errorf("Convert() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+
+	files, _ := os.ReadDir(filepath.Join(outputDir, assetsDir))
+	if len(files) != 2 {
+		t.Errorf("expected 2 asset files, got %d", len(files))
+	}
+	if manifest.Statistics.TotalAssets != 3 {
+		t.Errorf("TotalAssets = %d, want 3", manifest.Statistics.TotalAssets)
+	}
+	if want := int64(len(png) + len("%PDF-1.4")); manifest.Statistics.AssetBytes != want {
+		t.Errorf("AssetBytes = %d, want %d", manifest.Statistics.AssetBytes, want)
+	}
+	if sources := manifest.Assets[server.URL+"/img/a.png"].SourcePages; len(sources) != 1 || sources[0] != base.String() {
+		t.Errorf("SourcePages = %v, want [%s]", sources, base)
+	}
+}
+
+func TestAssetsDownloadedOnResolve(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "image/png")
+		data := make([]byte, 100)
+		copy(data, r.URL.Path)
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	base, _ := url.Parse(server.URL + "/guide/intro")
+	manifest := NewManifest(server.URL, base.Host, t.TempDir(), CrawlConfig{})
+	store, err := newAssetStore(server.Client(), base.Host, t.TempDir(), CrawlConfig{AssetBudget: 250}, manifest)
+	if err != nil {
+		t.Fatalf("newAssetStore() error: %v", err)
+	}
+
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(`<html><body><p><img src="/a.png" alt="A"></p></body></html>`))
+	converter := newMarkdownConverter(base)
+	converter.assets = store
+	converter.Convert(doc.Find("body"))
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("converting a page made %d asset requests, want 0", n)
+	}
+
+	// Parallel downloads share the budget: only two 100-byte images fit in 250
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store.Fetch(fmt.Sprintf("%s/img/%d.png", server.URL, i), base.String())
+		}(i)
+	}
+	wg.Wait()
+	if manifest.AssetBytes() > 250 {
+		t.Errorf("AssetBytes = %d, over the budget of 250", manifest.AssetBytes())
+	}
+	if store.reserved != 0 {
+		t.Errorf("reserved = %d after all downloads finished, want 0", store.reserved)
+	}
+}
+
+func TestAssetsNotDownloadedForPagesOverTokenBudget(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(make([]byte, 100))
+	}))
+	defer server.Close()
+
+	base, _ := url.Parse(server.URL + "/guide/intro")
+	manifest := NewManifest(server.URL, base.Host, t.TempDir(), CrawlConfig{})
+	store, err := newAssetStore(server.Client(), base.Host, t.TempDir(), CrawlConfig{AssetBudget: 1000}, manifest)
+	if err != nil {
+		t.Fatalf("newAssetStore() error: %v", err)
+	}
+	c := &Crawler{
+		maxTokens:  8,
+		tokens:     countWords,
+		manifest:   manifest,
+		assets:     store,
+		config:     CrawlConfig{},
+		writeQueue: make(chan writeTask, 4),
+	}
+
+	convert := func(page string) (string, *assetRefs) {
+		doc, _ := goquery.NewDocumentFromReader(strings.NewReader(page))
+		converter := newMarkdownConverter(base)
+		converter.assets = store
+		converter.assetRefs = &assetRefs{}
+		return converter.Convert(doc.Find("body")), converter.assetRefs
+	}
+
+	body, refs := convert(`<p>One two three <img src="/a.png" alt="A"></p>`)
+	c.writePage(&PageInfo{URL: server.URL + "/a"}, body, refs, "a.md")
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("page within the budget made %d asset requests, want 1", n)
+	}
+
+	body, refs = convert(`<p>Four five six seven eight nine <img src="/b.png" alt="B"></p>`)
+	c.writePage(&PageInfo{URL: server.URL + "/b"}, body, refs, "b.md")
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("page over the token budget made %d more asset requests, want 0", n-1)
+	}
+	if page := manifest.Pages[server.URL+"/b"]; page == nil || page.Status != "skipped" {
+		t.Errorf("page over the budget recorded as %+v", page)
+	}
+	if len(c.writeQueue) != 1 || c.tokenCount > c.maxTokens {
+		t.Errorf("queued %d pages with %d tokens, want 1 page within %d", len(c.writeQueue), c.tokenCount, c.maxTokens)
+	}
+}
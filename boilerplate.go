@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultBoilerplateThreshold = 0.5
+	// minBoilerplatePages is how many pages must be seen before any block counts as boilerplate
+	minBoilerplatePages = 10
+	// minBoilerplateBlockLength is the shortest block that can count as boilerplate
+	minBoilerplateBlockLength = 16
+)
+
+// boilerplateDetector learns which text blocks recur across a large share of
+// pages (feedback widgets, version banners, footer blurbs) and strips them
+type boilerplateDetector struct {
+	mu        sync.RWMutex
+	threshold float64
+	pages     int
+	counts    map[string]int
+}
+
+// newBoilerplateDetector creates a detector that treats blocks found on at
+// least threshold (0-1) of all pages as boilerplate
+func newBoilerplateDetector(threshold float64) *boilerplateDetector {
+	if threshold <= 0 || threshold > 1 {
+		threshold = defaultBoilerplateThreshold
+	}
+	return &boilerplateDetector{
+		threshold: threshold,
+		counts:    make(map[string]int),
+	}
+}
+
+// Observe records the blocks of one page
+func (b *boilerplateDetector) Observe(body string) {
+	seen := make(map[string]bool)
+	for _, block := range splitMarkdownBlocks(body) {
+		if key := blockKey(block); key != "" {
+			seen[key] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pages++
+	for key := range seen {
+		b.counts[key]++
+	}
+}
+
+// Process records the blocks of a page and returns it with known boilerplate removed
+func (b *boilerplateDetector) Process(body string) string {
+	b.Observe(body)
+	return b.Strip(body)
+}
+
+// Strip removes every block currently considered boilerplate
+func (b *boilerplateDetector) Strip(body string) string {
+	stripped, _ := b.stripBlocks(body)
+	return stripped
+}
+
+// stripBlocks removes boilerplate blocks and reports how many were removed
+func (b *boilerplateDetector) stripBlocks(body string) (string, int) {
+	blocks := splitMarkdownBlocks(body)
+	var kept []string
+	for _, block := range blocks {
+		if !b.isBoilerplate(blockKey(block)) {
+			kept = append(kept, block)
+		}
+	}
+	return strings.Join(kept, "\n\n"), len(blocks) - len(kept)
+}
+
+// isBoilerplate reports whether a block key recurs on enough pages
+func (b *boilerplateDetector) isBoilerplate(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.pages < minBoilerplatePages {
+		return false
+	}
+	return float64(b.counts[key]) >= b.threshold*float64(b.pages)
+}
+
+// BlockCount returns the number of distinct blocks currently considered boilerplate
+func (b *boilerplateDetector) BlockCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.pages < minBoilerplatePages {
+		return 0
+	}
+	count := 0
+	for _, n := range b.counts {
+		if float64(n) >= b.threshold*float64(b.pages) {
+			count++
+		}
+	}
+	return count
+}
+
+// blockKey normalizes a block for comparison across pages. Blocks with code,
+// headings, anchors, rules and very short blocks never count: sections like
+// "## Parameters" recur on most reference pages but are content.
+func blockKey(block string) string {
+	switch {
+	case hasFence(block),
+		chunkHeadingRe.MatchString(block),
+		headingAnchorTagRe.MatchString(block),
+		strings.Trim(block, "-*_ ") == "",
+		len(block) < minBoilerplateBlockLength:
+		return ""
+	}
+	return strings.ToLower(strings.Join(strings.Fields(block), " "))
+}
+
+// hasFence reports whether a block holds fenced code, at the top level or
+// inside a blockquote
+func hasFence(block string) bool {
+	for _, line := range strings.Split(block, "\n") {
+		if nextFence(line, "") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// splitMarkdownBlocks splits Markdown on blank lines, keeping fenced code blocks whole
+func splitMarkdownBlocks(text string) []string {
+	var blocks []string
+	var current []string
+	fence := ""
+
+	flush := func() {
+		if block := strings.TrimSpace(strings.Join(current, "\n")); block != "" {
+			blocks = append(blocks, block)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if fence == "" && strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		current = append(current, line)
+		fence = nextFence(line, fence)
+	}
+	flush()
+
+	return blocks
+}
+
+// rewriteBoilerplate strips boilerplate from every saved page in the output
+// directory, rehashing every page and marking pages that become identical as
+// duplicates. It returns the number of files rewritten.
+func rewriteBoilerplate(manifest *CrawlManifest, outputDir string, detector *boilerplateDetector, countTokens tokenCounter) (int, error) {
+	rewritten := 0
+	seen := make(map[string]string) // content hash -> URL
+
+	for _, page := range manifest.CompletedPages() {
+		filePath := filepath.Join(outputDir, page.FileName)
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to read %s: %w", page.FileName, err)
+		}
+
+		body, hasFrontMatter := parsePageFile(string(data))
+		stripped, removed := detector.stripBlocks(body)
+		hash := pageContentHash(page.URL, page.Title, stripped)
+
+		if originalURL, ok := seen[hash]; ok {
+			if err := os.Remove(filePath); err != nil {
+				return rewritten, fmt.Errorf("failed to remove %s: %w", page.FileName, err)
+			}
+			manifest.MarkDuplicate(page.URL, originalURL, hash)
+			continue
+		}
+		seen[hash] = page.URL
+
+		// Pages saved during the crawl were hashed before their asset links
+		// were resolved; store the hash of the file body for every page so
+		// the manifest uses one definition throughout
+		if removed == 0 && hash == page.ContentHash {
+			continue
+		}
+
+		var content string
+		manifest.UpdatePage(page.URL, func(p *PageInfo) {
+			p.ContentHash = hash
+			p.WordCount = countWords(stripped)
+			p.TokenCount = countTokens(stripped)
+			content = renderPageFile(p, stripped, hasFrontMatter)
+			p.FileSize = int64(len(content))
+		})
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			return rewritten, fmt.Errorf("failed to write %s: %w", page.FileName, err)
+		}
+		rewritten++
+	}
+
+	manifest.SetBoilerplateBlocks(detector.BlockCount())
+	return rewritten, nil
+}
+
+// stripBoilerplateDir learns boilerplate from an existing crawl and removes it from every page
+func stripBoilerplateDir(outputDir string, threshold float64) error {
+	manifest, err := LoadManifest(outputDir)
+	if err != nil {
+		return err
+	}
+
+	countTokens, err := loadTokenCounter(manifest.Config.Tokenizer)
+	if err != nil {
+		return err
+	}
+
+	detector := newBoilerplateDetector(threshold)
+	for _, page := range manifest.CompletedPages() {
+		data, err := os.ReadFile(filepath.Join(outputDir, page.FileName))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", page.FileName, err)
+		}
+		body, _ := parsePageFile(string(data))
+		detector.Observe(body)
+	}
+
+	rewritten, err := rewriteBoilerplate(manifest, outputDir, detector, countTokens)
+	if err != nil {
+		return err
+	}
+
+	if err := manifest.Save(outputDir); err != nil {
+		return err
+	}
+
+	logSuccess("Removed %d boilerplate blocks from %d pages", detector.BlockCount(), rewritten)
+	return nil
+}
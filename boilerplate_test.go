@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBoilerplateDetector(t *testing.T) {
+	detector := newBoilerplateDetector(0.5)
+	footer := "Was this page helpful? Yes No"
+	code := "```sh\npip install example\n```"
+
+	var last string
+	for i := 0; i < minBoilerplatePages; i++ {
+		page := fmt.Sprintf("# Page %d\n\nUnique text for page %d.\n\n%s\n\n%s", i, i, code, footer)
+		last = detector.Process(page)
+	}
+
+	if strings.Contains(last, footer) {
+		t.Errorf("footer not removed: %q", last)
+	}
+	if !strings.Contains(last, code) {
+		t.Errorf("code block should never be treated as boilerplate: %q", last)
+	}
+	if !strings.Contains(last, "Unique text for page 9.") {
+		t.Errorf("unique content removed: %q", last)
+	}
+	if count := detector.BlockCount(); count != 1 {
+		t.Errorf("BlockCount() = %d, want 1", count)
+	}
+}
+
+func TestBlockKeySkipsStructure(t *testing.T) {
+	for _, block := range []string{
+		"## Parameters",
+		"### Returns {#returns}",
+		`<a id="returns"></a>`,
+		"---",
+		"* * *",
+		"Yes No",
+		"```sh\npip install example\n```",
+		"> [!TIP]\n> Install the client first:\n>\n> ```sh\n> pip install example\n> ```",
+	} {
+		if key := blockKey(block); key != "" {
+			t.Errorf("blockKey(%q) = %q, want no key", block, key)
+		}
+	}
+	if key := blockKey("Was this page  helpful?\nYes No"); key != "was this page helpful? yes no" {
+		t.Errorf("blockKey() = %q", key)
+	}
+}
+
+func TestSplitMarkdownBlocks(t *testing.T) {
+	text := "Intro\n\n```\nline one\n\nline two\n```\n\n- a\n- b"
+	blocks := splitMarkdownBlocks(text)
+	if len(blocks) != 3 {
+		t.Fatalf("got %d blocks, want 3: %q", len(blocks), blocks)
+	}
+	if blocks[1] != "```\nline one\n\nline two\n```" {
+		t.Errorf("fenced block split apart: %q", blocks[1])
+	}
+}
+
+func TestRewriteBoilerplateRehashesEveryPage(t *testing.T) {
+	outputDir := t.TempDir()
+	manifest := NewManifest("https://docs.example.com/", "docs.example.com", outputDir, CrawlConfig{})
+	page := &PageInfo{
+		URL:         "https://docs.example.com/guide",
+		Title:       "Guide",
+		ContentHash: pageContentHash("https://docs.example.com/guide", "Guide", "![Diagram](CRAWLDOCSASSET0.)"),
+		FileName:    "guide.md",
+		Status:      "completed",
+	}
+	manifest.AddPage(page)
+	body := "![Diagram](assets/diagram.png)"
+	if err := os.WriteFile(filepath.Join(outputDir, page.FileName), []byte(renderPageFile(page, body, false)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rewriteBoilerplate(manifest, outputDir, newBoilerplateDetector(0.5), countWords); err != nil {
+		t.Fatal(err)
+	}
+	if want := pageContentHash(page.URL, page.Title, body); manifest.Pages[page.URL].ContentHash != want {
+		t.Errorf("ContentHash = %s, want the hash of the saved body %s", manifest.Pages[page.URL].ContentHash, want)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/saintfish/chardet"
+	"golang.org/x/net/html/charset"
+)
+
+// metaCharsetPrescan is how far into a page a <meta> charset declaration is
+// looked for, as in the HTML encoding sniffing algorithm
+const metaCharsetPrescan = 1024
+
+// metaCharsetRe matches <meta charset="..."> and the charset parameter of
+// <meta http-equiv="Content-Type" content="text/html; charset=...">
+var metaCharsetRe = regexp.MustCompile(`(?i)<meta\s[^>]*?charset\s*=\s*["']?\s*([\w.:+-]+)`)
+
+// chardetLabels maps the names chardet reports to WHATWG encoding labels
+// where they differ
+var chardetLabels = map[string]string{
+	"GB-18030":     "gb18030",
+	"ISO-8859-8-I": "iso-8859-8-i",
+}
+
+// bodyCharset works out the character encoding of a response body. It
+// returns the canonical name of the encoding and whether the body still has
+// to be transcoded: colly already transcodes bodies whose Content-Type
+// declares a charset, so only a byte order mark, a <meta> declaration (for
+// HTML) or detection are left to go on. Bodies without a declaration that
+// are valid UTF-8 are taken as UTF-8.
+func bodyCharset(body []byte, contentType string, isHTML bool) (string, bool) {
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		if _, name := charset.Lookup(params["charset"]); name != "" {
+			return name, false
+		}
+		return strings.ToLower(params["charset"]), false
+	}
+
+	// A byte order mark is the only case DetermineEncoding is certain about
+	// without a Content-Type
+	if _, name, certain := charset.DetermineEncoding(body, ""); certain {
+		return name, name != "utf-8"
+	}
+
+	if isHTML {
+		prescan := body
+		if len(prescan) > metaCharsetPrescan {
+			prescan = prescan[:metaCharsetPrescan]
+		}
+		if match := metaCharsetRe.FindSubmatch(prescan); match != nil {
+			if _, name := charset.Lookup(string(match[1])); name != "" {
+				// A page can't really be UTF-16 if its markup was readable as ASCII
+				if strings.HasPrefix(name, "utf-16") {
+					name = "utf-8"
+				}
+				return name, name != "utf-8"
+			}
+		}
+	}
+
+	if utf8.Valid(body) {
+		return "utf-8", false
+	}
+	result, err := chardet.NewTextDetector().DetectBest(body)
+	if err == nil {
+		label := result.Charset
+		if mapped, ok := chardetLabels[label]; ok {
+			label = mapped
+		}
+		if _, name := charset.Lookup(label); name != "" {
+			return name, name != "utf-8"
+		}
+	}
+	// Browsers fall back to Windows-1252 for undeclared legacy pages
+	return "windows-1252", true
+}
+
+// toUTF8 transcodes a body from the named encoding to UTF-8
+func toUTF8(body []byte, name string) ([]byte, error) {
+	encoding, _ := charset.Lookup(name)
+	if encoding == nil {
+		return nil, fmt.Errorf("unknown charset %q", name)
+	}
+	decoded, err := encoding.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, err
+	}
+	// The byte order mark has served its purpose
+	return bytes.TrimPrefix(decoded, []byte("\xef\xbb\xbf")), nil
+}
+
+// decodeResponse transcodes a response body to UTF-8 in place, before it is
+// parsed, and remembers its charset for the page's manifest entry
+func (c *Crawler) decodeResponse(r *colly.Response, isHTML bool) {
+	if len(r.Body) == 0 {
+		return
+	}
+	currentURL := r.Request.URL.String()
+	name, transcode := bodyCharset(r.Body, r.Headers.Get("Content-Type"), isHTML)
+	if transcode {
+		decoded, err := toUTF8(r.Body, name)
+		if err != nil {
+			if c.verbose {
+				logWarn("Failed to transcode %s from %s: %v", currentURL, name, err)
+			}
+			return
+		}
+		r.Body = decoded
+		if c.verbose {
+			logDim("Transcoded %s from %s", currentURL, name)
+		}
+	}
+	c.charsets.Store(currentURL, name)
+}
+
+// responseCharset returns the charset decodeResponse found for a URL
+func (c *Crawler) responseCharset(currentURL string) string {
+	if name, ok := c.charsets.LoadAndDelete(currentURL); ok {
+		return name.(string)
+	}
+	return ""
+}
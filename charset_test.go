@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+	"golang.org/x/net/html/charset"
+)
+
+// encodeAs encodes UTF-8 text in the named charset
+func encodeAs(t *testing.T, text, name string) []byte {
+	t.Helper()
+	encoding, _ := charset.Lookup(name)
+	encoded, err := encoding.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatalf("failed to encode as %s: %v", name, err)
+	}
+	return encoded
+}
+
+func TestBodyCharset(t *testing.T) {
+	japanese := "<p>このドキュメントでは、インストール方法と設定について説明します。詳しくは次の章を参照してください。</p>"
+	russian := "<p>Это руководство описывает установку и настройку сервера. Подробности смотрите в следующих разделах документации.</p>"
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		isHTML      bool
+		expected    string
+		transcode   bool
+	}{
+		{
+			name:        "header charset is already handled by colly",
+			body:        []byte("<html><head><meta charset=\"shift_jis\"></head></html>"),
+			contentType: "text/html; charset=Shift_JIS",
+			isHTML:      true,
+			expected:    "shift_jis",
+		},
+		{
+			name:      "meta charset",
+			body:      append([]byte(`<html><head><meta charset="Shift_JIS"></head><body>`), encodeAs(t, japanese, "shift_jis")...),
+			isHTML:    true,
+			expected:  "shift_jis",
+			transcode: true,
+		},
+		{
+			name:      "meta http-equiv",
+			body:      append([]byte(`<meta http-equiv="Content-Type" content="text/html; charset=windows-1251">`), encodeAs(t, russian, "windows-1251")...),
+			isHTML:    true,
+			expected:  "windows-1251",
+			transcode: true,
+		},
+		{
+			name:      "latin1 label means windows-1252",
+			body:      []byte(`<meta charset=iso-8859-1><p>Caf` + "\xe9" + `</p>`),
+			isHTML:    true,
+			expected:  "windows-1252",
+			transcode: true,
+		},
+		{
+			name:     "meta utf-16 is read as utf-8",
+			body:     []byte(`<meta charset="utf-16"><p>Hello</p>`),
+			isHTML:   true,
+			expected: "utf-8",
+		},
+		{
+			name:      "byte order mark",
+			body:      append([]byte{0xff, 0xfe}, encodeAs(t, "<p>Hi</p>", "utf-16le")...),
+			isHTML:    true,
+			expected:  "utf-16le",
+			transcode: true,
+		},
+		{
+			name:     "undeclared utf-8",
+			body:     []byte(japanese),
+			isHTML:   true,
+			expected: "utf-8",
+		},
+		{
+			name:      "undeclared legacy encoding is detected",
+			body:      encodeAs(t, strings.Repeat(japanese, 3), "euc-jp"),
+			isHTML:    true,
+			expected:  "euc-jp",
+			transcode: true,
+		},
+		{
+			name:        "meta tags are ignored outside HTML",
+			body:        []byte(`<meta charset="shift_jis"> plain text`),
+			contentType: "text/plain",
+			expected:    "utf-8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, transcode := bodyCharset(tt.body, tt.contentType, tt.isHTML)
+			if name != tt.expected || transcode != tt.transcode {
+				t.Errorf("bodyCharset() = %q, %v, expected %q, %v", name, transcode, tt.expected, tt.transcode)
+			}
+		})
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	c := &Crawler{}
+	text := "<title>Установка</title><p>Подробности смотрите в документации.</p>"
+	pageURL, _ := url.Parse("https://docs.example.com/ru/install.html")
+	r := &colly.Response{
+		Body:    append([]byte(`<meta charset="koi8-r">`), encodeAs(t, text, "koi8-r")...),
+		Headers: &http.Header{"Content-Type": {"text/html"}},
+		Request: &colly.Request{URL: pageURL},
+	}
+
+	c.decodeResponse(r, true)
+	if got := string(r.Body); got != `<meta charset="koi8-r">`+text {
+		t.Errorf("body = %q", got)
+	}
+	if got := c.responseCharset(pageURL.String()); got != "koi8-r" {
+		t.Errorf("responseCharset() = %q, expected koi8-r", got)
+	}
+	// The charset is handed over once
+	if got := c.responseCharset(pageURL.String()); got != "" {
+		t.Errorf("second responseCharset() = %q", got)
+	}
+}
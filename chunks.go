@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Defaults for --chunks
+const (
+	defaultChunkTokens  = 512
+	defaultChunkOverlap = 64
+	chunksFileName      = "chunks.jsonl"
+)
+
+var (
+	// chunkHeadingRe matches a Markdown heading line
+	chunkHeadingRe = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*$`)
+	// headingAttrRe matches the {#id} written after headings by --heading-ids attr
+	headingAttrRe = regexp.MustCompile(`\s*\{#([^\s{}]+)\}$`)
+	// headingAnchorTagRe matches the <a id> written before headings by --heading-ids html
+	headingAnchorTagRe = regexp.MustCompile(`^<a id="([^"]*)"></a>$`)
+	// markdownEscapeRe matches a backslash escape written by escapeMarkdown
+	markdownEscapeRe = regexp.MustCompile("\\\\([\\\\`*_<\\[\\]])")
+)
+
+// Chunk is one record of the chunk export: a piece of a page, cut along its
+// headings, small enough to embed for retrieval
+type Chunk struct {
+	ID          string   `json:"id"`
+	URL         string   `json:"url"` // page URL with the section's anchor
+	Anchor      string   `json:"anchor,omitempty"`
+	Title       string   `json:"title"`
+	HeadingPath []string `json:"heading_path"`
+	Index       int      `json:"index"` // position of the chunk within its page
+	Text        string   `json:"text"`
+	ContentHash string   `json:"content_hash"`
+	TokenCount  int      `json:"token_count"`
+	FileName    string   `json:"file_name"`
+	Language    string   `json:"language,omitempty"`
+	Version     string   `json:"version,omitempty"`
+}
+
+// chunkSection is the content under one heading, up to the next heading
+type chunkSection struct {
+	path   []string
+	anchor string
+	blocks []string
+}
+
+// chunker splits pages into chunks of about targetTokens tokens, repeating
+// up to overlapTokens tokens of the previous chunk at the start of the next
+type chunker struct {
+	targetTokens  int
+	overlapTokens int
+	countTokens   tokenCounter
+}
+
+// chunkOverlapFor checks the --chunk-tokens and --chunk-overlap settings and
+// returns the overlap to use. The default overlap is sized for the default
+// chunk size; unless the overlap was set, smaller chunks keep the same share.
+func chunkOverlapFor(targetTokens, overlapTokens int, overlapSet bool) (int, error) {
+	if !overlapSet && overlapTokens >= targetTokens {
+		overlapTokens = targetTokens * defaultChunkOverlap / defaultChunkTokens
+	}
+	if targetTokens <= 0 || overlapTokens < 0 || overlapTokens >= targetTokens {
+		return 0, fmt.Errorf("--chunk-tokens must be positive and --chunk-overlap smaller than it")
+	}
+	return overlapTokens, nil
+}
+
+// newChunker creates a chunker that sizes chunks with countTokens
+func newChunker(targetTokens, overlapTokens int, countTokens tokenCounter) *chunker {
+	if targetTokens <= 0 {
+		targetTokens = defaultChunkTokens
+	}
+	if overlapTokens < 0 || overlapTokens >= targetTokens {
+		overlapTokens = 0
+	}
+	return &chunker{
+		targetTokens:  targetTokens,
+		overlapTokens: overlapTokens,
+		countTokens:   countTokens,
+	}
+}
+
+// Split cuts a saved page body into chunks along its heading hierarchy.
+// Sections never share a chunk; sections longer than the target are split
+// between blocks, and blocks longer than the target between lines.
+func (ch *chunker) Split(page *PageInfo, body string) []Chunk {
+	var chunks []Chunk
+	ordinals := make(map[string]int)
+
+	for _, section := range splitSections(body, page.Outline) {
+		for _, text := range ch.pack(section.blocks) {
+			chunkURL := page.URL
+			if section.anchor != "" {
+				chunkURL += "#" + section.anchor
+			}
+
+			// IDs depend on where a chunk sits, not on its text, so an edited
+			// section keeps its IDs
+			key := page.URL + "\n" + section.anchor + "\n" + strings.Join(section.path, "\n")
+			id := CalculateContentHash(key + "\n" + strconv.Itoa(ordinals[key]))[:16]
+			ordinals[key]++
+
+			chunks = append(chunks, Chunk{
+				ID:          id,
+				URL:         chunkURL,
+				Anchor:      section.anchor,
+				Title:       page.Title,
+				HeadingPath: section.path,
+				Index:       len(chunks),
+				Text:        text,
+				ContentHash: CalculateContentHash(text),
+				TokenCount:  ch.countTokens(text),
+				FileName:    page.FileName,
+				Language:    page.Language,
+				Version:     page.Version,
+			})
+		}
+	}
+	return chunks
+}
+
+// splitSections divides a Markdown body at its headings. Each section
+// starts with its heading (without the anchor markup) and carries the path
+// of headings above it and the nearest anchor. Anchors come from the
+// heading markup, or from the page outline when they were left out of the
+// file. Headings with no content of their own produce no section.
+func splitSections(body string, outline []HeadingInfo) []chunkSection {
+	type openHeading struct {
+		level  int
+		text   string
+		anchor string
+	}
+	var (
+		sections   []chunkSection
+		stack      []openHeading
+		current    = chunkSection{path: []string{}}
+		hasContent bool
+		pending    string // anchor from an <a id> block before the next heading
+		outlinePos int
+	)
+
+	flush := func() {
+		if hasContent {
+			sections = append(sections, current)
+		}
+		hasContent = false
+	}
+
+	for _, block := range splitMarkdownBlocks(body) {
+		if match := headingAnchorTagRe.FindStringSubmatch(block); match != nil {
+			pending = html.UnescapeString(match[1])
+			continue
+		}
+
+		match := chunkHeadingRe.FindStringSubmatch(block)
+		if match == nil || strings.Contains(block, "\n") {
+			if pending != "" {
+				current.blocks = append(current.blocks, `<a id="`+html.EscapeString(pending)+`"></a>`)
+				pending = ""
+			}
+			current.blocks = append(current.blocks, block)
+			hasContent = true
+			continue
+		}
+
+		flush()
+		level := len(match[1])
+		text := match[2]
+		anchor := pending
+		pending = ""
+		if attr := headingAttrRe.FindStringSubmatch(text); attr != nil {
+			anchor = attr[1]
+			text = strings.TrimSpace(text[:len(text)-len(attr[0])])
+		}
+		if anchor == "" {
+			for i := outlinePos; i < len(outline); i++ {
+				if outline[i].Level == level && outline[i].Text == plainHeadingText(text) {
+					anchor = outline[i].Anchor
+					outlinePos = i + 1
+					break
+				}
+			}
+		}
+
+		for len(stack) > 0 && stack[len(stack)-1].level >= level {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, openHeading{level: level, text: text, anchor: anchor})
+
+		current = chunkSection{path: make([]string, len(stack))}
+		for i, heading := range stack {
+			current.path[i] = plainHeadingText(heading.text)
+			if heading.anchor != "" {
+				current.anchor = heading.anchor
+			}
+		}
+		current.blocks = []string{match[1] + " " + text}
+	}
+	flush()
+
+	return sections
+}
+
+// plainHeadingText drops inline code and emphasis markers and backslash
+// escapes from heading text
+func plainHeadingText(text string) string {
+	text = strings.ReplaceAll(text, "**", "")
+	text = markdownEscapeRe.ReplaceAllString(text, "$1")
+	text = strings.ReplaceAll(text, "`", "")
+	return strings.TrimSpace(text)
+}
+
+// pack joins a section's blocks into chunks of at most targetTokens tokens
+// where possible, starting each chunk after the first with the trailing
+// blocks of the previous one that fit in overlapTokens
+func (ch *chunker) pack(blocks []string) []string {
+	var units []string
+	for _, block := range blocks {
+		if ch.countTokens(block) > ch.targetTokens {
+			units = append(units, ch.splitBlock(block)...)
+		} else {
+			units = append(units, block)
+		}
+	}
+
+	var chunks []string
+	var current []string
+	currentTokens := 0
+	fresh := 0 // units in current that aren't overlap from the previous chunk
+
+	for _, unit := range units {
+		tokens := ch.countTokens(unit)
+		if fresh > 0 && currentTokens+tokens > ch.targetTokens {
+			chunks = append(chunks, strings.Join(current, "\n\n"))
+
+			var overlap []string
+			overlapTokens := 0
+			for i := len(current) - 1; i >= 0; i-- {
+				t := ch.countTokens(current[i])
+				if overlapTokens+t > ch.overlapTokens || overlapTokens+t+tokens > ch.targetTokens {
+					break
+				}
+				overlap = append([]string{current[i]}, overlap...)
+				overlapTokens += t
+			}
+			current, currentTokens, fresh = overlap, overlapTokens, 0
+		}
+		current = append(current, unit)
+		currentTokens += tokens
+		fresh++
+	}
+	if fresh > 0 {
+		chunks = append(chunks, strings.Join(current, "\n\n"))
+	}
+	return chunks
+}
+
+// splitBlock splits an oversized block between lines. Pieces of a fenced
+// code block are fenced again so each stays valid Markdown.
+func (ch *chunker) splitBlock(block string) []string {
+	lines := strings.Split(block, "\n")
+	openFence, closeFence := "", ""
+	if len(lines) > 2 && strings.HasPrefix(strings.TrimSpace(lines[0]), "```") {
+		last := strings.TrimSpace(lines[len(lines)-1])
+		if strings.HasPrefix(last, "```") && strings.Trim(last, "`") == "" {
+			openFence, closeFence = lines[0], lines[len(lines)-1]
+			lines = lines[1 : len(lines)-1]
+		}
+	}
+	budget := ch.targetTokens - ch.countTokens(openFence+"\n"+closeFence)
+
+	var pieces []string
+	var current []string
+	currentTokens := 0
+	emit := func() {
+		piece := strings.Join(current, "\n")
+		if openFence != "" {
+			piece = openFence + "\n" + piece + "\n" + closeFence
+		}
+		pieces = append(pieces, piece)
+		current, currentTokens = nil, 0
+	}
+
+	for _, line := range lines {
+		tokens := ch.countTokens(line)
+		if len(current) > 0 && currentTokens+tokens > budget {
+			emit()
+		}
+		current = append(current, line)
+		currentTokens += tokens
+	}
+	if len(current) > 0 {
+		emit()
+	}
+	return pieces
+}
+
+// exportChunks splits every saved page in the output directory into chunks
+// and writes them to chunks.jsonl, one JSON record per line. It returns the
+// number of chunks written.
+func exportChunks(manifest *CrawlManifest, outputDir string, ch *chunker) (int, error) {
+	file, err := os.Create(filepath.Join(outputDir, chunksFileName))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", chunksFileName, err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+
+	total := 0
+	for _, page := range manifest.CompletedPages() {
+		data, err := os.ReadFile(filepath.Join(outputDir, page.FileName))
+		if err != nil {
+			return total, fmt.Errorf("failed to read %s: %w", page.FileName, err)
+		}
+
+		body, _ := parsePageFile(string(data))
+		for _, chunk := range ch.Split(page, body) {
+			if err := encoder.Encode(chunk); err != nil {
+				return total, fmt.Errorf("failed to write %s: %w", chunksFileName, err)
+			}
+			total++
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return total, fmt.Errorf("failed to write %s: %w", chunksFileName, err)
+	}
+	if err := file.Close(); err != nil {
+		return total, fmt.Errorf("failed to write %s: %w", chunksFileName, err)
+	}
+
+	manifest.SetChunks(total)
+	return total, nil
+}
+
+// exportChunksDir writes chunks.jsonl for an existing crawl output, counting
+// tokens with the named tokenizer or, if it is empty, the crawl's own
+func exportChunksDir(outputDir string, targetTokens, overlapTokens int, tokenizer string) error {
+	manifest, err := LoadManifest(outputDir)
+	if err != nil {
+		return err
+	}
+
+	if tokenizer == "" {
+		tokenizer = manifest.Config.Tokenizer
+	}
+	countTokens, err := loadTokenCounter(tokenizer)
+	if err != nil {
+		return err
+	}
+
+	total, err := exportChunks(manifest, outputDir, newChunker(targetTokens, overlapTokens, countTokens))
+	if err != nil {
+		return err
+	}
+
+	if err := manifest.Save(outputDir); err != nil {
+		return err
+	}
+
+	logSuccess("Wrote %d chunks from %d pages to %s", total, len(manifest.CompletedPages()), filepath.Join(outputDir, chunksFileName))
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitSections(t *testing.T) {
+	body := strings.Join([]string{
+		"Intro before any heading.",
+		"# Installation {#installation}",
+		"How to install.",
+		"## Requirements",
+		"## Linux {#linux}",
+		"```sh\n# not a heading\napt install example\n```",
+		`<a id="mac-os"></a>`,
+		"### `brew` on macOS",
+		"Use Homebrew.",
+		"## Windows",
+		"Use the installer.",
+		"## `max_size` \\<limit>",
+		"Bytes.",
+	}, "\n\n")
+	outline := []HeadingInfo{
+		{Level: 1, Text: "Installation", Anchor: "installation"},
+		{Level: 2, Text: "Requirements", Anchor: "requirements"},
+		{Level: 2, Text: "Linux", Anchor: "linux"},
+		{Level: 3, Text: "brew on macOS", Anchor: "mac-os"},
+		{Level: 2, Text: "Windows", Anchor: "windows"},
+		{Level: 2, Text: "max_size <limit>", Anchor: "max-size"},
+	}
+
+	sections := splitSections(body, outline)
+	type result struct {
+		path   []string
+		anchor string
+		text   string
+	}
+	var got []result
+	for _, section := range sections {
+		got = append(got, result{section.path, section.anchor, strings.Join(section.blocks, "\n\n")})
+	}
+	expected := []result{
+		{[]string{}, "", "Intro before any heading."},
+		{[]string{"Installation"}, "installation", "# Installation\n\nHow to install."},
+		{[]string{"Installation", "Linux"}, "linux", "## Linux\n\n```sh\n# not a heading\napt install example\n```"},
+		{[]string{"Installation", "Linux", "brew on macOS"}, "mac-os", "### `brew` on macOS\n\nUse Homebrew."},
+		{[]string{"Installation", "Windows"}, "windows", "## Windows\n\nUse the installer."},
+		{[]string{"Installation", "max_size <limit>"}, "max-size", "## `max_size` \\<limit>\n\nBytes."},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("splitSections() =\n%+v\nexpected\n%+v", got, expected)
+	}
+}
+
+func TestChunkerPack(t *testing.T) {
+	ch := newChunker(10, 3, countWords)
+
+	tests := []struct {
+		name     string
+		blocks   []string
+		expected []string
+	}{
+		{
+			name:     "fits in one chunk",
+			blocks:   []string{"## Setup", "one two three"},
+			expected: []string{"## Setup\n\none two three"},
+		},
+		{
+			name:   "overlap repeats trailing blocks",
+			blocks: []string{"## Setup", "a b c d e", "f g h", "i j k l m n"},
+			expected: []string{
+				"## Setup\n\na b c d e\n\nf g h",
+				"f g h\n\ni j k l m n",
+			},
+		},
+		{
+			name:   "oversized code block is split between lines and fenced again",
+			blocks: []string{"```go\na b c\nd e f\ng h i\nj k l\n```"},
+			expected: []string{
+				"```go\na b c\nd e f\n```",
+				"```go\ng h i\nj k l\n```",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ch.pack(tt.blocks); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("pack() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestChunkOverlapFor(t *testing.T) {
+	tests := []struct {
+		tokens, overlap int
+		overlapSet      bool
+		expected        int
+		valid           bool
+	}{
+		{defaultChunkTokens, defaultChunkOverlap, false, defaultChunkOverlap, true},
+		{64, defaultChunkOverlap, false, 8, true},
+		{32, defaultChunkOverlap, false, 4, true},
+		{64, 64, true, 0, false},
+		{256, 0, true, 0, true},
+		{0, defaultChunkOverlap, false, 0, false},
+		{512, -1, true, 0, false},
+	}
+	for _, tt := range tests {
+		overlap, err := chunkOverlapFor(tt.tokens, tt.overlap, tt.overlapSet)
+		if (err == nil) != tt.valid || overlap != tt.expected {
+			t.Errorf("chunkOverlapFor(%d, %d, %v) = %d, %v, want %d (valid %v)", tt.tokens, tt.overlap, tt.overlapSet, overlap, err, tt.expected, tt.valid)
+		}
+	}
+}
+
+func TestChunkIDs(t *testing.T) {
+	ch := newChunker(defaultChunkTokens, defaultChunkOverlap, estimateTokens)
+	page := &PageInfo{URL: "https://docs.example.com/install", Title: "Install", FileName: "install.md"}
+
+	first := ch.Split(page, "# Install {#install}\n\nOld text.\n\n## Example\n\nOne.\n\n## Example\n\nTwo.")
+	second := ch.Split(page, "# Install {#install}\n\nNew text.\n\n## Example\n\nOne.\n\n## Example\n\nTwo.")
+	if len(first) != 3 || len(second) != 3 {
+		t.Fatalf("got %d and %d chunks, expected 3", len(first), len(second))
+	}
+
+	seen := make(map[string]bool)
+	for i := range first {
+		if first[i].ID != second[i].ID {
+			t.Errorf("chunk %d: ID changed with the text: %s != %s", i, first[i].ID, second[i].ID)
+		}
+		if seen[first[i].ID] {
+			t.Errorf("chunk %d: duplicate ID %s", i, first[i].ID)
+		}
+		seen[first[i].ID] = true
+	}
+	if first[0].ContentHash == second[0].ContentHash {
+		t.Error("content hash didn't change with the text")
+	}
+	if first[1].URL != "https://docs.example.com/install#install" || first[1].Index != 1 {
+		t.Errorf("unexpected chunk: %+v", first[1])
+	}
+}
+
+func TestExportChunks(t *testing.T) {
+	dir := t.TempDir()
+	manifest := NewManifest("https://docs.example.com/", "docs.example.com", dir, CrawlConfig{})
+
+	page := &PageInfo{
+		URL:       "https://docs.example.com/guide",
+		Title:     "Guide",
+		FileName:  "guide.md",
+		Status:    "completed",
+		CrawledAt: time.Now(),
+		Language:  "en",
+	}
+	body := "# Guide {#guide}\n\nWelcome to the <b>guide</b>.\n\n## Usage {#usage}\n\nRun `example --help`."
+	if err := os.WriteFile(filepath.Join(dir, page.FileName), []byte(renderPageFile(page, body, true)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest.AddPage(page)
+
+	total, err := exportChunks(manifest, dir, newChunker(defaultChunkTokens, defaultChunkOverlap, estimateTokens))
+	if err != nil {
+		t.Fatalf("exportChunks() error: %v", err)
+	}
+	if total != 2 || manifest.Statistics.TotalChunks != 2 {
+		t.Errorf("total = %d, TotalChunks = %d, expected 2", total, manifest.Statistics.TotalChunks)
+	}
+
+	file, err := os.Open(filepath.Join(dir, chunksFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var chunks []Chunk
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if !strings.Contains(scanner.Text(), "<b>guide</b>") && len(chunks) == 0 {
+			t.Errorf("HTML escaped in record: %s", scanner.Text())
+		}
+		var chunk Chunk
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			t.Fatalf("invalid record %q: %v", scanner.Text(), err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("got %d records, expected 2", len(chunks))
+	}
+
+	usage := chunks[1]
+	if usage.URL != "https://docs.example.com/guide#usage" || usage.Title != "Guide" || usage.Language != "en" {
+		t.Errorf("unexpected record: %+v", usage)
+	}
+	if !reflect.DeepEqual(usage.HeadingPath, []string{"Guide", "Usage"}) {
+		t.Errorf("heading path = %v", usage.HeadingPath)
+	}
+	if usage.Text != "## Usage\n\nRun `example --help`." || usage.TokenCount != estimateTokens(usage.Text) {
+		t.Errorf("text = %q, tokens = %d", usage.Text, usage.TokenCount)
+	}
+	if usage.ContentHash != CalculateContentHash(usage.Text) {
+		t.Errorf("content hash = %s", usage.ContentHash)
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
-	"html"
 	"regexp"
 	"strings"
 	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 // Pre-compiled regex patterns for performance
@@ -42,7 +45,124 @@ type ContentValidation struct {
 	CleanedContent   string
 }
 
-// cleanHTMLOptimized is an optimized version with pre-compiled regex
+// nonContentSelector matches elements that never hold readable text. Hidden
+// tab panels are kept, since tabbed docs hide every tab but the first.
+const nonContentSelector = "script, style, noscript, template, svg, " +
+	"[hidden]:not([role='tabpanel']), [style*='display:none']:not([role='tabpanel']), " +
+	"[style*='display: none']:not([role='tabpanel'])"
+
+// removeNonContent drops scripts, styles, templates, inline SVG and hidden
+// elements, so their contents never reach the extracted text
+func removeNonContent(root *goquery.Selection) {
+	root.Find(nonContentSelector).Remove()
+}
+
+// blockText extracts the text of a cleaned DOM, breaking paragraphs at its
+// block elements. List items, table rows, definition terms and <br> start a
+// new line; other blocks start a new paragraph.
+func blockText(root *goquery.Selection) string {
+	var sb strings.Builder
+	newlines := 1 // trailing newlines written; the start counts as a line start
+
+	// breakLine ends the current line (1) or paragraph (2), unless the text
+	// already ends that way
+	breakLine := func(n int) {
+		for ; newlines < n; newlines++ {
+			sb.WriteByte('\n')
+		}
+	}
+
+	var walk func(n *html.Node, inList bool)
+	walk = func(n *html.Node, inList bool) {
+		switch n.Type {
+		case html.TextNode:
+			text := collapseWhitespace(n.Data)
+			if text == "" || (text == " " && newlines > 0) {
+				// Indentation between blocks
+				return
+			}
+			sb.WriteString(text)
+			newlines = 0
+			return
+		case html.ElementNode:
+			if isSkippedElement(n) {
+				return
+			}
+		}
+
+		if n.DataAtom == atom.Br {
+			// Consecutive <br> tags keep their blank lines
+			sb.WriteByte('\n')
+			newlines++
+			return
+		}
+		brk := textBreak(n, inList)
+		if brk == 0 && (n.DataAtom == atom.Td || n.DataAtom == atom.Th) && newlines == 0 {
+			sb.WriteByte(' ')
+		}
+		breakLine(brk)
+		inList = inList || n.DataAtom == atom.Li
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child, inList)
+		}
+		breakLine(brk)
+	}
+	for _, n := range root.Nodes {
+		walk(n, false)
+	}
+	return sb.String()
+}
+
+// textBreak returns how many newlines blockText puts around an element:
+// 2 for blocks, 1 for lines and 0 for inline elements and table cells
+func textBreak(n *html.Node, inList bool) int {
+	if n.Type != html.ElementNode {
+		return 0
+	}
+	if mathKind(n) == "display" {
+		return 2
+	}
+	switch n.DataAtom {
+	case atom.Td, atom.Th:
+		return 0
+	case atom.Li, atom.Tr, atom.Dt, atom.Dd:
+		return 1
+	case atom.Ul, atom.Ol, atom.Dl:
+		if inList {
+			return 1
+		}
+	}
+	if isBlockElement(n) {
+		return 2
+	}
+	return 0
+}
+
+// cleanText tidies text from blockText: whitespace is collapsed within each
+// line and runs of blank lines become a single paragraph break. Nothing is
+// dropped and no breaks are added, so the paragraphs follow the source.
+func cleanText(text string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(collapseWhitespace(line))
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cleanHTMLOptimized is the legacy cleaner for text that still contains
+// stylesheets: it strips CSS with regexes and drops sentences that look like
+// CSS or are very long. It also removes code, JSON and long paragraphs, so it
+// is only used with --legacy-clean.
 func cleanHTMLOptimized(text string) string {
 	initRegexPatterns()
 
@@ -72,7 +192,17 @@ func cleanHTMLOptimized(text string) string {
 	// Decode HTML entities
 	text = html.UnescapeString(text)
 
-	// Split into sentences and create paragraphs
+	// Skip sentences that look like CSS or technical artifacts
+	return sentenceParagraphs(text, func(sentence string) bool {
+		return strings.Contains(sentence, "{") || strings.Contains(sentence, "}") ||
+			strings.Contains(sentence, "src:") || strings.Contains(sentence, "font-family") ||
+			len(sentence) > 500
+	})
+}
+
+// sentenceParagraphs splits text into sentences and joins every three into a
+// paragraph, leaving out sentences for which skip returns true
+func sentenceParagraphs(text string, skip func(string) bool) string {
 	sentences := strings.Split(text, ". ")
 	var paragraphs []string
 	var currentParagraph []string
@@ -82,11 +212,7 @@ func cleanHTMLOptimized(text string) string {
 		if len(sentence) == 0 {
 			continue
 		}
-
-		// Skip sentences that look like CSS or technical artifacts
-		if strings.Contains(sentence, "{") || strings.Contains(sentence, "}") ||
-			strings.Contains(sentence, "src:") || strings.Contains(sentence, "font-family") ||
-			len(sentence) > 500 {
+		if skip != nil && skip(sentence) {
 			continue
 		}
 
@@ -94,22 +220,37 @@ func cleanHTMLOptimized(text string) string {
 
 		// Start new paragraph after 3-5 sentences
 		if len(currentParagraph) >= 3 {
-			paragraphs = append(paragraphs, strings.Join(currentParagraph, ". ")+".")
+			paragraphs = append(paragraphs, joinSentences(currentParagraph))
 			currentParagraph = nil
 		}
 	}
 
 	// Add remaining sentences
 	if len(currentParagraph) > 0 {
-		paragraphs = append(paragraphs, strings.Join(currentParagraph, ". ")+".")
+		paragraphs = append(paragraphs, joinSentences(currentParagraph))
 	}
 
 	return strings.Join(paragraphs, "\n\n")
 }
 
-// validateContent checks if the content is worth saving
-func validateContent(text string, url string) ContentValidation {
-	cleaned := cleanHTMLOptimized(text)
+// joinSentences rebuilds a paragraph from sentences split on ". "
+func joinSentences(sentences []string) string {
+	paragraph := strings.Join(sentences, ". ")
+	if !strings.HasSuffix(paragraph, ".") && !strings.HasSuffix(paragraph, "!") && !strings.HasSuffix(paragraph, "?") {
+		paragraph += "."
+	}
+	return paragraph
+}
+
+// validateContent checks if the content is worth saving. legacy selects the
+// regex-based cleaner for text that wasn't cleaned at the DOM level.
+func validateContent(text string, url string, legacy bool) ContentValidation {
+	var cleaned string
+	if legacy {
+		cleaned = cleanHTMLOptimized(text)
+	} else {
+		cleaned = cleanText(text)
+	}
 	contentLength := len(cleaned)
 
 	validation := ContentValidation{
@@ -122,19 +263,6 @@ func validateContent(text string, url string) ContentValidation {
 	return validation
 }
 
-// extractContentMetadata extracts useful metadata from HTML
-func extractContentMetadata(e interface{}) map[string]string {
-	metadata := make(map[string]string)
-
-	// This would need to be adapted based on the actual HTML parsing library
-	// For now, returning a basic structure
-	metadata["description"] = ""
-	metadata["keywords"] = ""
-	metadata["author"] = ""
-
-	return metadata
-}
-
 // cleanHTMLSimple is a simple version for backward compatibility
 func cleanHTMLSimple(text string) string {
 	return cleanHTMLOptimized(text)
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// codeChromeSelector matches line numbers, copy buttons and other widgets
+// that syntax highlighters render around code blocks
+const codeChromeSelector = "" +
+	// Line numbers: Pygments, Prism, highlight.js, Chroma, VitePress, Expressive Code
+	".linenos, .lineno, .linenodiv, .line-numbers-rows, .line-numbers-wrapper, " +
+	".hljs-ln-numbers, .ln, .lnt, .gutter, " +
+	// Copy buttons and toolbars
+	"button, clipboard-copy, .copybtn, .copy-button, .copy-code-button, .code-copy, " +
+	".btn-clipboard, .clipboard, [class*='copyButton'], .toolbar"
+
+// codeBlockPlaceholder marks where a protected code block goes in flattened text
+const codeBlockPlaceholder = "CRAWLDOCSCODEBLOCK"
+
+var (
+	langClassRe   = regexp.MustCompile(`^(?:language|lang|highlight)-(?:source-)?([A-Za-z0-9+#_.-]+)$`)
+	placeholderRe = regexp.MustCompile(`\s*` + codeBlockPlaceholder + `(\d+)\.?\s*`)
+)
+
+// ignoredLanguages are class suffixes that don't name a real language
+var ignoredLanguages = map[string]bool{
+	"default": true, "none": true, "plain": true, "plaintext": true, "nohighlight": true,
+}
+
+// prepareCodeBlocks removes highlighter chrome around code blocks and unwraps
+// line-number tables so that each block is a single <pre>
+func prepareCodeBlocks(root *goquery.Selection) {
+	// Pygments and Chroma tables: keep only the code cell
+	root.Find("table.highlighttable, table.lntable").Each(func(i int, table *goquery.Selection) {
+		code := table.Find("td.code, td.lntd:last-child").First()
+		if code.Length() > 0 {
+			table.ReplaceWithSelection(code.Children())
+		}
+	})
+
+	// highlight.js line-numbers plugin: one row per line
+	root.Find("table.hljs-ln").Each(func(i int, table *goquery.Selection) {
+		var lines []string
+		table.Find("td.hljs-ln-code").Each(func(j int, cell *goquery.Selection) {
+			lines = append(lines, cell.Text())
+		})
+		table.ReplaceWithHtml(html.EscapeString(strings.Join(lines, "\n")))
+	})
+
+	// Strip chrome within each code block and its immediate wrappers
+	root.Find("pre").Each(func(i int, pre *goquery.Selection) {
+		scope := pre
+		for level := 0; level < 2; level++ {
+			parent := scope.Parent()
+			if parent.Length() == 0 || parent.IsSelection(root) {
+				break
+			}
+			scope = parent
+		}
+		scope.Find(codeChromeSelector).Remove()
+	})
+}
+
+// detectCodeLanguage finds the language of a code block from the class and
+// data attributes used by Prism, highlight.js, Pygments and Shiki
+func detectCodeLanguage(pre *html.Node) string {
+	candidates := []*html.Node{}
+	for child := pre.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == atom.Code {
+			candidates = append(candidates, child)
+		}
+	}
+	candidates = append(candidates, pre)
+	for parent, level := pre.Parent, 0; parent != nil && level < 3; parent, level = parent.Parent, level+1 {
+		candidates = append(candidates, parent)
+	}
+
+	for _, n := range candidates {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		for _, key := range []string{"data-lang", "data-language"} {
+			if lang := normalizeLanguage(getAttr(n, key)); lang != "" {
+				return lang
+			}
+		}
+		for _, class := range strings.Fields(getAttr(n, "class")) {
+			if match := langClassRe.FindStringSubmatch(class); match != nil {
+				if lang := normalizeLanguage(match[1]); lang != "" {
+					return lang
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeLanguage lowercases a language name and drops non-languages
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "" || ignoredLanguages[lang] || strings.ContainsAny(lang, " \t\n`") {
+		return ""
+	}
+	return lang
+}
+
+// codeText returns the text of a code block, keeping line structure for
+// highlighters that render each line as its own element
+func codeText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		switch node.Type {
+		case html.TextNode:
+			sb.WriteString(node.Data)
+			return
+		case html.ElementNode:
+			if isSkippedElement(node) {
+				return
+			}
+			if node.DataAtom == atom.Br {
+				sb.WriteString("\n")
+				return
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if node.Type == html.ElementNode && node.DataAtom == atom.Div && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// fencedCode renders code as a fenced block tagged with its language
+func fencedCode(code, lang string) string {
+	code = strings.Trim(code, "\n")
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+// protectCodeBlocks replaces every code block under root with a placeholder
+// so that text cleaning can't mangle it, returning the fenced blocks in order
+func protectCodeBlocks(root *goquery.Selection) []string {
+	var blocks []string
+	root.Find("pre").Each(func(i int, pre *goquery.Selection) {
+		// Nested <pre> elements are handled by their outermost ancestor
+		if pre.ParentsFiltered("pre").Length() > 0 {
+			return
+		}
+		node := pre.Get(0)
+		block := fencedCode(codeText(node), detectCodeLanguage(node))
+		if block == "" {
+			pre.Remove()
+			return
+		}
+		pre.ReplaceWithHtml(fmt.Sprintf(" %s%d ", codeBlockPlaceholder, len(blocks)))
+		blocks = append(blocks, block)
+	})
+	return blocks
+}
+
+// restoreCodeBlocks puts protected code blocks back in place of their placeholders
+func restoreCodeBlocks(text string, blocks []string) string {
+	text = placeholderRe.ReplaceAllStringFunc(text, func(match string) string {
+		index, err := strconv.Atoi(placeholderRe.FindStringSubmatch(match)[1])
+		if err != nil || index >= len(blocks) {
+			return match
+		}
+		return "\n\n" + blocks[index] + "\n\n"
+	})
+	return normalizeMarkdown(text)
+}
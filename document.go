@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// Document types saved with --documents
+const (
+	DocumentPDF      = "pdf"
+	DocumentText     = "text"
+	DocumentMarkdown = "markdown"
+)
+
+// MetaDocument records the kind of document a non-HTML page was saved from
+const MetaDocument = "document"
+
+// documentKind returns the kind of document a non-HTML content type holds,
+// or "" if it isn't one --documents saves. Markdown served as text/plain is
+// recognized by its file extension.
+func documentKind(contentType, urlPath string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	switch mediaType {
+	case "application/pdf", "application/x-pdf":
+		return DocumentPDF
+	case "text/markdown", "text/x-markdown":
+		return DocumentMarkdown
+	case "text/plain":
+		switch strings.ToLower(path.Ext(urlPath)) {
+		case ".md", ".markdown", ".mdx":
+			return DocumentMarkdown
+		}
+		return DocumentText
+	}
+	return ""
+}
+
+// documentTitle picks a title for a document: the title it declares (a PDF's
+// document information title), the first Markdown heading, or the file name
+func documentTitle(kind, declared, text string, pageURL *url.URL) string {
+	if declared != "" {
+		return declared
+	}
+	switch kind {
+	case DocumentMarkdown:
+		for _, line := range strings.Split(text, "\n") {
+			if strings.HasPrefix(line, "# ") {
+				return strings.TrimSpace(strings.TrimPrefix(line, "# "))
+			}
+		}
+	}
+	if name := path.Base(pageURL.Path); name != "" && name != "/" && name != "." {
+		return name
+	}
+	return "Untitled"
+}
+
+// recoverDocument turns a panic while reading a malformed document into an
+// error, so the document fails on its own rather than taking the crawl down.
+// It must be deferred directly for recover to stop the panic.
+func recoverDocument(kind string, err *error) {
+	if recovered := recover(); recovered != nil {
+		*err = fmt.Errorf("failed to read %s: %v", kind, recovered)
+	}
+}
+
+// saveDocument saves a PDF, plain-text or Markdown response like a page:
+// PDFs are converted to text, text and Markdown are kept as they are
+func (c *Crawler) saveDocument(r *colly.Response, kind string) (err error) {
+	defer recoverDocument(kind, &err)
+
+	startTime := time.Now()
+	currentURL := r.Request.URL.String()
+	charsetName := c.responseCharset(currentURL)
+
+	if c.urlBloom.Test([]byte(currentURL)) && c.manifest.IsVisited(currentURL) {
+		return nil
+	}
+	c.urlBloom.Add([]byte(currentURL))
+	if c.limitReached() {
+		return nil
+	}
+
+	var text, declaredTitle string
+	if kind == DocumentPDF {
+		extracted, title, err := pdfText(r.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read PDF: %w", err)
+		}
+		text, declaredTitle = extracted, title
+	} else {
+		text = strings.ReplaceAll(string(r.Body), "\r\n", "\n")
+	}
+	if kind == DocumentMarkdown {
+		// Front matter in Markdown sources would clash with our own
+		text = frontMatterRe.ReplaceAllString(text, "")
+	}
+
+	validation := validateMarkdown(text)
+	if !validation.IsValid {
+		c.manifest.AddPage(&PageInfo{
+			URL:            currentURL,
+			Status:         "skipped",
+			ErrorMessage:   "minimal content",
+			ResponseCode:   r.StatusCode,
+			ContentType:    r.Headers.Get("Content-Type"),
+			CrawledAt:      time.Now(),
+			ProcessingTime: time.Since(startTime).Milliseconds(),
+		})
+
+		if c.verbose {
+			logSkip("Minimal content: %s", currentURL)
+		}
+		return nil
+	}
+
+	language := normalizeLanguageTag(r.Headers.Get("Content-Language"))
+	if language == "" {
+		language = detectLanguage(validation.CleanedContent)
+	}
+	if c.languages != nil && !c.languages.Allows(language) {
+		c.manifest.AddPage(&PageInfo{
+			URL:            currentURL,
+			Status:         "skipped",
+			ErrorMessage:   fmt.Sprintf("language %s", language),
+			ResponseCode:   r.StatusCode,
+			CrawledAt:      time.Now(),
+			ProcessingTime: time.Since(startTime).Milliseconds(),
+			Language:       language,
+		})
+
+		if c.verbose {
+			logSkip("Language %s: %s", language, currentURL)
+		}
+		return nil
+	}
+
+	title := documentTitle(kind, declaredTitle, validation.CleanedContent, r.Request.URL)
+	contentHash, duplicate := c.checkDuplicate(currentURL, title, validation.CleanedContent, startTime)
+	if duplicate {
+		return nil
+	}
+
+	var fileDir string
+	if c.config.LanguageDirs && language != "" {
+		fileDir = language
+	}
+	atomic.AddInt32(&c.pageCount, 1)
+	filename, filePath, err := c.outputFile(currentURL, fileDir)
+	if err != nil {
+		return err
+	}
+
+	var parentURL string
+	if parent, ok := c.parents.Load(currentURL); ok {
+		parentURL = parent.(string)
+	}
+	version, _ := pathVersion(r.Request.URL.Path, nil)
+
+	pageInfo := &PageInfo{
+		URL:            currentURL,
+		Title:          title,
+		ContentHash:    contentHash,
+		FileName:       filename,
+		CrawledAt:      time.Now(),
+		ResponseCode:   r.StatusCode,
+		ContentType:    r.Headers.Get("Content-Type"),
+		Charset:        charsetName,
+		ProcessingTime: time.Since(startTime).Milliseconds(),
+		Status:         "completed",
+		ContentSource:  SourceDocument,
+		Depth:          r.Request.Depth - 1,
+		ParentURL:      parentURL,
+		Language:       language,
+		Version:        version,
+		Metadata:       map[string]string{MetaDocument: kind},
+		WordCount:      countWords(validation.CleanedContent),
+	}
+	c.writePage(pageInfo, validation.CleanedContent, nil, filePath)
+	return nil
+}
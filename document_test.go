@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDocumentKind(t *testing.T) {
+	tests := []struct {
+		contentType string
+		path        string
+		expected    string
+	}{
+		{"application/pdf", "/specs/v1.pdf", DocumentPDF},
+		{"text/plain; charset=utf-8", "/robots.txt", DocumentText},
+		{"text/plain", "/docs/README.md", DocumentMarkdown},
+		{"text/markdown; charset=UTF-8", "/docs/intro", DocumentMarkdown},
+		{"image/png", "/logo.png", ""},
+		{"application/json", "/api.json", ""},
+	}
+	for _, tt := range tests {
+		if kind := documentKind(tt.contentType, tt.path); kind != tt.expected {
+			t.Errorf("documentKind(%q, %q) = %q, want %q", tt.contentType, tt.path, kind, tt.expected)
+		}
+	}
+}
+
+func TestDocumentTitle(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/docs/CHANGELOG.md")
+	if title := documentTitle(DocumentMarkdown, "", "Intro text\n\n# Changelog\n\n## 1.0", pageURL); title != "Changelog" {
+		t.Errorf("documentTitle() = %q, want %q", title, "Changelog")
+	}
+	if title := documentTitle(DocumentText, "", "Plain text", pageURL); title != "CHANGELOG.md" {
+		t.Errorf("documentTitle() = %q, want %q", title, "CHANGELOG.md")
+	}
+	if title := documentTitle(DocumentPDF, "Client Guide", "Text", pageURL); title != "Client Guide" {
+		t.Errorf("documentTitle() = %q, want %q", title, "Client Guide")
+	}
+}
+
+func TestRecoverDocument(t *testing.T) {
+	// A parser bug that indexes past the end of a malformed object
+	read := func(data []byte, offset int) (err error) {
+		defer recoverDocument(DocumentPDF, &err)
+		_ = data[offset]
+		return nil
+	}
+
+	err := read([]byte("%PDF-1.4"), 64)
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to read pdf: runtime error: index out of range") {
+		t.Errorf("error = %v, want the recovered panic", err)
+	}
+	if err := read([]byte("%PDF-1.4"), 0); err != nil {
+		t.Errorf("error = %v without a panic", err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Metadata keys set from the detected docs framework
+const (
+	MetaFramework = "framework"
+	MetaPrevURL   = "prev_url"
+	MetaNextURL   = "next_url"
+)
+
+// docsFramework describes where a documentation generator puts the parts of a page
+type docsFramework struct {
+	Name      string
+	Generator *regexp.Regexp // matched against <meta name="generator">
+	Signature string         // elements only this framework renders
+	Content   string         // content root
+	Sidebar   string         // navigation sidebar
+	Pager     string         // previous/next page links
+	Version   string         // version switcher
+	Exclude   string         // other chrome inside the content root
+}
+
+// docsFrameworks are checked in order, so themes come before the generator they build on
+var docsFrameworks = []*docsFramework{
+	{
+		Name:      "readthedocs",
+		Signature: ".wy-nav-content, .rst-versions, readthedocs-flyout",
+		Content:   "[itemprop='articleBody'], .rst-content [role='main']",
+		Sidebar:   ".wy-nav-side",
+		Pager:     ".rst-footer-buttons",
+		Version:   ".rst-versions, readthedocs-flyout",
+		Exclude:   "a.headerlink, .wy-breadcrumbs-aside",
+	},
+	{
+		Name:      "sphinx",
+		Generator: regexp.MustCompile(`(?i)^sphinx`),
+		Signature: ".sphinxsidebar, [data-content_root]",
+		Content:   "div[role='main'] .body, div.body, article[role='main'], #furo-main-content",
+		Sidebar:   ".sphinxsidebar, .sidebar-drawer, .bd-sidebar-primary",
+		Pager:     "div.related, .prev-next-area, .related-pages",
+		Version:   ".version-switcher__container, #version_switcher",
+		Exclude:   "a.headerlink",
+	},
+	{
+		Name:      "mkdocs-material",
+		Generator: regexp.MustCompile(`(?i)mkdocs-material`),
+		Signature: ".md-container, [data-md-component='content']",
+		Content:   "article.md-content__inner, .md-content",
+		Sidebar:   ".md-sidebar",
+		Pager:     ".md-footer__inner",
+		Version:   ".md-version",
+		Exclude:   "a.headerlink, .md-content__button, .md-source-file, .md-feedback",
+	},
+	{
+		Name:      "mkdocs",
+		Generator: regexp.MustCompile(`(?i)^mkdocs`),
+		Signature: ".bs-sidebar, .wy-nav-content[data-mkdocs]",
+		Content:   "div[role='main']",
+		Sidebar:   ".bs-sidebar, .wy-nav-side",
+		Pager:     ".rst-footer-buttons, .navbar a[rel='prev'], .navbar a[rel='next']",
+		Exclude:   "a.headerlink",
+	},
+	{
+		Name:      "docusaurus",
+		Generator: regexp.MustCompile(`(?i)^docusaurus`),
+		Signature: "#__docusaurus, .theme-doc-markdown",
+		Content:   ".theme-doc-markdown, article .markdown",
+		Sidebar:   ".theme-doc-sidebar-container, nav.menu",
+		Pager:     "nav.pagination-nav",
+		Version:   ".theme-doc-version-badge, .theme-doc-version-banner",
+		Exclude:   ".theme-doc-toc-mobile, .theme-doc-footer, .hash-link, .theme-doc-breadcrumbs",
+	},
+	{
+		Name:      "vitepress",
+		Generator: regexp.MustCompile(`(?i)^vitepress`),
+		Signature: "#VPContent, .VPDoc",
+		Content:   ".vp-doc",
+		Sidebar:   ".VPSidebar",
+		Pager:     ".prev-next",
+		Version:   ".VPNavBarMenuGroup",
+		Exclude:   ".header-anchor, .edit-link",
+	},
+	{
+		Name:      "gitbook",
+		Generator: regexp.MustCompile(`(?i)^gitbook`),
+		Signature: ".book-summary, .book-body",
+		Content:   ".markdown-section, .book-body .page-inner, main",
+		Sidebar:   ".book-summary",
+		Pager:     ".navigation, a.navigation",
+		Exclude:   ".page-footer",
+	},
+	{
+		Name:      "docsy",
+		Signature: ".td-main, .td-content",
+		Content:   ".td-content",
+		Sidebar:   ".td-sidebar, .td-sidebar-toc",
+		Version:   ".td-navbar .dropdown",
+		Exclude:   ".td-page-meta, .td-toc, .feedback--title, .feedback--answer, .td-heading-self-link",
+	},
+	{
+		Name:      "javadoc",
+		Generator: regexp.MustCompile(`(?i)^javadoc`),
+		Signature: ".contentContainer, .flex-content, .top-nav#navbar-top",
+		Content:   ".flex-content main, main[role='main'], .contentContainer",
+		Sidebar:   ".topNav, .subNav, .top-nav, .sub-nav, .flex-box > header",
+		Exclude:   ".skipNav, .skip-nav, .navPadding",
+	},
+	{
+		Name:      "rustdoc",
+		Generator: regexp.MustCompile(`(?i)^rustdoc`),
+		Signature: "body.rustdoc, #main-content.content",
+		Content:   "#main-content",
+		Sidebar:   "nav.sidebar, .sidebar",
+		Version:   ".sidebar-crate .version",
+		Exclude:   ".out-of-band, a.src, a.anchor, rustdoc-toolbar, #copy-path, .main-heading .sub-heading",
+	},
+}
+
+// detectFramework identifies the documentation generator of a page from its
+// generator meta tag or DOM signature. It returns nil for unknown sites.
+func detectFramework(doc *goquery.Selection) *docsFramework {
+	generator := strings.TrimSpace(doc.Find("meta[name='generator']").AttrOr("content", ""))
+
+	for _, framework := range docsFrameworks {
+		if framework.Generator != nil && generator != "" && framework.Generator.MatchString(generator) {
+			return framework
+		}
+		if doc.Find(framework.Signature).Length() > 0 {
+			return framework
+		}
+	}
+	return nil
+}
+
+// Chrome returns the selector for the framework's navigation and page furniture
+func (f *docsFramework) Chrome() string {
+	return joinSelectors(f.Sidebar, f.Pager, f.Version, f.Exclude)
+}
+
+// PagerLinks returns the previous and next page URLs from the framework's pager
+func (f *docsFramework) PagerLinks(doc *goquery.Selection, resolve func(string) string) (prev, next string) {
+	if f.Pager == "" {
+		return "", ""
+	}
+
+	pager := doc.Find(f.Pager)
+	pager.Find("a[href]").AddSelection(pager.Filter("a[href]")).Each(func(i int, s *goquery.Selection) {
+		href := strings.TrimSpace(s.AttrOr("href", ""))
+		if href == "" || strings.HasPrefix(href, "#") {
+			return
+		}
+		hints := strings.ToLower(strings.Join([]string{
+			s.AttrOr("rel", ""), s.AttrOr("class", ""), s.AttrOr("aria-label", ""), s.AttrOr("title", ""),
+		}, " "))
+		accessKey := strings.ToLower(s.AttrOr("accesskey", ""))
+
+		switch {
+		case prev == "" && (strings.Contains(hints, "prev") || accessKey == "p"):
+			prev = resolve(href)
+		case next == "" && (strings.Contains(hints, "next") || accessKey == "n"):
+			next = resolve(href)
+		}
+	})
+	return prev, next
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestDetectFramework(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "Sphinx generator",
+			html:     `<head><meta name="generator" content="Sphinx 7.2.6"></head><body><div class="body">x</div></body>`,
+			expected: "sphinx",
+		},
+		{
+			name:     "Sphinx with Read the Docs theme",
+			html:     `<head><meta name="generator" content="Sphinx 7.2.6"></head><body><section class="wy-nav-content-wrap"><div class="wy-nav-content"></div></section></body>`,
+			expected: "readthedocs",
+		},
+		{
+			name:     "MkDocs Material generator",
+			html:     `<head><meta name="generator" content="mkdocs-1.5.3, mkdocs-material-9.4.6"></head><body></body>`,
+			expected: "mkdocs-material",
+		},
+		{
+			name:     "Plain MkDocs",
+			html:     `<head><meta name="generator" content="mkdocs-1.5.3"></head><body></body>`,
+			expected: "mkdocs",
+		},
+		{
+			name:     "Docusaurus without generator",
+			html:     `<body><div id="__docusaurus"><div class="theme-doc-markdown markdown">x</div></div></body>`,
+			expected: "docusaurus",
+		},
+		{
+			name:     "VitePress",
+			html:     `<head><meta name="generator" content="VitePress v1.0.0"></head><body></body>`,
+			expected: "vitepress",
+		},
+		{
+			name:     "Hugo Docsy",
+			html:     `<head><meta name="generator" content="Hugo 0.120.0"></head><body><main class="td-main"><div class="td-content">x</div></main></body>`,
+			expected: "docsy",
+		},
+		{
+			name:     "Javadoc",
+			html:     `<head><meta name="generator" content="javadoc/ClassWriterImpl"></head><body></body>`,
+			expected: "javadoc",
+		},
+		{
+			name:     "rustdoc",
+			html:     `<head><meta name="generator" content="rustdoc"></head><body class="rustdoc mod"></body>`,
+			expected: "rustdoc",
+		},
+		{
+			name:     "Unknown site",
+			html:     `<head><meta name="generator" content="WordPress 6.4"></head><body><main>x</main></body>`,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html>" + tt.html + "</html>"))
+			if err != nil {
+				t.Fatalf("failed to parse HTML: %v", err)
+			}
+			name := ""
+			if framework := detectFramework(doc.Selection); framework != nil {
+				name = framework.Name
+			}
+			if name != tt.expected {
+				t.Errorf("detectFramework() = %q, want %q", name, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFrameworkExtraction(t *testing.T) {
+	page := `<html><head><meta name="generator" content="Docusaurus v3.1.0"></head><body>
+		<nav class="menu"><a href="/docs/intro">Intro</a></nav>
+		<article><div class="theme-doc-markdown markdown"><h1>Install<a class="hash-link" href="#install">#</a></h1><p>Run it.</p></div>
+		<nav class="pagination-nav"><a class="pagination-nav__link pagination-nav__link--prev" href="/docs/intro">Intro</a>
+		<a class="pagination-nav__link pagination-nav__link--next" href="/docs/usage">Usage</a></nav></article>
+		</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("failed to parse HTML: %v", err)
+	}
+
+	framework := detectFramework(doc.Selection)
+	if framework == nil || framework.Name != "docusaurus" {
+		t.Fatalf("expected docusaurus, got %v", framework)
+	}
+
+	root := doc.Find(framework.Content).First().Clone()
+	root.Find(framework.Chrome()).Remove()
+	if result := newMarkdownConverter(nil).Convert(root); result != "# Install\n\nRun it." {
+		t.Errorf("content = %q", result)
+	}
+
+	resolve := func(href string) string { return "https://docs.example.com" + href }
+	prev, next := framework.PagerLinks(doc.Selection, resolve)
+	if prev != "https://docs.example.com/docs/intro" || next != "https://docs.example.com/docs/usage" {
+		t.Errorf("PagerLinks() = %q, %q", prev, next)
+	}
+}
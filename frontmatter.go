@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// breadcrumbSelector matches breadcrumb trails in common docs themes
+const breadcrumbSelector = "nav[aria-label*='readcrumb'], .breadcrumb, .breadcrumbs, " +
+	"[itemtype*='BreadcrumbList'], .theme-doc-breadcrumbs, .wy-breadcrumbs, .md-path"
+
+// renderPageFile builds the saved Markdown file for a page, with either YAML
+// front matter or the plain title/source header
+func renderPageFile(page *PageInfo, body string, withFrontMatter bool) string {
+	if withFrontMatter {
+		return frontMatter(page) + "\n# " + page.Title + "\n\n" + body
+	}
+	return fmt.Sprintf("# %s\n\nSource: %s\n\n---\n\n%s", page.Title, page.URL, body)
+}
+
+// parsePageFile extracts the Markdown body from a saved page and reports
+// whether the file uses front matter
+func parsePageFile(content string) (body string, hasFrontMatter bool) {
+	if strings.HasPrefix(content, "---\n") {
+		if end := strings.Index(content[4:], "\n---\n"); end >= 0 {
+			body = strings.TrimLeft(content[4+end+5:], "\n")
+			// Skip the title heading written after the front matter
+			if strings.HasPrefix(body, "# ") {
+				if idx := strings.Index(body, "\n\n"); idx >= 0 {
+					body = body[idx+2:]
+				} else {
+					body = ""
+				}
+			}
+			return body, true
+		}
+	}
+
+	const separator = "\n---\n\n"
+	if idx := strings.Index(content, separator); idx >= 0 {
+		return content[idx+len(separator):], false
+	}
+	return content, false
+}
+
+// frontMatter renders page metadata as a YAML front matter block
+func frontMatter(page *PageInfo) string {
+	var sb strings.Builder
+	sb.WriteString("---\n")
+
+	writeYAMLString(&sb, "url", page.URL)
+	writeYAMLString(&sb, "title", page.Title)
+	writeYAMLString(&sb, "description", page.Description)
+	writeYAMLString(&sb, "canonical_url", page.CanonicalURL)
+	writeYAMLString(&sb, "language", page.Language)
+	writeYAMLString(&sb, "version", page.Version)
+	writeYAMLString(&sb, "author", page.Metadata[MetaAuthor])
+	if keywords := splitList(page.Metadata[MetaKeywords]); len(keywords) > 0 {
+		writeYAMLList(&sb, "keywords", keywords)
+	}
+	writeYAMLString(&sb, "published_time", page.Metadata[MetaPublishedTime])
+	writeYAMLString(&sb, "modified_time", page.Metadata[MetaModifiedTime])
+	fmt.Fprintf(&sb, "crawled_at: %s\n", page.CrawledAt.UTC().Format(time.RFC3339))
+	writeYAMLString(&sb, "content_hash", page.ContentHash)
+	fmt.Fprintf(&sb, "depth: %d\n", page.Depth)
+	writeYAMLString(&sb, "parent_url", page.ParentURL)
+	if len(page.Breadcrumbs) > 0 {
+		writeYAMLList(&sb, "breadcrumbs", page.Breadcrumbs)
+	}
+	fmt.Fprintf(&sb, "word_count: %d\n", page.WordCount)
+	if page.TokenCount > 0 {
+		fmt.Fprintf(&sb, "token_count: %d\n", page.TokenCount)
+	}
+
+	sb.WriteString("---\n")
+	return sb.String()
+}
+
+// writeYAMLString writes a quoted string field, omitting empty values
+func writeYAMLString(sb *strings.Builder, key, value string) {
+	if value == "" {
+		return
+	}
+	sb.WriteString(key + ": " + yamlQuote(value) + "\n")
+}
+
+// writeYAMLList writes a list of quoted strings
+func writeYAMLList(sb *strings.Builder, key string, values []string) {
+	sb.WriteString(key + ":\n")
+	for _, value := range values {
+		sb.WriteString("  - " + yamlQuote(value) + "\n")
+	}
+}
+
+// yamlQuote renders a string as a double-quoted YAML scalar
+func yamlQuote(value string) string {
+	// Every escape sequence strconv emits is also valid in YAML
+	return strconv.Quote(value)
+}
+
+// extractBreadcrumbs returns the breadcrumb trail of a page, if it has one
+func extractBreadcrumbs(doc *goquery.Selection) []string {
+	trail := doc.Find(breadcrumbSelector).First()
+	if trail.Length() == 0 {
+		return nil
+	}
+
+	items := trail.Find("li")
+	if items.Length() == 0 {
+		items = trail.Find("a")
+	}
+
+	var crumbs []string
+	items.Each(func(i int, s *goquery.Selection) {
+		// Nested list items are picked up on their own
+		if s.Find("li").Length() > 0 {
+			return
+		}
+		text := strings.Join(strings.Fields(s.Text()), " ")
+		if text == "" || (len(crumbs) > 0 && crumbs[len(crumbs)-1] == text) {
+			return
+		}
+		crumbs = append(crumbs, text)
+	})
+	return crumbs
+}
+
+// countWords counts whitespace-separated words in text
+func countWords(text string) int {
+	return len(strings.Fields(text))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPageFileRoundTrip(t *testing.T) {
+	page := &PageInfo{
+		URL:         "https://docs.example.com/guide/",
+		Title:       `The "Guide"`,
+		Description: "How to: get started",
+		Language:    "en",
+		CrawledAt:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		ContentHash: "abc123",
+		Depth:       2,
+		Breadcrumbs: []string{"Docs", "Guide"},
+		WordCount:   4,
+	}
+	body := "## Intro\n\nSome body text.\n\n---\n\nAfter a rule."
+
+	withFrontMatter := renderPageFile(page, body, true)
+	expectedHeader := "---\n" +
+		"url: \"https://docs.example.com/guide/\"\n" +
+		"title: \"The \\\"Guide\\\"\"\n" +
+		"description: \"How to: get started\"\n" +
+		"language: \"en\"\n" +
+		"crawled_at: 2024-05-01T12:00:00Z\n" +
+		"content_hash: \"abc123\"\n" +
+		"depth: 2\n" +
+		"breadcrumbs:\n  - \"Docs\"\n  - \"Guide\"\n" +
+		"word_count: 4\n" +
+		"---\n"
+	if !strings.HasPrefix(withFrontMatter, expectedHeader) {
+		t.Errorf("front matter = %q, want prefix %q", withFrontMatter, expectedHeader)
+	}
+
+	for _, frontMatter := range []bool{true, false} {
+		parsed, hasFrontMatter := parsePageFile(renderPageFile(page, body, frontMatter))
+		if parsed != body || hasFrontMatter != frontMatter {
+			t.Errorf("parsePageFile(frontMatter=%v) = %q, %v; want %q", frontMatter, parsed, hasFrontMatter, body)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Heading ID styles for --heading-ids
+const (
+	HeadingIDsAttr = "attr" // ## Title {#id}
+	HeadingIDsHTML = "html" // <a id="id"></a> before the heading
+	HeadingIDsNone = "none"
+)
+
+// headingLevel returns 1-6 for heading elements and 0 for anything else
+func headingLevel(n *html.Node) int {
+	if n.Type != html.ElementNode {
+		return 0
+	}
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return int(n.Data[1] - '0')
+	}
+	return 0
+}
+
+// headingAnchor finds the fragment that links to a heading: its own id, a
+// named anchor inside or just before it, or the id of the section it opens
+// (Sphinx puts the id on the enclosing <section>)
+func headingAnchor(n *html.Node) string {
+	if id := strings.TrimSpace(getAttr(n, "id")); id != "" {
+		return id
+	}
+
+	for _, a := range findAll(n, atom.A) {
+		if id := anchorName(a); id != "" && strings.TrimSpace(nodeText(a)) == "" {
+			return id
+		}
+	}
+
+	prev := n.PrevSibling
+	for prev != nil && prev.Type == html.TextNode && strings.TrimSpace(prev.Data) == "" {
+		prev = prev.PrevSibling
+	}
+	if prev != nil && prev.Type == html.ElementNode && prev.DataAtom == atom.A && strings.TrimSpace(nodeText(prev)) == "" {
+		if id := anchorName(prev); id != "" {
+			return id
+		}
+	}
+
+	if parent := n.Parent; parent != nil && (parent.DataAtom == atom.Section || parent.DataAtom == atom.Div) && firstElementChild(parent) == n {
+		return strings.TrimSpace(getAttr(parent, "id"))
+	}
+	return ""
+}
+
+// anchorName returns the id or name of an anchor element
+func anchorName(a *html.Node) string {
+	if id := strings.TrimSpace(getAttr(a, "id")); id != "" {
+		return id
+	}
+	return strings.TrimSpace(getAttr(a, "name"))
+}
+
+// firstElementChild returns the first child element of n
+func firstElementChild(n *html.Node) *html.Node {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode {
+			return child
+		}
+	}
+	return nil
+}
+
+// renderHeading renders a heading, keeping its anchor in the configured style
+func (mc *markdownConverter) renderHeading(n *html.Node) ([]mdBlock, bool) {
+	anchor := headingAnchor(n)
+	if mc.headingIDs != HeadingIDsNone {
+		mc.permalink = anchor
+	}
+	text := cleanInline(mc.renderInlineChildren(n))
+	mc.permalink = ""
+	if text == "" {
+		return nil, false
+	}
+	heading := strings.Repeat("#", headingLevel(n)) + " " + text
+
+	switch {
+	case anchor == "" || mc.headingIDs == HeadingIDsNone:
+		return []mdBlock{{text: heading}}, true
+	case mc.headingIDs == HeadingIDsHTML:
+		return []mdBlock{{text: `<a id="` + html.EscapeString(anchor) + `"></a>`}, {text: heading}}, true
+	case strings.ContainsAny(anchor, " \t{}"):
+		// Not expressible as a {#id} attribute
+		return []mdBlock{{text: heading}}, true
+	default:
+		return []mdBlock{{text: heading + " {#" + anchor + "}"}}, true
+	}
+}
+
+// isWordRune reports whether r is a letter or digit
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// extractOutline lists the headings of a content root with their anchors
+func extractOutline(root *goquery.Selection) []HeadingInfo {
+	var outline []HeadingInfo
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode || isSkippedElement(child) {
+				continue
+			}
+			if level := headingLevel(child); level > 0 {
+				// Sphinx permalinks render as a trailing pilcrow
+				text := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(collapseWhitespace(nodeText(child))), "¶"))
+				if text != "" {
+					outline = append(outline, HeadingInfo{Level: level, Text: text, Anchor: headingAnchor(child)})
+				}
+				continue
+			}
+			walk(child)
+		}
+	}
+	for _, n := range root.Nodes {
+		walk(n)
+	}
+	return outline
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// minDetectWords is the fewest words detectLanguage will guess a language from
+const minDetectWords = 20
+
+// stopwords are frequent function words used to tell Latin-script languages apart
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "for", "it", "with", "are", "this", "be", "you", "on", "can", "not", "or", "by", "if"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "ein", "eine", "den", "zu", "von", "sie", "auf", "für", "dem", "sich", "werden", "wird", "auch"},
+	"fr": {"le", "la", "les", "et", "est", "des", "une", "pour", "dans", "que", "qui", "pas", "sur", "du", "vous", "avec", "il", "sont", "ce", "au"},
+	"es": {"el", "la", "los", "las", "y", "es", "que", "de", "en", "un", "una", "para", "por", "con", "se", "del", "no", "como", "su", "al"},
+	"it": {"il", "di", "che", "è", "la", "per", "un", "una", "non", "sono", "con", "del", "della", "gli", "le", "si", "come", "questo", "anche", "nel"},
+	"pt": {"o", "os", "que", "de", "do", "da", "em", "um", "uma", "para", "com", "não", "é", "se", "dos", "das", "por", "mais", "como", "ao"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "voor", "met", "zijn", "te", "wordt", "je", "ook", "aan", "bij", "kan", "dit"},
+}
+
+// stopwordLanguages maps each stopword to the languages it appears in
+var stopwordLanguages = func() map[string][]string {
+	index := make(map[string][]string)
+	for lang, words := range stopwords {
+		for _, word := range words {
+			index[word] = append(index[word], lang)
+		}
+	}
+	return index
+}()
+
+// hreflangAlternate is a translation of a page listed in <link rel="alternate" hreflang>
+type hreflangAlternate struct {
+	Language string
+	URL      string
+}
+
+// hreflangAlternates lists the translations a page links to, with their URLs
+// resolved. The x-default entry isn't a language tag and is left out.
+func hreflangAlternates(doc *goquery.Selection, resolve func(string) string) []hreflangAlternate {
+	var alternates []hreflangAlternate
+	doc.Find("link[rel='alternate'][hreflang][href]").Each(func(i int, s *goquery.Selection) {
+		lang := normalizeLanguageTag(s.AttrOr("hreflang", ""))
+		href := resolve(strings.TrimSpace(s.AttrOr("href", "")))
+		if lang == "" || href == "" {
+			return
+		}
+		alternates = append(alternates, hreflangAlternate{Language: lang, URL: href})
+	})
+	return alternates
+}
+
+// pageLanguage works out the language of a page from <html lang>, the
+// Content-Language header or meta tag, the hreflang alternate pointing back at
+// the page, and finally the text of its content
+func pageLanguage(doc *goquery.Selection, contentLanguage, pageURL string, alternates []hreflangAlternate, content *goquery.Selection) string {
+	if lang := normalizeLanguageTag(doc.AttrOr("lang", "")); lang != "" {
+		return lang
+	}
+	if lang := normalizeLanguageTag(doc.Find("html").AttrOr("lang", "")); lang != "" {
+		return lang
+	}
+	if lang := normalizeLanguageTag(contentLanguage); lang != "" {
+		return lang
+	}
+	var metaLanguage string
+	doc.Find("meta[http-equiv][content]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if strings.EqualFold(s.AttrOr("http-equiv", ""), "content-language") {
+			metaLanguage = normalizeLanguageTag(s.AttrOr("content", ""))
+			return false
+		}
+		return true
+	})
+	if metaLanguage != "" {
+		return metaLanguage
+	}
+	for _, alternate := range alternates {
+		if sameURL(alternate.URL, pageURL) {
+			return alternate.Language
+		}
+	}
+	if content != nil {
+		return detectLanguage(content.Text())
+	}
+	return ""
+}
+
+// normalizeLanguageTag turns a language tag into its usual BCP 47 casing
+// (en, pt-BR, zh-Hant). Only the first tag of a list is kept.
+func normalizeLanguageTag(tag string) string {
+	tag = strings.TrimSpace(strings.Split(tag, ",")[0])
+	tag = strings.TrimSpace(strings.Split(tag, ";")[0])
+	if tag == "" || tag == "*" {
+		return ""
+	}
+	parts := strings.FieldsFunc(tag, func(r rune) bool { return r == '-' || r == '_' })
+	for i, part := range parts {
+		for _, r := range part {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				return ""
+			}
+		}
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(part)
+		case len(part) == 2:
+			parts[i] = strings.ToUpper(part)
+		case len(part) == 4:
+			parts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		default:
+			parts[i] = strings.ToLower(part)
+		}
+	}
+	if len(parts) == 0 || len(parts[0]) < 2 || len(parts[0]) > 3 {
+		return ""
+	}
+	return strings.Join(parts, "-")
+}
+
+// languageCodes are the ISO 639-1 language codes, less the few that double as
+// common path segments in docs (io, ml, os, ts)
+var languageCodes = func() map[string]bool {
+	codes := make(map[string]bool)
+	for _, code := range strings.Fields(`
+		aa ab ae af ak am an ar as av ay az ba be bg bh bi bm bn bo br bs ca ce ch co cr cs cu cv cy
+		da de dv dz ee el en eo es et eu fa ff fi fj fo fr fy ga gd gl gn gu gv ha he hi ho hr ht hu
+		hy hz ia id ie ig ii ik is it iu ja jv ka kg ki kj kk kl km kn ko kr ks ku kv kw ky la lb lg
+		li ln lo lt lu lv mg mh mi mk mn mr ms mt my na nb nd ne ng nl nn no nr nv ny oc oj om or pa
+		pi pl ps pt qu rm rn ro ru rw sa sc sd se sg si sk sl sm sn so sq sr ss st su sv sw ta te tg
+		th ti tk tl tn to tr tt tw ty ug uk ur uz ve vi vo wa wo xh yi yo za zh zu`) {
+		codes[code] = true
+	}
+	return codes
+}()
+
+// languageSegmentRe matches the shape of a language path segment such as en,
+// pt-br, zh_Hant or es-419
+var languageSegmentRe = regexp.MustCompile(`(?i)^([a-z]{2})([-_]([a-z]{2}|[a-z]{4}|\d{3})){0,2}$`)
+
+// isLanguageSegment reports whether a URL path segment names a language,
+// which takes a known language code and not just a two-letter segment, since
+// /go/, /js/ and /ui/ aren't languages
+func isLanguageSegment(segment string) bool {
+	match := languageSegmentRe.FindStringSubmatch(segment)
+	return match != nil && languageCodes[strings.ToLower(match[1])]
+}
+
+// primaryLanguage returns the language subtag of a tag (pt for pt-BR)
+func primaryLanguage(tag string) string {
+	primary, _, _ := strings.Cut(tag, "-")
+	return primary
+}
+
+// sameURL compares two URLs, ignoring a trailing slash and the fragment
+func sameURL(a, b string) bool {
+	trim := func(s string) string {
+		s, _, _ = strings.Cut(s, "#")
+		return strings.TrimSuffix(s, "/")
+	}
+	return trim(a) == trim(b)
+}
+
+// detectLanguage guesses the language of a text. Non-Latin scripts are
+// recognized by their characters; Latin-script text is scored against lists
+// of common words. It returns "" when the text is too short or ambiguous.
+func detectLanguage(text string) string {
+	scripts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			scripts["ja"]++
+		case unicode.Is(unicode.Hangul, r):
+			scripts["ko"]++
+		case unicode.Is(unicode.Han, r):
+			scripts["zh"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts["ru"]++
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				scripts["uk"]++
+			}
+		case unicode.Is(unicode.Arabic, r):
+			scripts["ar"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		case unicode.Is(unicode.Devanagari, r):
+			scripts["hi"]++
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// Japanese mixes kana with kanji, so any amount of kana decides it
+	if scripts["ja"] > 0 && scripts["ja"]+scripts["zh"] > letters/3 {
+		return "ja"
+	}
+	for _, lang := range []string{"zh", "ko", "ru", "ar", "he", "el", "th", "hi"} {
+		if scripts[lang] > letters/3 {
+			if lang == "ru" && scripts["uk"] > 0 {
+				return "uk"
+			}
+			return lang
+		}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) < minDetectWords {
+		return ""
+	}
+	scores := make(map[string]int)
+	for _, word := range words {
+		for _, lang := range stopwordLanguages[word] {
+			scores[lang]++
+		}
+	}
+
+	languages := make([]string, 0, len(scores))
+	for lang := range scores {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	best, bestScore, secondScore := "", 0, 0
+	for _, lang := range languages {
+		switch score := scores[lang]; {
+		case score > bestScore:
+			best, bestScore, secondScore = lang, score, bestScore
+		case score > secondScore:
+			secondScore = score
+		}
+	}
+	// Require a clear winner among a meaningful share of the words
+	if bestScore*20 < len(words) || bestScore == secondScore {
+		return ""
+	}
+	return best
+}
+
+// languageFilter implements --lang: it keeps pages in the listed languages
+// and learns from hreflang alternates which URLs are translations, so they
+// can be skipped before they are queued
+type languageFilter struct {
+	languages []string
+
+	mu       sync.Mutex
+	urls     map[string]string // URL -> language of hreflang alternates
+	prefixes map[string]string // first path segment -> language, e.g. "fr" or "zh-cn"
+}
+
+// newLanguageFilter creates a filter for a comma-separated list of language tags
+func newLanguageFilter(languages []string) *languageFilter {
+	filter := &languageFilter{
+		urls:     make(map[string]string),
+		prefixes: make(map[string]string),
+	}
+	for _, lang := range languages {
+		if lang = normalizeLanguageTag(lang); lang != "" {
+			filter.languages = append(filter.languages, lang)
+		}
+	}
+	return filter
+}
+
+// Allows reports whether a page in lang should be kept. "en" matches en-US
+// and en-GB; pages whose language is unknown are always kept.
+func (f *languageFilter) Allows(lang string) bool {
+	if lang == "" || len(f.languages) == 0 {
+		return true
+	}
+	for _, want := range f.languages {
+		if strings.EqualFold(lang, want) || (!strings.Contains(want, "-") && strings.EqualFold(primaryLanguage(lang), want)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Learn records the languages of a page's hreflang alternates, along with
+// the path prefixes the site uses for them
+func (f *languageFilter) Learn(alternates []hreflangAlternate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, alternate := range alternates {
+		f.urls[strings.TrimSuffix(alternate.URL, "/")] = alternate.Language
+
+		parsed, err := url.Parse(alternate.URL)
+		if err != nil {
+			continue
+		}
+		segment, _, _ := strings.Cut(strings.TrimPrefix(parsed.Path, "/"), "/")
+		if tag := normalizeLanguageTag(segment); tag != "" && (strings.EqualFold(tag, alternate.Language) || tag == primaryLanguage(alternate.Language)) {
+			f.prefixes[strings.ToLower(segment)] = alternate.Language
+		}
+	}
+}
+
+// URLLanguage returns the language of a URL known from hreflang alternates
+// or a learned path prefix, or "" if it isn't known
+func (f *languageFilter) URLLanguage(rawURL string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if lang, ok := f.urls[strings.TrimSuffix(rawURL, "/")]; ok {
+		return lang
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	segment, _, _ := strings.Cut(strings.TrimPrefix(parsed.Path, "/"), "/")
+	return f.prefixes[strings.ToLower(segment)]
+}
+
+// AllowsURL reports whether a URL should be queued
+func (f *languageFilter) AllowsURL(rawURL string) bool {
+	return f.Allows(f.URLLanguage(rawURL))
+}
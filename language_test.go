@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{
+			name:     "English",
+			text:     "The client is configured with a token. If the token is not set, requests to the API are rejected and you can see the error in the log for this service.",
+			expected: "en",
+		},
+		{
+			name:     "German",
+			text:     "Der Client wird mit einem Token konfiguriert. Wenn das Token nicht gesetzt ist, werden die Anfragen an die API abgelehnt und der Fehler ist auch im Log von dem Dienst zu sehen.",
+			expected: "de",
+		},
+		{
+			name:     "French",
+			text:     "Le client est configuré avec un jeton. Si le jeton est absent, les requêtes vers l'API sont refusées et vous pouvez voir l'erreur dans le journal du service pour cette raison.",
+			expected: "fr",
+		},
+		{
+			name:     "Japanese",
+			text:     "クライアントはトークンで設定されます。トークンが設定されていない場合、APIへのリクエストは拒否されます。",
+			expected: "ja",
+		},
+		{
+			name:     "Chinese",
+			text:     "客户端使用令牌进行配置。如果未设置令牌，对接口的请求将被拒绝。",
+			expected: "zh",
+		},
+		{
+			name:     "Russian",
+			text:     "Клиент настраивается с помощью токена. Если токен не задан, запросы к API отклоняются.",
+			expected: "ru",
+		},
+		{
+			name:     "Too short",
+			text:     "Install the client",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if lang := detectLanguage(tt.text); lang != tt.expected {
+				t.Errorf("detectLanguage() = %q, want %q", lang, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPageLanguage(t *testing.T) {
+	english := strings.Repeat("The client is configured with a token and it is ready for use. ", 3)
+	resolve := func(href string) string {
+		if strings.HasPrefix(href, "/") {
+			return "https://docs.example.com" + href
+		}
+		return href
+	}
+
+	tests := []struct {
+		name            string
+		html            string
+		contentLanguage string
+		expected        string
+	}{
+		{
+			name:     "html lang",
+			html:     `<html lang="pt_br"><head><meta http-equiv="Content-Language" content="en"></head><body></body></html>`,
+			expected: "pt-BR",
+		},
+		{
+			name:            "Content-Language header",
+			html:            `<html><body></body></html>`,
+			contentLanguage: "de-DE, en",
+			expected:        "de-DE",
+		},
+		{
+			name:     "Content-Language meta tag",
+			html:     `<html><head><meta http-equiv="content-language" content="fr"></head><body></body></html>`,
+			expected: "fr",
+		},
+		{
+			name: "hreflang alternate",
+			html: `<html><head>
+				<link rel="alternate" hreflang="x-default" href="/docs/">
+				<link rel="alternate" hreflang="en" href="/docs/">
+				<link rel="alternate" hreflang="zh-Hans" href="/zh/docs/">
+			</head><body></body></html>`,
+			expected: "zh-Hans",
+		},
+		{
+			name:     "Text detection",
+			html:     `<html><body><main>` + english + `</main></body></html>`,
+			expected: "en",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatalf("failed to parse HTML: %v", err)
+			}
+			alternates := hreflangAlternates(doc.Selection, resolve)
+			lang := pageLanguage(doc.Find("html"), tt.contentLanguage, "https://docs.example.com/zh/docs", alternates, doc.Find("body"))
+			if lang != tt.expected {
+				t.Errorf("pageLanguage() = %q, want %q", lang, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLanguageFilter(t *testing.T) {
+	filter := newLanguageFilter([]string{"en"})
+	filter.Learn([]hreflangAlternate{
+		{Language: "en", URL: "https://docs.example.com/guide/"},
+		{Language: "fr", URL: "https://docs.example.com/fr/guide/"},
+		{Language: "zh-CN", URL: "https://docs.example.com/zh-cn/guide/"},
+		{Language: "de", URL: "https://docs.example.com/guide/?hl=de"},
+	})
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://docs.example.com/guide", true},
+		{"https://docs.example.com/api/", true},
+		{"https://docs.example.com/fr/api/", false},
+		{"https://docs.example.com/zh-cn/guide/install", false},
+		{"https://docs.example.com/guide/?hl=de", false},
+	}
+	for _, tt := range tests {
+		if allowed := filter.AllowsURL(tt.url); allowed != tt.allowed {
+			t.Errorf("AllowsURL(%q) = %v, want %v", tt.url, allowed, tt.allowed)
+		}
+	}
+
+	for lang, allowed := range map[string]bool{"en": true, "en-GB": true, "": true, "de": false} {
+		if got := filter.Allows(lang); got != allowed {
+			t.Errorf("Allows(%q) = %v, want %v", lang, got, allowed)
+		}
+	}
+	if newLanguageFilter([]string{"pt-BR"}).Allows("pt-PT") {
+		t.Error("pt-BR filter allows pt-PT")
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	markdownLinkRe = regexp.MustCompile(`(!?)\[((?:[^\[\]]|\\\[|\\\])*)\]\(([^)\s]+)((?:\s+"[^"]*")?)\)`)
+	htmlHrefRe     = regexp.MustCompile(`href="([^"]+)"`)
+)
+
+// linkIndex maps normalized page URLs to the saved pages
+type linkIndex struct {
+	domain string
+	pages  map[string]*PageInfo
+}
+
+// newLinkIndex indexes the completed pages of a crawl by normalized URL
+func newLinkIndex(manifest *CrawlManifest) *linkIndex {
+	index := &linkIndex{
+		domain: manifest.Metadata.Domain,
+		pages:  make(map[string]*PageInfo),
+	}
+	for _, page := range manifest.CompletedPages() {
+		key := normalizeLinkURL(page.URL)
+		if _, exists := index.pages[key]; !exists {
+			index.pages[key] = page
+		}
+	}
+	return index
+}
+
+// resolve returns the local path for an absolute same-domain link, relative
+// to the file it appears in. internal is true for same-domain links, even when
+// they don't resolve to a saved page.
+func (li *linkIndex) resolve(href, fromFile string) (local string, internal bool) {
+	parsed, err := url.Parse(href)
+	if err != nil || !parsed.IsAbs() || parsed.Host != li.domain {
+		return "", false
+	}
+
+	page, ok := li.pages[normalizeLinkURL(href)]
+	if !ok {
+		return "", true
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(fromFile), page.FileName)
+	if err != nil {
+		return "", true
+	}
+	local = filepath.ToSlash(rel)
+	if parsed.Fragment != "" {
+		local += "#" + parsed.EscapedFragment()
+	}
+	return local, true
+}
+
+// rewriteLinks rewrites links in a Markdown body, skipping fenced code blocks.
+// It returns the new body and the same-domain links that didn't resolve.
+func (li *linkIndex) rewriteLinks(body, fromFile string) (string, []string) {
+	var unresolved []string
+	seen := make(map[string]bool)
+
+	replace := func(href string) string {
+		local, internal := li.resolve(href, fromFile)
+		if local != "" {
+			return local
+		}
+		if internal && !seen[href] {
+			seen[href] = true
+			unresolved = append(unresolved, href)
+		}
+		return href
+	}
+
+	lines := strings.Split(body, "\n")
+	fence := ""
+	for i, line := range lines {
+		inCode := fence != ""
+		if fence = nextFence(line, fence); inCode || fence != "" {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+
+		line = markdownLinkRe.ReplaceAllStringFunc(line, func(match string) string {
+			parts := markdownLinkRe.FindStringSubmatch(match)
+			if parts[1] == "!" {
+				return match
+			}
+			return "[" + parts[2] + "](" + replace(parts[3]) + parts[4] + ")"
+		})
+		if strings.HasPrefix(trimmed, "<table") {
+			line = htmlHrefRe.ReplaceAllStringFunc(line, func(match string) string {
+				return `href="` + replace(htmlHrefRe.FindStringSubmatch(match)[1]) + `"`
+			})
+		}
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n"), unresolved
+}
+
+// rewriteLocalLinks points same-domain links in every saved page at the local
+// Markdown file for that page. Links to pages that weren't saved stay absolute
+// and are recorded on the page as unresolved.
+func rewriteLocalLinks(manifest *CrawlManifest, outputDir string, countTokens tokenCounter) (rewritten, unresolved int, err error) {
+	index := newLinkIndex(manifest)
+
+	for _, page := range manifest.CompletedPages() {
+		filePath := filepath.Join(outputDir, page.FileName)
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return rewritten, unresolved, fmt.Errorf("failed to read %s: %w", page.FileName, err)
+		}
+
+		body, hasFrontMatter := parsePageFile(string(data))
+		newBody, missing := index.rewriteLinks(body, page.FileName)
+		unresolved += len(missing)
+
+		var content string
+		manifest.UpdatePage(page.URL, func(p *PageInfo) {
+			p.UnresolvedLinks = missing
+			if newBody != body {
+				p.TokenCount = countTokens(newBody)
+				content = renderPageFile(p, newBody, hasFrontMatter)
+				p.FileSize = int64(len(content))
+			}
+		})
+		if content == "" {
+			continue
+		}
+
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			return rewritten, unresolved, fmt.Errorf("failed to write %s: %w", page.FileName, err)
+		}
+		rewritten++
+	}
+
+	return rewritten, unresolved, nil
+}
+
+// rewriteLinksDir rewrites internal links in an existing crawl output
+func rewriteLinksDir(outputDir string) error {
+	manifest, err := LoadManifest(outputDir)
+	if err != nil {
+		return err
+	}
+
+	countTokens, err := loadTokenCounter(manifest.Config.Tokenizer)
+	if err != nil {
+		return err
+	}
+
+	rewritten, unresolved, err := rewriteLocalLinks(manifest, outputDir, countTokens)
+	if err != nil {
+		return err
+	}
+
+	if err := manifest.Save(outputDir); err != nil {
+		return err
+	}
+
+	logSuccess("Rewrote links in %d pages (%d links to pages that weren't saved)", rewritten, unresolved)
+	return nil
+}
+
+// normalizeLinkURL reduces a URL to the form used to match links to pages:
+// no fragment, lowercase host and a non-empty path
+func normalizeLinkURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	parsed.Host = strings.ToLower(parsed.Host)
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	return parsed.String()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRewriteLinks(t *testing.T) {
+	index := &linkIndex{
+		domain: "docs.example.com",
+		pages: map[string]*PageInfo{
+			"https://docs.example.com/":              {FileName: "index.md"},
+			"https://docs.example.com/guide/install": {FileName: "guide-install.md"},
+			"https://docs.example.com/api":           {FileName: "api/index.md"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		fromFile   string
+		expected   string
+		unresolved []string
+	}{
+		{
+			name:     "keeps fragment",
+			body:     "See [install](https://docs.example.com/guide/install#linux).",
+			fromFile: "index.md",
+			expected: "See [install](guide-install.md#linux).",
+		},
+		{
+			name:     "root without path",
+			body:     "[Home](https://docs.example.com)",
+			fromFile: "guide-install.md",
+			expected: "[Home](index.md)",
+		},
+		{
+			name:     "relative to subdirectory",
+			body:     "[Home](https://docs.example.com/)",
+			fromFile: "api/index.md",
+			expected: "[Home](../index.md)",
+		},
+		{
+			name:       "unsaved page stays absolute",
+			body:       "[Old](https://docs.example.com/old) and [Old again](https://docs.example.com/old)",
+			fromFile:   "index.md",
+			expected:   "[Old](https://docs.example.com/old) and [Old again](https://docs.example.com/old)",
+			unresolved: []string{"https://docs.example.com/old"},
+		},
+		{
+			name:     "other domains untouched",
+			body:     "[GitHub](https://github.com/example)",
+			fromFile: "index.md",
+			expected: "[GitHub](https://github.com/example)",
+		},
+		{
+			name:     "images untouched",
+			body:     "![logo](https://docs.example.com/api)",
+			fromFile: "index.md",
+			expected: "![logo](https://docs.example.com/api)",
+		},
+		{
+			name:     "code blocks untouched",
+			body:     "```md\n[API](https://docs.example.com/api)\n```\n\n[API](https://docs.example.com/api)",
+			fromFile: "index.md",
+			expected: "```md\n[API](https://docs.example.com/api)\n```\n\n[API](api/index.md)",
+		},
+		{
+			name:     "code in callouts untouched",
+			body:     "> [!NOTE]\n> ```md\n> [API](https://docs.example.com/api)\n> ```\n>\n> See [API](https://docs.example.com/api).",
+			fromFile: "index.md",
+			expected: "> [!NOTE]\n> ```md\n> [API](https://docs.example.com/api)\n> ```\n>\n> See [API](api/index.md).",
+		},
+		{
+			name:     "link titles kept",
+			body:     `[API](https://docs.example.com/api "Reference")`,
+			fromFile: "index.md",
+			expected: `[API](api/index.md "Reference")`,
+		},
+		{
+			name:     "HTML table links",
+			body:     `<table><tr><td><a href="https://docs.example.com/api">API</a></td></tr></table>`,
+			fromFile: "index.md",
+			expected: `<table><tr><td><a href="api/index.md">API</a></td></tr></table>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, unresolved := index.rewriteLinks(tt.body, tt.fromFile)
+			if result != tt.expected {
+				t.Errorf("rewriteLinks() = %q, want %q", result, tt.expected)
+			}
+			if !reflect.DeepEqual(unresolved, tt.unresolved) {
+				t.Errorf("unresolved = %v, want %v", unresolved, tt.unresolved)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -40,6 +41,10 @@ type Crawler struct {
 	outputDir    string
 	maxPages     int
 	pageCount    int32 // Use atomic for thread safety
+	maxTokens    int64
+	tokenCount   int64 // Use atomic for thread safety
+	tokensFull   int32 // set once a page didn't fit in --max-tokens
+	tokens       tokenCounter
 	parallelism  int
 	manifest     *CrawlManifest
 	contentCache *bigcache.BigCache // High-performance cache for duplicate detection
@@ -47,6 +52,19 @@ type Crawler struct {
 	urlQueue     *queue.Queue
 	collector    *colly.Collector
 	verbose      bool
+	config       CrawlConfig
+	rules        *ruleSet
+	boilerplate  *boilerplateDetector
+	parents      sync.Map // URL -> URL of the page it was first linked from
+	charsets     sync.Map // URL -> charset of the response body
+	metaFilters  []metaFilter
+	assets       *assetStore
+	searchIndex  *searchIndex
+	openAPI      *openAPILoader
+	spa          *spaExtractor
+	languages    *languageFilter
+	versions     *versionFilter
+	chunker      *chunker
 
 	// Performance metrics
 	startTime    time.Time
@@ -80,7 +98,15 @@ type cleaningPatterns struct {
 }
 
 // NewCrawler creates a new enhanced crawler instance
-func NewCrawler(targetURL, outputDir string, maxPages, rateLimit, parallelism int, verbose bool) (*Crawler, error) {
+func NewCrawler(targetURL, outputDir string, config CrawlConfig) (*Crawler, error) {
+	// Start inside the tree of the pinned docs version
+	var versions *versionFilter
+	if config.DocVersion != "" {
+		versions = newVersionFilter(config.DocVersion)
+		targetURL = versions.PinURL(targetURL)
+		versions.Learn(targetURL)
+	}
+
 	parsedURL, err := url.Parse(targetURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
@@ -95,14 +121,26 @@ func NewCrawler(targetURL, outputDir string, maxPages, rateLimit, parallelism in
 		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// Initialize crawler configuration
-	config := CrawlConfig{
-		MaxPages:    maxPages,
-		Parallelism: parallelism,
-		Verbose:     verbose,
-		UserAgent:   "CrawlDocs/2.0",
-		RateLimit:   rateLimit,
-		Timeout:     defaultTimeout,
+	// Fill in configuration defaults
+	if config.UserAgent == "" {
+		config.UserAgent = "CrawlDocs/2.0"
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.Format == "" {
+		config.Format = FormatMarkdown
+	}
+
+	// Load content extraction rules
+	rules, err := newRuleSet(config.ContentSelector, config.ExcludeSelector, config.RulesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := loadTokenCounter(config.Tokenizer)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create or load manifest
@@ -143,14 +181,20 @@ func NewCrawler(targetURL, outputDir string, maxPages, rateLimit, parallelism in
 		baseURL:      targetURL,
 		domain:       parsedURL.Host,
 		outputDir:    outputDir,
-		maxPages:     maxPages,
-		parallelism:  parallelism,
+		maxPages:     config.MaxPages,
+		maxTokens:    config.MaxTokens,
+		tokenCount:   manifest.Statistics.TotalTokens,
+		tokens:       tokens,
+		parallelism:  config.Parallelism,
 		manifest:     manifest,
 		contentCache: contentCache,
 		urlBloom:     urlBloom,
-		verbose:      verbose,
+		verbose:      config.Verbose,
+		config:       config,
+		rules:        rules,
+		versions:     versions,
 		startTime:    time.Now(),
-		writeQueue:   make(chan writeTask, parallelism*2),
+		writeQueue:   make(chan writeTask, config.Parallelism*2),
 	}
 
 	// Create optimized HTTP transport with connection pooling
@@ -190,6 +234,7 @@ func NewCrawler(targetURL, outputDir string, maxPages, rateLimit, parallelism in
 
 	// Set the custom HTTP client
 	crawler.collector.SetClient(httpClient)
+	crawler.spa = newSPAExtractor(httpClient, config.UserAgent)
 
 	// Set up rate limiting
 	limitRule := &colly.LimitRule{
@@ -198,8 +243,8 @@ func NewCrawler(targetURL, outputDir string, maxPages, rateLimit, parallelism in
 	}
 
 	// Only set delay if rate limit is not 0 (0 means unlimited)
-	if rateLimit > 0 {
-		limitRule.Delay = time.Second / time.Duration(rateLimit)
+	if config.RateLimit > 0 {
+		limitRule.Delay = time.Second / time.Duration(config.RateLimit)
 	} else {
 		// 0 means no delay (unlimited speed)
 		limitRule.Delay = 0
@@ -219,6 +264,41 @@ func NewCrawler(targetURL, outputDir string, maxPages, rateLimit, parallelism in
 	}
 	crawler.urlQueue = q
 
+	if config.Boilerplate {
+		crawler.boilerplate = newBoilerplateDetector(config.BoilerplateThreshold)
+	}
+
+	for _, spec := range config.MetaFilters {
+		filter, err := parseMetaFilter(spec)
+		if err != nil {
+			return nil, err
+		}
+		crawler.metaFilters = append(crawler.metaFilters, filter)
+	}
+
+	if config.Assets {
+		crawler.assets, err = newAssetStore(httpClient, crawler.domain, outputDir, config, manifest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.SearchIndex {
+		crawler.searchIndex = newSearchIndex(httpClient, parsedURL, config, manifest)
+	}
+
+	if config.OpenAPI {
+		crawler.openAPI = newOpenAPILoader(httpClient, crawler.domain, config)
+	}
+
+	if config.Chunks {
+		crawler.chunker = newChunker(config.ChunkTokens, config.ChunkOverlap, tokens)
+	}
+
+	if len(config.Languages) > 0 {
+		crawler.languages = newLanguageFilter(config.Languages)
+	}
+
 	// Start async write workers
 	for i := 0; i < crawler.parallelism/2; i++ {
 		go crawler.fileWriteWorker()
@@ -235,16 +315,47 @@ func main() {
 		outputDirShort = flag.String("o", "", "Output directory name (shorthand for --output)")
 		maxPages       = flag.Int("max-pages", defaultMaxPages, "Maximum number of pages to crawl")
 		maxPagesShort  = flag.Int("p", defaultMaxPages, "Maximum number of pages to crawl (shorthand for --max-pages)")
+		maxTokens      = flag.Int64("max-tokens", 0, "Stop the crawl once saved pages add up to this many tokens (0 = no limit)")
+		tokenizer      = flag.String("tokenizer", "", "Token counter: estimate (default; about four characters per token, not cl100k) or the path of a downloaded tiktoken ranks file such as cl100k_base.tiktoken")
 		rateLimit      = flag.Int("rate-limit", defaultRateLimit, "Maximum pages per second")
 		rateLimitShort = flag.Int("r", defaultRateLimit, "Maximum pages per second (shorthand for --rate-limit)")
 		workers        = flag.Int("workers", defaultParallelism, "Number of concurrent workers")
 		workersShort   = flag.Int("w", defaultParallelism, "Number of concurrent workers (shorthand for --workers)")
 		verbose        = flag.Bool("verbose", false, "Verbose logging")
 		verboseShort   = flag.Bool("v", false, "Verbose logging (shorthand for --verbose)")
+		format         = flag.String("format", FormatMarkdown, "Output format: markdown or text")
+		legacyClean    = flag.Bool("legacy-clean", false, "Clean --format text output with the old regex-based CSS stripper")
+		contentSel     = flag.String("content-selector", "", "CSS selector for the main content area")
+		excludeSel     = flag.String("exclude-selector", "", "CSS selector for elements to remove from the content")
+		rulesFile      = flag.String("rules", "", "JSON file with per-URL content and exclusion selectors")
 		resume         = flag.Bool("resume", false, "Resume a previous crawl session")
 		report         = flag.Bool("report", false, "Generate a report from manifest")
+		boilerplate    = flag.Bool("boilerplate", false, "Remove text blocks that repeat across most pages")
+		bpThreshold    = flag.Float64("boilerplate-threshold", defaultBoilerplateThreshold, "Share of pages (0-1) a block must appear on to count as boilerplate")
+		stripBP        = flag.Bool("strip-boilerplate", false, "Remove boilerplate from an existing crawl output")
+		frontMatter    = flag.Bool("front-matter", false, "Write YAML front matter with page metadata to each file")
+		localLinks     = flag.Bool("local-links", false, "Rewrite links between crawled pages to point at the saved files")
+		rewriteLinks   = flag.Bool("rewrite-links", false, "Rewrite internal links in an existing crawl output")
+		admonitions    = flag.String("admonitions", AdmonitionGitHub, "Callout style: github, blockquote or none")
+		headingIDs     = flag.String("heading-ids", HeadingIDsAttr, "Heading anchor style: attr ({#id}), html (<a id>) or none")
+		assets         = flag.Bool("assets", false, "Download same-domain images into an assets folder")
+		assetTypes     = flag.String("asset-types", AssetImage, "Comma-separated asset types to download: image, svg, pdf")
+		assetBudget    = flag.Int("asset-budget", defaultAssetBudget/1024/1024, "Maximum total size of downloaded assets in MB")
+		searchIdx      = flag.Bool("search-index", false, "Discover pages from docs search indexes (MkDocs, Sphinx, Docusaurus, VitePress)")
+		searchIdxText  = flag.Bool("search-index-text", false, "Use search index text for pages with minimal content (implies --search-index)")
+		languages      = flag.String("lang", "", "Comma-separated languages to keep (e.g. en or en,de); other translations are skipped")
+		langDirs       = flag.Bool("lang-dirs", false, "Write each language into its own subdirectory")
+		docVersion     = flag.String("doc-version", "", "Only crawl one docs version, e.g. latest, stable or 3.12")
+		documents      = flag.Bool("documents", false, "Also save PDF, plain-text and Markdown files linked from the site")
+		openAPI        = flag.Bool("openapi", false, "Render OpenAPI/Swagger specs found on the site as Markdown, one page per tag")
+		chunks         = flag.Bool("chunks", false, "Write chunks.jsonl, splitting each page along its headings for retrieval")
+		chunkTokens    = flag.Int("chunk-tokens", defaultChunkTokens, "Target chunk size in tokens")
+		chunkOverlap   = flag.Int("chunk-overlap", defaultChunkOverlap, "Tokens of the previous chunk repeated at the start of the next")
+		chunkExport    = flag.Bool("export-chunks", false, "Write chunks.jsonl for an existing crawl output")
 		version        = flag.Bool("version", false, "Display version information")
 	)
+	var metaFilters stringList
+	flag.Var(&metaFilters, "meta-filter", "Only save pages whose metadata matches key=regex (or key!=regex); repeatable")
 	flag.Parse()
 
 	// Handle version flag
@@ -292,6 +403,55 @@ func main() {
 		return
 	}
 
+	// Handle boilerplate removal over an existing crawl
+	if *stripBP {
+		if *outputDir == "" {
+			fmt.Println("Error: --output/-o flag is required for boilerplate removal")
+			os.Exit(1)
+		}
+		if err := stripBoilerplateDir(*outputDir, *bpThreshold); err != nil {
+			log.Fatal("Failed to remove boilerplate:", err)
+		}
+		return
+	}
+
+	// Handle link rewriting over an existing crawl
+	if *rewriteLinks {
+		if *outputDir == "" {
+			fmt.Println("Error: --output/-o flag is required for link rewriting")
+			os.Exit(1)
+		}
+		if err := rewriteLinksDir(*outputDir); err != nil {
+			log.Fatal("Failed to rewrite links:", err)
+		}
+		return
+	}
+
+	if *chunks || *chunkExport {
+		overlapSet := false
+		flag.Visit(func(f *flag.Flag) {
+			overlapSet = overlapSet || f.Name == "chunk-overlap"
+		})
+		overlap, err := chunkOverlapFor(*chunkTokens, *chunkOverlap, overlapSet)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		*chunkOverlap = overlap
+	}
+
+	// Handle chunk export over an existing crawl
+	if *chunkExport {
+		if *outputDir == "" {
+			fmt.Println("Error: --output/-o flag is required for chunk export")
+			os.Exit(1)
+		}
+		if err := exportChunksDir(*outputDir, *chunkTokens, *chunkOverlap, *tokenizer); err != nil {
+			log.Fatal("Failed to export chunks:", err)
+		}
+		return
+	}
+
 	// Validate required flags for crawling
 	if *targetURL == "" && !*resume {
 		fmt.Printf("CrawlDocs v%s - Website Crawler\n", ManifestVersion)
@@ -301,18 +461,51 @@ func main() {
 		fmt.Println("  crawldocs --url <URL> [options]")
 		fmt.Println("  crawldocs --resume --output <dir> [--verbose]")
 		fmt.Println("  crawldocs --report --output <dir>")
+		fmt.Println("  crawldocs --strip-boilerplate --output <dir>")
+		fmt.Println("  crawldocs --rewrite-links --output <dir>")
+		fmt.Println("  crawldocs --export-chunks --output <dir> [--chunk-tokens N] [--chunk-overlap N]")
 		fmt.Println("  crawldocs --version")
 		fmt.Println()
 		fmt.Println("Options:")
-		fmt.Println("  --url, -u         Target URL to crawl (can also be first argument)")
-		fmt.Println("  --output, -o      Output directory (defaults to domain name)")
-		fmt.Println("  --max-pages, -p   Maximum pages to crawl (default: 5000)")
-		fmt.Println("  --rate-limit, -r  Maximum pages per second (default: 10, 0 = unlimited)")
-		fmt.Println("  --workers, -w     Number of concurrent workers (default: 10)")
-		fmt.Println("  --verbose, -v     Verbose output")
-		fmt.Println("  --resume          Resume a previous crawl")
-		fmt.Println("  --report          Generate report from manifest")
-		fmt.Println("  --version         Display version information")
+		fmt.Println("  --url, -u                Target URL to crawl (can also be first argument)")
+		fmt.Println("  --output, -o             Output directory (defaults to domain name)")
+		fmt.Println("  --max-pages, -p          Maximum pages to crawl (default: 5000)")
+		fmt.Println("  --max-tokens             Stop once saved pages add up to this many tokens (default: 0 = no limit)")
+		fmt.Println("  --tokenizer              Token counter: estimate (default, ~4 chars/token, not cl100k) or a downloaded cl100k_base.tiktoken")
+		fmt.Println("  --rate-limit, -r         Maximum pages per second (default: 10, 0 = unlimited)")
+		fmt.Println("  --workers, -w            Number of concurrent workers (default: 10)")
+		fmt.Println("  --verbose, -v            Verbose output")
+		fmt.Println("  --format                 Output format: markdown or text (default: markdown)")
+		fmt.Println("  --legacy-clean           Use the old regex-based CSS stripper for --format text")
+		fmt.Println("  --content-selector       CSS selector for the main content area")
+		fmt.Println("  --exclude-selector       CSS selector for elements to remove (sidebars, banners)")
+		fmt.Println("  --rules                  JSON file with per-URL content/exclude selectors")
+		fmt.Println("  --boilerplate            Remove text blocks repeated across most pages")
+		fmt.Println("  --boilerplate-threshold  Share of pages a block must appear on (default: 0.5)")
+		fmt.Println("  --strip-boilerplate      Remove boilerplate from an existing crawl output")
+		fmt.Println("  --front-matter           Write YAML front matter with page metadata")
+		fmt.Println("  --local-links            Rewrite links between crawled pages to relative file links")
+		fmt.Println("  --rewrite-links          Rewrite internal links in an existing crawl output")
+		fmt.Println("  --admonitions            Callout style: github (> [!WARNING]), blockquote or none (default: github)")
+		fmt.Println("  --heading-ids            Heading anchor style: attr ({#id}), html (<a id>) or none (default: attr)")
+		fmt.Println("  --assets                 Download same-domain images into assets/")
+		fmt.Println("  --asset-types            Asset types to download: image, svg, pdf (default: image)")
+		fmt.Println("  --asset-budget           Maximum total asset size in MB (default: 200)")
+		fmt.Println("  --search-index           Discover pages from docs search indexes")
+		fmt.Println("  --search-index-text      Use search index text for pages with minimal content")
+		fmt.Println("  --lang                   Only keep pages in these languages, e.g. en or en,de")
+		fmt.Println("  --lang-dirs              Write each language into its own subdirectory")
+		fmt.Println("  --doc-version            Only crawl one docs version, e.g. latest, stable or 3.12")
+		fmt.Println("  --documents              Also save linked PDF, plain-text and Markdown files")
+		fmt.Println("  --openapi                Render linked or embedded OpenAPI/Swagger specs, one page per tag")
+		fmt.Println("  --chunks                 Write chunks.jsonl with each page split along its headings")
+		fmt.Println("  --chunk-tokens           Target chunk size in tokens (default: 512)")
+		fmt.Println("  --chunk-overlap          Tokens repeated from the previous chunk (default: 64)")
+		fmt.Println("  --export-chunks          Write chunks.jsonl for an existing crawl output")
+		fmt.Println("  --meta-filter            Only save pages whose metadata matches key=regex or key!=regex (repeatable)")
+		fmt.Println("  --resume                 Resume a previous crawl")
+		fmt.Println("  --report                 Generate report from manifest")
+		fmt.Println("  --version                Display version information")
 		fmt.Println()
 		fmt.Println("Examples:")
 		fmt.Println("  crawldocs https://docs.python.org")
@@ -337,12 +530,128 @@ func main() {
 
 		*targetURL = manifest.Metadata.BaseURL
 		*maxPages = manifest.Config.MaxPages
+		if *maxTokens == 0 {
+			*maxTokens = manifest.Config.MaxTokens
+		}
+		if *tokenizer == "" {
+			*tokenizer = manifest.Config.Tokenizer
+		}
+		if manifest.Config.Format != "" {
+			*format = manifest.Config.Format
+		}
+		if manifest.Config.LegacyClean {
+			*legacyClean = true
+		}
+		if *contentSel == "" {
+			*contentSel = manifest.Config.ContentSelector
+		}
+		if *excludeSel == "" {
+			*excludeSel = manifest.Config.ExcludeSelector
+		}
+		if *rulesFile == "" {
+			*rulesFile = manifest.Config.RulesFile
+		}
+		if len(metaFilters) == 0 {
+			metaFilters = manifest.Config.MetaFilters
+		}
+		if manifest.Config.FrontMatter {
+			*frontMatter = true
+		}
+		if manifest.Config.LocalLinks {
+			*localLinks = true
+		}
+		if manifest.Config.Admonitions != "" {
+			*admonitions = manifest.Config.Admonitions
+		}
+		if manifest.Config.HeadingIDs != "" {
+			*headingIDs = manifest.Config.HeadingIDs
+		}
+		if manifest.Config.Assets {
+			*assets = true
+			*assetTypes = strings.Join(manifest.Config.AssetTypes, ",")
+			*assetBudget = int(manifest.Config.AssetBudget / 1024 / 1024)
+		}
+		if manifest.Config.SearchIndex {
+			*searchIdx = true
+		}
+		if manifest.Config.SearchIndexText {
+			*searchIdxText = true
+		}
+		if *languages == "" {
+			*languages = strings.Join(manifest.Config.Languages, ",")
+		}
+		if manifest.Config.LanguageDirs {
+			*langDirs = true
+		}
+		if *docVersion == "" {
+			*docVersion = manifest.Config.DocVersion
+		}
+		if manifest.Config.Documents {
+			*documents = true
+		}
+		if manifest.Config.OpenAPI {
+			*openAPI = true
+		}
+		if manifest.Config.Chunks {
+			*chunks = true
+			*chunkTokens = manifest.Config.ChunkTokens
+			*chunkOverlap = manifest.Config.ChunkOverlap
+		}
+		if manifest.Config.Boilerplate {
+			*boilerplate = true
+			*bpThreshold = manifest.Config.BoilerplateThreshold
+		}
 		logInfo("Resuming crawl of %s", *targetURL)
 		logProgress(manifest.Statistics.TotalPages, *maxPages, float64(manifest.Statistics.TotalPages)/float64(*maxPages)*100)
 	}
 
+	if *format != FormatMarkdown && *format != FormatText {
+		fmt.Printf("Error: unknown format %q (expected markdown or text)\n", *format)
+		os.Exit(1)
+	}
+	if *admonitions != AdmonitionGitHub && *admonitions != AdmonitionBlockquote && *admonitions != AdmonitionNone {
+		fmt.Printf("Error: unknown admonition style %q (expected github, blockquote or none)\n", *admonitions)
+		os.Exit(1)
+	}
+	if *headingIDs != HeadingIDsAttr && *headingIDs != HeadingIDsHTML && *headingIDs != HeadingIDsNone {
+		fmt.Printf("Error: unknown heading ID style %q (expected attr, html or none)\n", *headingIDs)
+		os.Exit(1)
+	}
+
 	// Create enhanced crawler
-	crawler, err := NewCrawler(*targetURL, *outputDir, *maxPages, *rateLimit, *workers, *verbose)
+	crawler, err := NewCrawler(*targetURL, *outputDir, CrawlConfig{
+		MaxPages:             *maxPages,
+		MaxTokens:            *maxTokens,
+		Tokenizer:            *tokenizer,
+		Parallelism:          *workers,
+		Verbose:              *verbose,
+		RateLimit:            *rateLimit,
+		Format:               *format,
+		LegacyClean:          *legacyClean,
+		ContentSelector:      *contentSel,
+		ExcludeSelector:      *excludeSel,
+		RulesFile:            *rulesFile,
+		Boilerplate:          *boilerplate,
+		BoilerplateThreshold: *bpThreshold,
+		FrontMatter:          *frontMatter,
+		MetaFilters:          metaFilters,
+		LocalLinks:           *localLinks,
+		Admonitions:          *admonitions,
+		HeadingIDs:           *headingIDs,
+		Assets:               *assets,
+		AssetTypes:           splitList(*assetTypes),
+		AssetBudget:          int64(*assetBudget) * 1024 * 1024,
+		SearchIndex:          *searchIdx || *searchIdxText,
+		SearchIndexText:      *searchIdxText,
+		Languages:            splitList(*languages),
+		LanguageDirs:         *langDirs,
+		DocVersion:           *docVersion,
+		Documents:            *documents,
+		OpenAPI:              *openAPI,
+		Chunks:               *chunks,
+		ChunkTokens:          *chunkTokens,
+		ChunkOverlap:         *chunkOverlap,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -368,6 +677,9 @@ func main() {
 	logInfo("Starting crawl of %s", *targetURL)
 	logDim("Output directory: %s", crawler.outputDir)
 	logDim("Max pages: %d", *maxPages)
+	if *maxTokens > 0 {
+		logDim("Max tokens: %d", *maxTokens)
+	}
 	if *rateLimit == 0 {
 		logDim("Rate limit: unlimited")
 	} else {
@@ -389,7 +701,7 @@ func main() {
 
 	logSuccess("Crawling completed! %d pages saved to %s/",
 		atomic.LoadInt32(&crawler.pageCount), crawler.outputDir)
-	logInfo("Performance: %.2f pages/sec, %.2f MB written", pagesPerSecond, mbWritten)
+	logInfo("Performance: %.2f pages/sec, %.2f MB written, %d tokens", pagesPerSecond, mbWritten, atomic.LoadInt64(&crawler.tokenCount))
 	logInfo("View the manifest at: %s/crawl-manifest.json", crawler.outputDir)
 }
 
@@ -432,6 +744,36 @@ func (c *Crawler) Start() error {
 	close(c.writeQueue)
 	c.writeWg.Wait()
 
+	// Strip boilerplate learned during the crawl from pages saved before it was recognised
+	if c.boilerplate != nil {
+		rewritten, err := rewriteBoilerplate(c.manifest, c.outputDir, c.boilerplate, c.tokens)
+		if err != nil {
+			logError("Failed to remove boilerplate: %v", err)
+		} else if c.verbose {
+			logInfo("Removed %d boilerplate blocks, rewrote %d pages", c.boilerplate.BlockCount(), rewritten)
+		}
+	}
+
+	// Point links between saved pages at the local files
+	if c.config.LocalLinks {
+		rewritten, unresolved, err := rewriteLocalLinks(c.manifest, c.outputDir, c.tokens)
+		if err != nil {
+			logError("Failed to rewrite links: %v", err)
+		} else if c.verbose {
+			logInfo("Rewrote links in %d pages, %d links to pages that weren't saved", rewritten, unresolved)
+		}
+	}
+
+	// Export the final pages as chunks for retrieval
+	if c.chunker != nil {
+		total, err := exportChunks(c.manifest, c.outputDir, c.chunker)
+		if err != nil {
+			logError("Failed to export chunks: %v", err)
+		} else if c.verbose {
+			logInfo("Wrote %d chunks to %s", total, chunksFileName)
+		}
+	}
+
 	// Update final statistics
 	c.manifest.Complete()
 	if err := c.manifest.Save(c.outputDir); err != nil {
@@ -454,6 +796,40 @@ func (c *Crawler) setupCallbacks() {
 
 		// Check if content type is HTML
 		if contentType != "" && !strings.Contains(strings.ToLower(contentType), "text/html") {
+			// Specs the site links to are rendered with --openapi
+			if c.openAPI != nil && openAPIContentType(contentType, r.Request.URL.Path) {
+				if spec, err := parseOpenAPISpec(r.Body, r.Request.URL.String()); err == nil {
+					if c.openAPI.claim(spec.URL) {
+						var parentURL string
+						if parent, ok := c.parents.Load(spec.URL); ok {
+							parentURL = parent.(string)
+						}
+						c.saveOpenAPISpec(spec, parentURL, r.Request.Depth-1)
+					}
+					return
+				}
+			}
+
+			// PDFs, plain text and Markdown are saved like pages with --documents
+			if kind := documentKind(contentType, r.Request.URL.Path); kind != "" && c.config.Documents {
+				if kind != DocumentPDF {
+					c.decodeResponse(r, false)
+				}
+				if err := c.saveDocument(r, kind); err != nil {
+					logError("Failed to save document %s: %v", r.Request.URL, err)
+
+					c.manifest.AddPage(&PageInfo{
+						URL:          r.Request.URL.String(),
+						Status:       "failed",
+						ErrorMessage: err.Error(),
+						ResponseCode: r.StatusCode,
+						ContentType:  contentType,
+						CrawledAt:    time.Now(),
+					})
+				}
+				return
+			}
+
 			// Skip non-HTML content
 			c.manifest.AddPage(&PageInfo{
 				URL:          r.Request.URL.String(),
@@ -472,6 +848,9 @@ func (c *Crawler) setupCallbacks() {
 			r.Request.Abort()
 			return
 		}
+
+		// Transcode pages in legacy encodings to UTF-8 before they are parsed
+		c.decodeResponse(r, true)
 	})
 
 	// Handle HTML pages
@@ -487,11 +866,33 @@ func (c *Crawler) setupCallbacks() {
 		// Add to bloom filter for fast lookups
 		c.urlBloom.Add([]byte(currentURL))
 
-		// Check page limit
-		if c.maxPages > 0 && atomic.LoadInt32(&c.pageCount) >= int32(c.maxPages) {
+		// Check page and token limits
+		if c.limitReached() {
 			return
 		}
 
+		// Learn which URLs are translations before this page's links are queued
+		if c.languages != nil {
+			c.languages.Learn(hreflangAlternates(e.DOM, e.Request.AbsoluteURL))
+		}
+
+		// Record the versions offered by the site's version switcher
+		if versions := versionSwitcher(e.DOM, e.Request.AbsoluteURL); len(versions) > 0 {
+			c.manifest.AddVersions(versions)
+		}
+
+		// Queue pages listed in the site's search indexes
+		if c.searchIndex != nil {
+			c.queueSearchIndexPages(e)
+		}
+
+		// Render the OpenAPI specs the page embeds or points at
+		if c.openAPI != nil {
+			for _, spec := range c.openAPI.Discover(e.DOM, e.Request.URL) {
+				c.saveOpenAPISpec(spec, currentURL, e.Request.Depth)
+			}
+		}
+
 		// Extract and save content
 		if err := c.savePage(e, currentURL); err != nil {
 			logError("Failed to save page %s: %v", currentURL, err)
@@ -509,8 +910,8 @@ func (c *Crawler) setupCallbacks() {
 
 	// Find and follow links
 	c.collector.OnHTML("a[href]", func(e *colly.HTMLElement) {
-		// Check page limit
-		if c.maxPages > 0 && atomic.LoadInt32(&c.pageCount) >= int32(c.maxPages) {
+		// Check page and token limits
+		if c.limitReached() {
 			return
 		}
 
@@ -520,6 +921,22 @@ func (c *Crawler) setupCallbacks() {
 		// Only follow links within the same domain
 		// Check bloom filter first for performance, then manifest
 		if c.isValidURL(absoluteURL) && !c.urlBloom.Test([]byte(absoluteURL)) {
+			if c.versions != nil {
+				c.versions.Learn(absoluteURL)
+				if !c.versions.AllowsURL(absoluteURL) {
+					if c.verbose {
+						logSkip("Other version: %s", absoluteURL)
+					}
+					return
+				}
+			}
+			if c.languages != nil && !c.languages.AllowsURL(absoluteURL) {
+				if c.verbose {
+					logSkip("Language %s: %s", c.languages.URLLanguage(absoluteURL), absoluteURL)
+				}
+				return
+			}
+			c.parents.LoadOrStore(absoluteURL, e.Request.URL.String())
 			if err := e.Request.Visit(absoluteURL); err != nil {
 				if !isAlreadyVisitedError(err) && c.verbose {
 					logError("Failed to queue URL %s: %v", absoluteURL, err)
@@ -570,6 +987,7 @@ func (c *Crawler) setupCallbacks() {
 func (c *Crawler) savePage(e *colly.HTMLElement, currentURL string) error {
 	startTime := time.Now()
 	statusCode := e.Response.StatusCode
+	charsetName := c.responseCharset(currentURL)
 
 	// Check HTTP status code first
 	if statusCode != 200 {
@@ -605,25 +1023,149 @@ func (c *Crawler) savePage(e *colly.HTMLElement, currentURL string) error {
 		return nil
 	}
 
-	// Extract text content for 200 responses
+	// Extract content for 200 responses
 	// First try to find main content areas
-	var rawContent string
+	var contentRoot *goquery.Selection
 	var contentSource string
+	var contentScore float64
+	rule := c.rules.Match(e.Request.URL)
+	framework := detectFramework(e.DOM)
+
+	// Try to extract from main content areas first; the framework's content
+	// root wins unless a selector was configured
+	var frameworkContent *goquery.Selection
+	if framework != nil && rule.Content == defaultContentSelector {
+		frameworkContent = e.DOM.Find(framework.Content).First()
+	}
+	mainContent := e.DOM.Find(rule.Content).First()
+	if frameworkContent != nil && frameworkContent.Length() > 0 {
+		contentRoot = frameworkContent
+		contentSource = SourceFramework
+	} else if mainContent.Length() > 0 {
+		contentRoot = mainContent
+		contentSource = SourceSelector
+	} else if candidate, score := findContentByScore(e.DOM); candidate != nil {
+		// Pick the best-scoring subtree
+		contentRoot = candidate
+		contentSource = SourceReadability
+		contentScore = score
+	} else {
+		// Fallback to full page
+		contentRoot = e.DOM
+		contentSource = SourceFullPage
+	}
 
-	// Try to extract from main content areas first
-	mainContent := e.DOM.Find("main, article, [role='main'], .content, #content").First()
-	if mainContent.Length() > 0 {
-		rawContent = mainContent.Text()
-		contentSource = "main content area"
+	// Work on a copy so cleanup doesn't affect the other callbacks
+	contentRoot = contentRoot.Clone()
+	if rule.Exclude != "" {
+		contentRoot.Find(rule.Exclude).Remove()
+	}
+	if framework != nil {
+		contentRoot.Find(framework.Chrome()).Remove()
+	}
+	prepareCodeBlocks(contentRoot)
+	prepareMath(contentRoot)
+	removeNonContent(contentRoot)
+	outline := extractOutline(contentRoot)
+
+	// Detect the page language and skip pages outside --lang
+	alternates := hreflangAlternates(e.DOM, e.Request.AbsoluteURL)
+	language := pageLanguage(e.DOM, e.Response.Headers.Get("Content-Language"), currentURL, alternates, contentRoot)
+	if c.languages != nil && !c.languages.Allows(language) {
+		c.manifest.AddPage(&PageInfo{
+			URL:            currentURL,
+			Status:         "skipped",
+			ErrorMessage:   fmt.Sprintf("language %s", language),
+			ResponseCode:   statusCode,
+			CrawledAt:      time.Now(),
+			ProcessingTime: time.Since(startTime).Milliseconds(),
+			Language:       language,
+		})
+
+		if c.verbose {
+			logSkip("Language %s: %s", language, currentURL)
+		}
+		return nil
+	}
+
+	// Detect the docs version and skip pages outside --doc-version
+	version := pageVersion(e.DOM, e.Request.URL)
+	if c.versions != nil && (!c.versions.AllowsURL(currentURL) || !c.versions.Allows(declaredVersion(e.DOM))) {
+		c.manifest.AddPage(&PageInfo{
+			URL:            currentURL,
+			Status:         "skipped",
+			ErrorMessage:   fmt.Sprintf("version %s", version),
+			ResponseCode:   statusCode,
+			CrawledAt:      time.Now(),
+			ProcessingTime: time.Since(startTime).Milliseconds(),
+			Version:        version,
+		})
+
+		if c.verbose {
+			logSkip("Version %s: %s", version, currentURL)
+		}
+		return nil
+	}
+
+	// With --lang-dirs each language is written to its own subdirectory
+	var fileDir string
+	if c.config.LanguageDirs && language != "" {
+		fileDir = language
+	}
+
+	var rawContent string
+	var codeBlocks []string
+	// Assets are only downloaded once the page passes the checks below
+	pageAssets := &assetRefs{}
+	if c.config.Format == FormatText {
+		// Protect code blocks from text cleaning
+		codeBlocks = protectCodeBlocks(contentRoot)
+		if c.config.LegacyClean {
+			rawContent = contentRoot.Text()
+		} else {
+			rawContent = blockText(contentRoot)
+		}
 	} else {
-		// Fallback to full page text
-		rawContent = e.DOM.Text()
-		contentSource = "full page"
+		converter := newMarkdownConverter(e.Request.URL)
+		converter.assets = c.assets
+		converter.assetRefs = pageAssets
+		converter.admonitions = c.config.Admonitions
+		converter.headingIDs = c.config.HeadingIDs
+		converter.fileDir = fileDir
+		rawContent = converter.Convert(contentRoot)
 	}
 
 	// Extract page metadata
-	metaDescription := e.DOM.Find("meta[name='description']").AttrOr("content", "")
-	ogDescription := e.DOM.Find("meta[property='og:description']").AttrOr("content", "")
+	metadata := extractContentMetadata(e.DOM, e.Request.AbsoluteURL)
+	if framework != nil {
+		metadata[MetaFramework] = framework.Name
+		prev, next := framework.PagerLinks(e.DOM, e.Request.AbsoluteURL)
+		if prev != "" {
+			metadata[MetaPrevURL] = prev
+		}
+		if next != "" {
+			metadata[MetaNextURL] = next
+		}
+	}
+	for _, filter := range c.metaFilters {
+		if !filter.Allows(metadata) {
+			c.manifest.AddPage(&PageInfo{
+				URL:            currentURL,
+				Status:         "skipped",
+				ErrorMessage:   fmt.Sprintf("filtered by metadata: %s", filter),
+				ResponseCode:   statusCode,
+				CrawledAt:      time.Now(),
+				ProcessingTime: time.Since(startTime).Milliseconds(),
+				Metadata:       metadata,
+			})
+
+			if c.verbose {
+				logSkip("Metadata filter %s: %s", filter, currentURL)
+			}
+			return nil
+		}
+	}
+	description := metadata[MetaDescription]
 
 	// Check for potential SPA indicators
 	reactRoot := e.DOM.Find("#root, #app, [data-react-root], [data-reactroot]").Length() > 0
@@ -636,18 +1178,69 @@ func (c *Crawler) savePage(e *colly.HTMLElement, currentURL string) error {
 
 	// Combine content with metadata for better uniqueness
 	fullContent := rawContent
-	if metaDescription != "" {
-		fullContent = metaDescription + "\n\n" + fullContent
-	} else if ogDescription != "" {
-		fullContent = ogDescription + "\n\n" + fullContent
+	if description != "" {
+		fullContent = description + "\n\n" + fullContent
 	}
 
 	// Simple content length validation
-	validation := validateContent(fullContent, currentURL)
+	var validation ContentValidation
+	if c.config.Format == FormatText {
+		validation = validateContent(fullContent, currentURL, c.config.LegacyClean)
+		if len(codeBlocks) > 0 {
+			validation = validateMarkdown(restoreCodeBlocks(validation.CleanedContent, codeBlocks))
+		}
+	} else {
+		validation = validateMarkdown(fullContent)
+	}
+
+	// Strip blocks that repeat across the site before hashing and length checks
+	if c.boilerplate != nil && validation.IsValid {
+		validation = validateMarkdown(c.boilerplate.Process(validation.CleanedContent))
+	}
 
 	if c.verbose {
-		logDim("Content source: %s, Raw length: %d, Cleaned length: %d",
-			contentSource, len(rawContent), len(validation.CleanedContent))
+		source := contentSource
+		if contentSource == SourceReadability {
+			source = fmt.Sprintf("%s (score %.1f)", contentSource, contentScore)
+		}
+		if framework != nil {
+			source = fmt.Sprintf("%s (%s)", source, framework.Name)
+		}
+		logDim("Content source: %s, Rule: %s, Raw length: %d, Cleaned length: %d",
+			source, rule.Name, len(rawContent), len(validation.CleanedContent))
+	}
+
+	// Single-page apps often ship their content in a server-side payload
+	var payloadTitle string
+	if !validation.IsValid {
+		if payload := c.spa.Extract(e.DOM, e.Request.URL); payload != nil {
+			if extracted, payloadOutline := c.renderSPAPayload(payload, e.Request.URL, fileDir, pageAssets); extracted.IsValid {
+				validation = extracted
+				outline = payloadOutline
+				contentSource = SourceSPAPayload
+				contentScore = 0
+				payloadTitle = payload.Title
+				metadata[MetaSPA] = payload.Framework
+				if c.verbose {
+					logDim("Using %s for %s", payload, currentURL)
+				}
+			}
+		}
+	}
+
+	// Pages rendered in the browser can still have their text in the search index
+	if !validation.IsValid && c.config.SearchIndexText && c.searchIndex != nil {
+		if text := c.searchIndex.PageText(currentURL); text != "" {
+			if indexed := validateMarkdown(text); indexed.IsValid {
+				validation = indexed
+				outline = nil
+				contentSource = SourceSearchIndex
+				contentScore = 0
+				if c.verbose {
+					logDim("Using search index text for %s", currentURL)
+				}
+			}
+		}
 	}
 
 	if !validation.IsValid {
@@ -669,27 +1262,86 @@ func (c *Crawler) savePage(e *colly.HTMLElement, currentURL string) error {
 
 	// Get page title first
 	title := strings.TrimSpace(e.DOM.Find("title").Text())
+	if title == "" {
+		title = payloadTitle
+	}
 	if title == "" {
 		title = "Untitled"
 	}
 
-	// Calculate content hash - include title and URL path to make it more unique
-	urlPath := e.Request.URL.Path
-	if urlPath == "" {
-		urlPath = "/"
+	contentHash, duplicate := c.checkDuplicate(currentURL, title, validation.CleanedContent, startTime)
+	if duplicate {
+		return nil
 	}
 
-	// For very short content, include more context to avoid false duplicates
-	var contentForHash string
-	if len(validation.CleanedContent) < 500 {
-		// Short content - include URL and title to differentiate
-		contentForHash = fmt.Sprintf("URL:%s\nTITLE:%s\n%s", urlPath, title, validation.CleanedContent)
-	} else {
-		// Normal content - just use the content
-		contentForHash = validation.CleanedContent
+	content := validation.CleanedContent
+
+	// Extract links
+	var linksFound []string
+	e.DOM.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		if href, exists := s.Attr("href"); exists {
+			absoluteURL := e.Request.AbsoluteURL(href)
+			if c.isValidURL(absoluteURL) {
+				linksFound = append(linksFound, absoluteURL)
+			}
+		}
+	})
+
+	// Create filename using slug
+	atomic.AddInt32(&c.pageCount, 1)
+	filename, filePath, err := c.outputFile(currentURL, fileDir)
+	if err != nil {
+		return err
 	}
 
-	contentHash := CalculateContentHash(contentForHash)
+	// Find where the page was linked from
+	var parentURL string
+	if parent, ok := c.parents.Load(currentURL); ok {
+		parentURL = parent.(string)
+	}
+
+	// Create page info
+	pageInfo := &PageInfo{
+		URL:            currentURL,
+		Title:          title,
+		ContentHash:    contentHash,
+		FileName:       filename,
+		CrawledAt:      time.Now(),
+		ResponseCode:   e.Response.StatusCode,
+		ContentType:    e.Response.Headers.Get("Content-Type"),
+		Charset:        charsetName,
+		ProcessingTime: time.Since(startTime).Milliseconds(),
+		LinksFound:     linksFound,
+		ExtractedLinks: len(linksFound),
+		Status:         "completed",
+		ExtractionRule: rule.Name,
+		ContentSource:  contentSource,
+		ContentScore:   contentScore,
+		Depth:          e.Request.Depth - 1,
+		ParentURL:      parentURL,
+		Description:    description,
+		CanonicalURL:   metadata[MetaCanonical],
+		Language:       language,
+		Version:        version,
+		Metadata:       metadata,
+		Breadcrumbs:    extractBreadcrumbs(e.DOM),
+		WordCount:      countWords(withoutAssets(content, pageAssets)),
+		Outline:        outline,
+	}
+
+	if framework != nil {
+		c.manifest.SetFramework(framework.Name)
+	}
+
+	c.writePage(pageInfo, content, pageAssets, filePath)
+	return nil
+}
+
+// checkDuplicate hashes a page's content and reports whether it duplicates
+// a page saved earlier, recording it as skipped if so
+func (c *Crawler) checkDuplicate(currentURL, title, content string, startTime time.Time) (string, bool) {
+	// Calculate content hash - short content includes title and URL path to make it more unique
+	contentHash := pageContentHash(currentURL, title, content)
 
 	// Check for duplicates using BigCache first (faster)
 	if cachedURL, err := c.contentCache.Get(contentHash); err == nil {
@@ -697,7 +1349,7 @@ func (c *Crawler) savePage(e *colly.HTMLElement, currentURL string) error {
 		originalPage := c.manifest.GetDuplicatePage(contentHash)
 
 		// Only mark as duplicate if content is substantial (not just template)
-		if len(validation.CleanedContent) >= 500 {
+		if len(content) >= 500 {
 			duplicateMsg := "duplicate content"
 			fileName := "unknown"
 
@@ -724,12 +1376,12 @@ func (c *Crawler) savePage(e *colly.HTMLElement, currentURL string) error {
 
 			if c.verbose {
 				logSkip("Duplicate of %s: %s (content length: %d)",
-					fileName, currentURL, len(validation.CleanedContent))
+					fileName, currentURL, len(content))
 			}
-			return nil
+			return contentHash, true
 		} else if c.verbose && originalPage != nil {
 			logDim("Similar template content to %s, but too short to be duplicate (%d chars)",
-				originalPage.FileName, len(validation.CleanedContent))
+				originalPage.FileName, len(content))
 		}
 	}
 
@@ -738,21 +1390,47 @@ func (c *Crawler) savePage(e *colly.HTMLElement, currentURL string) error {
 	if cacheErr != nil && c.verbose {
 		logError("Failed to cache content hash: %v", cacheErr)
 	}
+	return contentHash, false
+}
 
-	// Extract links
-	var linksFound []string
-	e.DOM.Find("a[href]").Each(func(i int, s *goquery.Selection) {
-		if href, exists := s.Attr("href"); exists {
-			absoluteURL := e.Request.AbsoluteURL(href)
-			if c.isValidURL(absoluteURL) {
-				linksFound = append(linksFound, absoluteURL)
-			}
+// limitReached reports whether the crawl has saved --max-pages pages or
+// used up its --max-tokens budget
+func (c *Crawler) limitReached() bool {
+	if c.maxPages > 0 && atomic.LoadInt32(&c.pageCount) >= int32(c.maxPages) {
+		return true
+	}
+	if atomic.LoadInt32(&c.tokensFull) != 0 {
+		return true
+	}
+	return c.maxTokens > 0 && atomic.LoadInt64(&c.tokenCount) >= c.maxTokens
+}
+
+// reserveTokens adds a page's tokens to the crawl total, reporting false if
+// they would take it past --max-tokens. Pages processed in parallel reserve
+// their tokens one at a time, so together they can't overshoot the budget.
+func (c *Crawler) reserveTokens(n int64) bool {
+	for {
+		current := atomic.LoadInt64(&c.tokenCount)
+		if c.maxTokens > 0 && current+n > c.maxTokens {
+			atomic.StoreInt32(&c.tokensFull, 1)
+			return false
 		}
-	})
+		if atomic.CompareAndSwapInt64(&c.tokenCount, current, current+n) {
+			return true
+		}
+	}
+}
 
-	// Create filename using slug
-	atomic.AddInt32(&c.pageCount, 1)
+// outputFile picks a free file name for a page, inside fileDir if it is set,
+// and returns it along with the full path
+func (c *Crawler) outputFile(currentURL, fileDir string) (string, string, error) {
 	slug := slugify(currentURL)
+	if fileDir != "" {
+		if err := os.MkdirAll(filepath.Join(c.outputDir, fileDir), 0755); err != nil {
+			return "", "", fmt.Errorf("failed to create language directory: %w", err)
+		}
+		slug = fileDir + "/" + slug
+	}
 	filename := fmt.Sprintf("%s.md", slug)
 
 	// Check for duplicates and append counter if needed
@@ -766,31 +1444,44 @@ func (c *Crawler) savePage(e *colly.HTMLElement, currentURL string) error {
 		basePath = filepath.Join(c.outputDir, filename)
 		counter++
 	}
-	filePath := basePath
+	return filename, basePath, nil
+}
 
-	// Prepare content with metadata
-	finalContent := fmt.Sprintf("# %s\n\nSource: %s\n\n---\n\n%s",
-		title,
-		currentURL,
-		validation.CleanedContent,
-	)
+// writePage renders a page's file and queues it for writing. A page that
+// doesn't fit in what is left of --max-tokens is skipped instead, and the
+// crawl stops.
+func (c *Crawler) writePage(pageInfo *PageInfo, body string, refs *assetRefs, filePath string) {
+	// Reserve tokens for the page as it reads without its assets, so a page
+	// over the budget is dropped before any of them are downloaded
+	unresolved := withoutAssets(body, refs)
+	pageInfo.TokenCount = c.tokens(unresolved)
+	if !c.reserveTokens(int64(pageInfo.TokenCount)) {
+		atomic.AddInt32(&c.pageCount, -1)
+		c.manifest.AddPage(&PageInfo{
+			URL:            pageInfo.URL,
+			Status:         "skipped",
+			ErrorMessage:   fmt.Sprintf("token budget: %d tokens", pageInfo.TokenCount),
+			ResponseCode:   pageInfo.ResponseCode,
+			CrawledAt:      time.Now(),
+			ProcessingTime: pageInfo.ProcessingTime,
+		})
 
-	// Create page info
-	pageInfo := &PageInfo{
-		URL:            currentURL,
-		Title:          title,
-		ContentHash:    contentHash,
-		FileSize:       int64(len(finalContent)),
-		FileName:       filename,
-		CrawledAt:      time.Now(),
-		ResponseCode:   e.Response.StatusCode,
-		ContentType:    e.Response.Headers.Get("Content-Type"),
-		ProcessingTime: time.Since(startTime).Milliseconds(),
-		LinksFound:     linksFound,
-		ExtractedLinks: len(linksFound),
-		Status:         "completed",
+		if c.verbose {
+			logSkip("Token budget: %s (%d tokens)", pageInfo.URL, pageInfo.TokenCount)
+		}
+		return
+	}
+
+	if c.assets != nil && refs != nil && len(refs.refs) > 0 {
+		body = c.resolveAssets(pageInfo, body, unresolved, refs)
+	} else {
+		body = unresolved
 	}
 
+	// Prepare content with metadata
+	finalContent := renderPageFile(pageInfo, body, c.config.FrontMatter)
+	pageInfo.FileSize = int64(len(finalContent))
+
 	// Queue async write
 	c.writeQueue <- writeTask{
 		filePath: filePath,
@@ -799,12 +1490,121 @@ func (c *Crawler) savePage(e *colly.HTMLElement, currentURL string) error {
 	}
 
 	if c.verbose {
-		logSuccess("Saved %s -> %s (%d bytes)", currentURL, filename, len(finalContent))
+		logSuccess("Saved %s -> %s (%d bytes)", pageInfo.URL, pageInfo.FileName, len(finalContent))
+	}
+}
+
+// resolveAssets downloads a page's assets once its tokens are reserved and
+// settles the reservation with the count of the resolved body. If the local
+// links take more tokens than are left, the page keeps its fallbacks.
+func (c *Crawler) resolveAssets(pageInfo *PageInfo, body, unresolved string, refs *assetRefs) string {
+	resolved := c.assets.Resolve(body, refs)
+	count := c.tokens(resolved)
+	extra := int64(count - pageInfo.TokenCount)
+	if extra > 0 && !c.reserveTokens(extra) {
+		return unresolved
+	}
+	if extra < 0 {
+		atomic.AddInt64(&c.tokenCount, extra)
 	}
+	pageInfo.TokenCount = count
+	pageInfo.WordCount = countWords(resolved)
+	return resolved
+}
+
+// renderSPAPayload converts the content of a single-page app payload like
+// the content of a server-rendered page, returning it with its outline.
+// fileDir is the page's subdirectory of the output directory; the assets
+// the payload references are added to refs.
+func (c *Crawler) renderSPAPayload(payload *spaPayload, pageURL *url.URL, fileDir string, refs *assetRefs) (ContentValidation, []HeadingInfo) {
+	if payload.HTML == "" {
+		return validateMarkdown(payload.Markdown), nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(payload.HTML))
+	if err != nil {
+		return ContentValidation{}, nil
+	}
+	root := doc.Find("body")
+	prepareCodeBlocks(root)
+	prepareMath(root)
+	removeNonContent(root)
+	outline := extractOutline(root)
+
+	if c.config.Format == FormatText {
+		codeBlocks := protectCodeBlocks(root)
+		text := blockText(root)
+		if c.config.LegacyClean {
+			text = root.Text()
+		}
+		validation := validateContent(text, pageURL.String(), c.config.LegacyClean)
+		if len(codeBlocks) > 0 {
+			validation = validateMarkdown(restoreCodeBlocks(validation.CleanedContent, codeBlocks))
+		}
+		return validation, outline
+	}
+
+	converter := newMarkdownConverter(pageURL)
+	converter.assets = c.assets
+	converter.assetRefs = refs
+	converter.admonitions = c.config.Admonitions
+	converter.headingIDs = c.config.HeadingIDs
+	converter.fileDir = fileDir
+	return validateMarkdown(converter.Convert(root)), outline
+}
+
+// queueSearchIndexPages fetches the search indexes a page points at and
+// queues the pages they list that haven't been seen yet
+func (c *Crawler) queueSearchIndexPages(e *colly.HTMLElement) {
+	pages := c.searchIndex.Discover(e.DOM, e.Request.URL, detectFramework(e.DOM))
+	for _, page := range pages {
+		if c.limitReached() {
+			return
+		}
+		if !c.isValidURL(page.URL) || c.urlBloom.Test([]byte(page.URL)) {
+			continue
+		}
+		if c.languages != nil && !c.languages.AllowsURL(page.URL) {
+			continue
+		}
+		if c.versions != nil && !c.versions.AllowsURL(page.URL) {
+			continue
+		}
+		// The parent is the page that led to the index, not the index itself
+		c.parents.LoadOrStore(page.URL, e.Request.URL.String())
+		if err := e.Request.Visit(page.URL); err != nil {
+			if !isAlreadyVisitedError(err) && c.verbose {
+				logError("Failed to queue URL %s: %v", page.URL, err)
+			}
+		}
+	}
+}
+
+// stringList is a flag that can be given multiple times
+type stringList []string
+
+// String joins the values for display
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
 
+// Set appends a value
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
 	return nil
 }
 
+// splitList splits a comma-separated flag value into trimmed, non-empty entries
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
 // isValidURL checks if the URL should be crawled
 func (c *Crawler) isValidURL(absoluteURL string) bool {
 	parsedLink, err := url.Parse(absoluteURL)
@@ -869,6 +1669,15 @@ func generateReport(outputDir string) error {
 	fmt.Printf("Session ID: %s\n", manifest.Metadata.SessionID)
 	fmt.Printf("Base URL: %s\n", manifest.Metadata.BaseURL)
 	fmt.Printf("Status: %s\n", manifest.Metadata.Status)
+	if manifest.Metadata.Framework != "" {
+		fmt.Printf("Framework: %s\n", manifest.Metadata.Framework)
+	}
+	if manifest.Config.DocVersion != "" {
+		fmt.Printf("Docs Version: %s\n", manifest.Config.DocVersion)
+	}
+	if len(manifest.Metadata.Versions) > 0 {
+		fmt.Printf("Versions Offered: %s\n", strings.Join(manifest.Metadata.Versions, ", "))
+	}
 	fmt.Printf("Duration: %s\n", manifest.Statistics.CrawlDuration)
 	fmt.Println("\n--- Statistics ---")
 	fmt.Printf("Total Pages: %d\n", manifest.Statistics.TotalPages)
@@ -879,6 +1688,85 @@ func generateReport(outputDir string) error {
 	fmt.Printf("Total Size: %.2f MB\n", float64(manifest.Statistics.TotalBytes)/1024/1024)
 	fmt.Printf("Avg Page Size: %.2f KB\n", float64(manifest.Statistics.AveragePageSize)/1024)
 	fmt.Printf("Pages/Second: %.2f\n", manifest.Statistics.PagesPerSecond)
+	if manifest.Statistics.TotalAssets > 0 {
+		fmt.Printf("Assets: %d (%.2f MB)\n", manifest.Statistics.TotalAssets, float64(manifest.Statistics.AssetBytes)/1024/1024)
+	}
+	if manifest.Statistics.TotalChunks > 0 {
+		fmt.Printf("Chunks: %d\n", manifest.Statistics.TotalChunks)
+	}
+
+	if manifest.Statistics.TotalTokens > 0 {
+		tokenizer := manifest.Config.Tokenizer
+		if tokenizer == "" {
+			tokenizer = TokenizerEstimate
+		}
+		fmt.Printf("\n--- Tokens (%s) ---\n", tokenizer)
+		fmt.Printf("Total: %d\n", manifest.Statistics.TotalTokens)
+		if manifest.Config.MaxTokens > 0 {
+			fmt.Printf("Budget: %d (%.1f%% used)\n", manifest.Config.MaxTokens,
+				float64(manifest.Statistics.TotalTokens)/float64(manifest.Config.MaxTokens)*100)
+		}
+		for _, prefix := range tokensByPrefix(manifest.CompletedPages()) {
+			fmt.Printf("%s: %d tokens, %d pages (%.1f%%)\n", prefix.Prefix, prefix.Tokens, prefix.Pages,
+				float64(prefix.Tokens)/float64(manifest.Statistics.TotalTokens)*100)
+		}
+	}
+
+	if len(manifest.SearchIndexes) > 0 {
+		fmt.Println("\n--- Search Indexes ---")
+		for _, index := range manifest.SearchIndexes {
+			fmt.Printf("%s (%s): %d pages\n", index.URL, index.Kind, index.Pages)
+		}
+	}
+
+	if len(manifest.OpenAPISpecs) > 0 {
+		fmt.Println("\n--- OpenAPI Specs ---")
+		for _, spec := range manifest.OpenAPISpecs {
+			title := spec.Title
+			if spec.Version != "" {
+				title += " " + spec.Version
+			}
+			fmt.Printf("%s (%s): %d pages\n", spec.URL, title, spec.Pages)
+		}
+	}
+
+	languageCounts := make(map[string]int)
+	for _, page := range manifest.CompletedPages() {
+		if page.Language != "" {
+			languageCounts[page.Language]++
+		}
+	}
+	if len(languageCounts) > 1 {
+		languages := make([]string, 0, len(languageCounts))
+		for lang := range languageCounts {
+			languages = append(languages, lang)
+		}
+		sort.Strings(languages)
+
+		fmt.Println("\n--- Languages ---")
+		for _, lang := range languages {
+			fmt.Printf("%s: %d pages\n", lang, languageCounts[lang])
+		}
+	}
+
+	charsetCounts := make(map[string]int)
+	for _, page := range manifest.CompletedPages() {
+		if page.Charset != "" {
+			charsetCounts[page.Charset]++
+		}
+	}
+	if len(charsetCounts) > 1 || (len(charsetCounts) == 1 && charsetCounts["utf-8"] == 0) {
+		charsets := make([]string, 0, len(charsetCounts))
+		for name := range charsetCounts {
+			charsets = append(charsets, name)
+		}
+		sort.Strings(charsets)
+
+		fmt.Println("\n--- Charsets ---")
+		for _, name := range charsets {
+			fmt.Printf("%s: %d pages\n", name, charsetCounts[name])
+		}
+	}
 
 	if len(manifest.Statistics.ErrorTypes) > 0 {
 		fmt.Println("\n--- Error Summary ---")
@@ -892,5 +1780,30 @@ func generateReport(outputDir string) error {
 		fmt.Printf("%d: %d\n", code, count)
 	}
 
+	// Internal links left absolute because the target page wasn't saved
+	linkCounts := make(map[string]int)
+	for _, page := range manifest.CompletedPages() {
+		for _, link := range page.UnresolvedLinks {
+			linkCounts[link]++
+		}
+	}
+	if len(linkCounts) > 0 {
+		links := make([]string, 0, len(linkCounts))
+		for link := range linkCounts {
+			links = append(links, link)
+		}
+		sort.Slice(links, func(i, j int) bool {
+			if linkCounts[links[i]] != linkCounts[links[j]] {
+				return linkCounts[links[i]] > linkCounts[links[j]]
+			}
+			return links[i] < links[j]
+		})
+
+		fmt.Printf("\n--- Unresolved Links (%d) ---\n", len(links))
+		for _, link := range links {
+			fmt.Printf("%s (linked from %d pages)\n", link, linkCounts[link])
+		}
+	}
+
 	return nil
 }
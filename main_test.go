@@ -1,8 +1,15 @@
 package main
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/bits-and-blooms/bloom/v3"
+	"github.com/gocolly/colly/v2"
 )
 
 func TestCleanHTMLSimple(t *testing.T) {
@@ -45,3 +52,122 @@ func TestCleanHTMLSimple(t *testing.T) {
 		})
 	}
 }
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		contains []string
+	}{
+		{
+			name:     "Code with braces is kept",
+			input:    "Define the handler:   func main() { color: red; }   and run it.",
+			contains: []string{"func main() { color: red; }", "and run it."},
+		},
+		{
+			name:     "No doubled periods",
+			input:    "Does it work? It does. Really!",
+			contains: []string{"Does it work? It does. Really!"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := cleanText(tt.input)
+			for _, want := range tt.contains {
+				if !strings.Contains(result, want) {
+					t.Errorf("cleanText() = %q, want to contain %q", result, want)
+				}
+			}
+			if strings.Contains(result, "..") || strings.Contains(result, "?.") || strings.Contains(result, "!.") {
+				t.Errorf("cleanText() = %q, has doubled punctuation", result)
+			}
+		})
+	}
+}
+
+func TestRemoveNonContent(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body><main>
+		<p>Visible</p>
+		<style>.a { color: red; }</style>
+		<script>var x = 1;</script>
+		<svg><text>icon</text></svg>
+		<div hidden>Hidden</div>
+		<div style="display: none">Collapsed</div>
+		<div role="tabpanel" hidden>Second tab</div>
+	</main></body></html>`))
+	if err != nil {
+		t.Fatalf("failed to parse HTML: %v", err)
+	}
+
+	removeNonContent(doc.Selection)
+	text := collapseWhitespace(doc.Find("main").Text())
+	for _, gone := range []string{"color", "var x", "icon", "Hidden", "Collapsed"} {
+		if strings.Contains(text, gone) {
+			t.Errorf("text = %q, still contains %q", text, gone)
+		}
+	}
+	if !strings.Contains(text, "Visible") || !strings.Contains(text, "Second tab") {
+		t.Errorf("text = %q, want visible content and the hidden tab panel", text)
+	}
+}
+
+func TestBlockText(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body><main>
+		<h2>Install</h2>
+		<p>Use a package manager, e.g. Homebrew. Version 1.2.3 is the
+		   latest. It ships <code>crawl</code> and <em>report</em>.</p>
+		<ul>
+			<li>macOS</li>
+			<li>Linux<ul><li>Debian</li></ul></li>
+		</ul>
+		<table><tr><th>Flag</th><th>Default</th></tr><tr><td>-p</td><td>5000</td></tr></table>
+		<p>First line<br>Second line</p>
+	</main></body></html>`))
+	if err != nil {
+		t.Fatalf("failed to parse HTML: %v", err)
+	}
+
+	expected := "Install\n\n" +
+		"Use a package manager, e.g. Homebrew. Version 1.2.3 is the latest. It ships crawl and report.\n\n" +
+		"macOS\nLinux\nDebian\n\n" +
+		"Flag Default\n-p 5000\n\n" +
+		"First line\nSecond line"
+	if text := cleanText(blockText(doc.Find("main"))); text != expected {
+		t.Errorf("cleanText(blockText()) = %q, want %q", text, expected)
+	}
+}
+
+func TestSearchIndexPagesParent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/guide/":
+			w.Write([]byte(`<html><head><meta name="generator" content="mkdocs-1.5.3"></head><body><script>var base_url = "..";</script></body></html>`))
+		case "/docs/search/search_index.json":
+			w.Write([]byte(`{"docs":[{"location":"hidden/","title":"Hidden","text":"Only in the index"}]}`))
+		default:
+			w.Write([]byte(`<html><body>Hidden</body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	startURL, _ := url.Parse(server.URL + "/docs/guide/")
+	manifest := NewManifest(server.URL, startURL.Host, t.TempDir(), CrawlConfig{})
+	c := &Crawler{
+		domain:      startURL.Host,
+		manifest:    manifest,
+		searchIndex: newSearchIndex(server.Client(), startURL, CrawlConfig{}, manifest),
+		urlBloom:    bloom.NewWithEstimates(1000, 0.0001),
+	}
+
+	collector := colly.NewCollector(colly.AllowedDomains(startURL.Hostname()))
+	collector.OnHTML("html", c.queueSearchIndexPages)
+	if err := collector.Visit(startURL.String()); err != nil {
+		t.Fatalf("Visit() error: %v", err)
+	}
+
+	parent, ok := c.parents.Load(server.URL + "/docs/hidden/")
+	if !ok || parent != startURL.String() {
+		t.Errorf("parent of an index-only page = %v, want the page that led to the index %s", parent, startURL)
+	}
+}
@@ -5,21 +5,27 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
 // CrawlManifest represents the complete crawl session data
 type CrawlManifest struct {
-	Version    string               `json:"version"`
-	Metadata   CrawlMetadata        `json:"metadata"`
-	Pages      map[string]*PageInfo `json:"pages"`
-	Queue      []QueueItem          `json:"queue"`
-	Statistics CrawlStatistics      `json:"statistics"`
-	Config     CrawlConfig          `json:"config"`
-	mutex      sync.RWMutex
+	Version       string                `json:"version"`
+	Metadata      CrawlMetadata         `json:"metadata"`
+	Pages         map[string]*PageInfo  `json:"pages"`
+	Assets        map[string]*AssetInfo `json:"assets,omitempty"`
+	SearchIndexes []SearchIndexInfo     `json:"search_indexes,omitempty"`
+	OpenAPISpecs  []OpenAPISpecInfo     `json:"openapi_specs,omitempty"`
+	Queue         []QueueItem           `json:"queue"`
+	Statistics    CrawlStatistics       `json:"statistics"`
+	Config        CrawlConfig           `json:"config"`
+	mutex         sync.RWMutex
 }
 
 // CrawlMetadata contains session information
@@ -31,27 +37,77 @@ type CrawlMetadata struct {
 	BaseURL   string    `json:"base_url"`
 	Domain    string    `json:"domain"`
 	OutputDir string    `json:"output_dir"`
+	Framework string    `json:"framework,omitempty"` // docs generator detected on the first saved page
+	Versions  []string  `json:"versions,omitempty"`  // docs versions offered by the site's version switcher
 }
 
 // PageInfo contains detailed information about each crawled page
 type PageInfo struct {
-	URL            string            `json:"url"`
-	Title          string            `json:"title"`
-	ContentHash    string            `json:"content_hash"`
-	FileSize       int64             `json:"file_size"`
-	FileName       string            `json:"file_name"`
-	CrawledAt      time.Time         `json:"crawled_at"`
-	LastModified   time.Time         `json:"last_modified,omitempty"`
-	ResponseCode   int               `json:"response_code"`
-	ContentType    string            `json:"content_type"`
-	ProcessingTime int64             `json:"processing_time_ms"`
-	LinksFound     []string          `json:"links_found"`
-	ExtractedLinks int               `json:"extracted_links"`
-	ParentURL      string            `json:"parent_url,omitempty"`
-	Depth          int               `json:"depth"`
-	Status         string            `json:"status"` // "completed", "failed", "skipped"
-	ErrorMessage   string            `json:"error_message,omitempty"`
-	Metadata       map[string]string `json:"metadata,omitempty"`
+	URL             string            `json:"url"`
+	Title           string            `json:"title"`
+	ContentHash     string            `json:"content_hash"`
+	FileSize        int64             `json:"file_size"`
+	FileName        string            `json:"file_name"`
+	CrawledAt       time.Time         `json:"crawled_at"`
+	LastModified    time.Time         `json:"last_modified,omitempty"`
+	ResponseCode    int               `json:"response_code"`
+	ContentType     string            `json:"content_type"`
+	Charset         string            `json:"charset,omitempty"` // encoding of the response body before it was transcoded to UTF-8
+	ProcessingTime  int64             `json:"processing_time_ms"`
+	LinksFound      []string          `json:"links_found"`
+	ExtractedLinks  int               `json:"extracted_links"`
+	ParentURL       string            `json:"parent_url,omitempty"`
+	Depth           int               `json:"depth"`
+	Status          string            `json:"status"` // "completed", "failed", "skipped"
+	ErrorMessage    string            `json:"error_message,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	ExtractionRule  string            `json:"extraction_rule,omitempty"`
+	ContentSource   string            `json:"content_source,omitempty"` // "selector", "framework", "readability", "full page", ...
+	ContentScore    float64           `json:"content_score,omitempty"`
+	Description     string            `json:"description,omitempty"`
+	CanonicalURL    string            `json:"canonical_url,omitempty"`
+	Language        string            `json:"language,omitempty"`
+	Version         string            `json:"version,omitempty"`
+	Breadcrumbs     []string          `json:"breadcrumbs,omitempty"`
+	WordCount       int               `json:"word_count,omitempty"`
+	TokenCount      int               `json:"token_count,omitempty"`
+	UnresolvedLinks []string          `json:"unresolved_links,omitempty"`
+	Outline         []HeadingInfo     `json:"outline,omitempty"`
+}
+
+// HeadingInfo is an entry in a page's outline
+type HeadingInfo struct {
+	Level  int    `json:"level"`
+	Text   string `json:"text"`
+	Anchor string `json:"anchor,omitempty"`
+}
+
+// AssetInfo describes a downloaded image or attachment
+type AssetInfo struct {
+	URL          string    `json:"url"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Hash         string    `json:"hash"`
+	SourcePages  []string  `json:"source_pages"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+// SearchIndexInfo describes a docs search index fetched during the crawl
+type SearchIndexInfo struct {
+	URL       string    `json:"url"`
+	Kind      string    `json:"kind"` // "mkdocs", "sphinx", "docusaurus" or "vitepress"
+	Pages     int       `json:"pages"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// OpenAPISpecInfo describes an OpenAPI spec rendered during the crawl
+type OpenAPISpecInfo struct {
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	Version   string    `json:"version,omitempty"`
+	Pages     int       `json:"pages"`
+	FetchedAt time.Time `json:"fetched_at"`
 }
 
 // QueueItem represents a URL waiting to be crawled
@@ -65,20 +121,25 @@ type QueueItem struct {
 
 // CrawlStatistics tracks overall crawl performance
 type CrawlStatistics struct {
-	TotalPages      int                 `json:"total_pages"`
-	SuccessfulPages int                 `json:"successful_pages"`
-	FailedPages     int                 `json:"failed_pages"`
-	SkippedPages    int                 `json:"skipped_pages"`
-	DuplicatePages  int                 `json:"duplicate_pages"`
-	TotalBytes      int64               `json:"total_bytes"`
-	AveragePageSize int64               `json:"average_page_size"`
-	CrawlDuration   string              `json:"crawl_duration"`
-	PagesPerSecond  float64             `json:"pages_per_second"`
-	BytesPerSecond  float64             `json:"bytes_per_second"`
-	ErrorTypes      map[string]int      `json:"error_types"`
-	StatusCodes     map[int]int         `json:"status_codes"`
-	ContentTypes    map[string]int      `json:"content_types"`
-	ProcessingTimes ProcessingTimeStats `json:"processing_times"`
+	TotalPages        int                 `json:"total_pages"`
+	SuccessfulPages   int                 `json:"successful_pages"`
+	FailedPages       int                 `json:"failed_pages"`
+	SkippedPages      int                 `json:"skipped_pages"`
+	DuplicatePages    int                 `json:"duplicate_pages"`
+	TotalBytes        int64               `json:"total_bytes"`
+	AveragePageSize   int64               `json:"average_page_size"`
+	CrawlDuration     string              `json:"crawl_duration"`
+	PagesPerSecond    float64             `json:"pages_per_second"`
+	BytesPerSecond    float64             `json:"bytes_per_second"`
+	ErrorTypes        map[string]int      `json:"error_types"`
+	StatusCodes       map[int]int         `json:"status_codes"`
+	ContentTypes      map[string]int      `json:"content_types"`
+	ProcessingTimes   ProcessingTimeStats `json:"processing_times"`
+	BoilerplateBlocks int                 `json:"boilerplate_blocks,omitempty"`
+	TotalAssets       int                 `json:"total_assets,omitempty"`
+	AssetBytes        int64               `json:"asset_bytes,omitempty"`
+	TotalChunks       int                 `json:"total_chunks,omitempty"`
+	TotalTokens       int64               `json:"total_tokens,omitempty"`
 }
 
 // ProcessingTimeStats tracks processing time metrics
@@ -91,12 +152,39 @@ type ProcessingTimeStats struct {
 
 // CrawlConfig stores the configuration used for the crawl
 type CrawlConfig struct {
-	MaxPages    int    `json:"max_pages"`
-	Parallelism int    `json:"parallelism"`
-	Verbose     bool   `json:"verbose"`
-	UserAgent   string `json:"user_agent"`
-	RateLimit   int    `json:"rate_limit"`
-	Timeout     int    `json:"timeout_seconds"`
+	MaxPages             int      `json:"max_pages"`
+	Parallelism          int      `json:"parallelism"`
+	Verbose              bool     `json:"verbose"`
+	UserAgent            string   `json:"user_agent"`
+	RateLimit            int      `json:"rate_limit"`
+	Timeout              int      `json:"timeout_seconds"`
+	Format               string   `json:"format"`
+	LegacyClean          bool     `json:"legacy_clean,omitempty"`
+	ContentSelector      string   `json:"content_selector,omitempty"`
+	ExcludeSelector      string   `json:"exclude_selector,omitempty"`
+	RulesFile            string   `json:"rules_file,omitempty"`
+	Boilerplate          bool     `json:"boilerplate,omitempty"`
+	BoilerplateThreshold float64  `json:"boilerplate_threshold,omitempty"`
+	FrontMatter          bool     `json:"front_matter,omitempty"`
+	MetaFilters          []string `json:"meta_filters,omitempty"`
+	LocalLinks           bool     `json:"local_links,omitempty"`
+	Assets               bool     `json:"assets,omitempty"`
+	AssetTypes           []string `json:"asset_types,omitempty"`
+	AssetBudget          int64    `json:"asset_budget_bytes,omitempty"`
+	Admonitions          string   `json:"admonitions,omitempty"`
+	HeadingIDs           string   `json:"heading_ids,omitempty"`
+	SearchIndex          bool     `json:"search_index,omitempty"`
+	SearchIndexText      bool     `json:"search_index_text,omitempty"`
+	Languages            []string `json:"languages,omitempty"`
+	LanguageDirs         bool     `json:"language_dirs,omitempty"`
+	DocVersion           string   `json:"doc_version,omitempty"`
+	Documents            bool     `json:"documents,omitempty"`
+	OpenAPI              bool     `json:"openapi,omitempty"`
+	Chunks               bool     `json:"chunks,omitempty"`
+	ChunkTokens          int      `json:"chunk_tokens,omitempty"`
+	ChunkOverlap         int      `json:"chunk_overlap,omitempty"`
+	MaxTokens            int64    `json:"max_tokens,omitempty"`
+	Tokenizer            string   `json:"tokenizer,omitempty"`
 }
 
 // NewManifest creates a new crawl manifest
@@ -135,6 +223,7 @@ func (m *CrawlManifest) AddPage(info *PageInfo) {
 	if info.Status == "completed" {
 		m.Statistics.SuccessfulPages++
 		m.Statistics.TotalBytes += info.FileSize
+		m.Statistics.TotalTokens += int64(info.TokenCount)
 		m.Statistics.StatusCodes[info.ResponseCode]++
 		m.Statistics.ContentTypes[info.ContentType]++
 
@@ -211,6 +300,181 @@ func (m *CrawlManifest) GetDuplicatePage(contentHash string) *PageInfo {
 	return nil
 }
 
+// CompletedPages returns the successfully saved pages in crawl order
+func (m *CrawlManifest) CompletedPages() []*PageInfo {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var pages []*PageInfo
+	for _, page := range m.Pages {
+		if page.Status == "completed" {
+			pages = append(pages, page)
+		}
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].CrawledAt.Before(pages[j].CrawledAt)
+	})
+	return pages
+}
+
+// UpdatePage applies changes to a saved page, keeping the byte and token
+// totals in step with its file size and token count
+func (m *CrawlManifest) UpdatePage(pageURL string, update func(*PageInfo)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	page, ok := m.Pages[pageURL]
+	if !ok {
+		return
+	}
+	previousSize, previousTokens := page.FileSize, page.TokenCount
+	update(page)
+	if page.Status == "completed" {
+		m.Statistics.TotalBytes += page.FileSize - previousSize
+		m.Statistics.TotalTokens += int64(page.TokenCount - previousTokens)
+	}
+}
+
+// MarkDuplicate turns a completed page into a skipped duplicate of another page
+func (m *CrawlManifest) MarkDuplicate(pageURL, originalURL, contentHash string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	page, ok := m.Pages[pageURL]
+	if !ok || page.Status != "completed" {
+		return
+	}
+
+	m.Statistics.SuccessfulPages--
+	m.Statistics.SkippedPages++
+	m.Statistics.DuplicatePages++
+	m.Statistics.TotalBytes -= page.FileSize
+	m.Statistics.TotalTokens -= int64(page.TokenCount)
+
+	page.Status = "skipped"
+	page.ErrorMessage = fmt.Sprintf("duplicate of %s", originalURL)
+	page.ContentHash = contentHash
+	page.FileName = ""
+	page.FileSize = 0
+}
+
+// SetBoilerplateBlocks records how many boilerplate blocks were removed from pages
+func (m *CrawlManifest) SetBoilerplateBlocks(count int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.Statistics.BoilerplateBlocks = count
+}
+
+// SetChunks records how many chunks were written to chunks.jsonl
+func (m *CrawlManifest) SetChunks(count int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.Statistics.TotalChunks = count
+}
+
+// AssetSource returns the saved file for an already downloaded asset and
+// records pageURL as one of its source pages
+func (m *CrawlManifest) AssetSource(assetURL, pageURL string) (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	asset, ok := m.Assets[assetURL]
+	if !ok {
+		return "", false
+	}
+	for _, source := range asset.SourcePages {
+		if source == pageURL {
+			return asset.FileName, true
+		}
+	}
+	asset.SourcePages = append(asset.SourcePages, pageURL)
+	return asset.FileName, true
+}
+
+// AddAsset records a downloaded asset. Assets sharing a content hash share a
+// file, so their bytes are only counted once.
+func (m *CrawlManifest) AddAsset(info *AssetInfo) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.Assets == nil {
+		m.Assets = make(map[string]*AssetInfo)
+	}
+	if _, exists := m.Assets[info.URL]; exists {
+		return
+	}
+
+	duplicate := false
+	for _, asset := range m.Assets {
+		if asset.Hash == info.Hash {
+			duplicate = true
+			break
+		}
+	}
+
+	m.Assets[info.URL] = info
+	m.Statistics.TotalAssets++
+	if !duplicate {
+		m.Statistics.AssetBytes += info.Size
+	}
+}
+
+// AssetBytes returns the bytes of asset files written so far
+func (m *CrawlManifest) AssetBytes() int64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.Statistics.AssetBytes
+}
+
+// AddSearchIndex records a search index fetched during the crawl
+func (m *CrawlManifest) AddSearchIndex(info SearchIndexInfo) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.SearchIndexes = append(m.SearchIndexes, info)
+}
+
+// AddOpenAPISpec records an OpenAPI spec rendered during the crawl
+func (m *CrawlManifest) AddOpenAPISpec(info OpenAPISpecInfo) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.OpenAPISpecs = append(m.OpenAPISpecs, info)
+}
+
+// SetFramework records the docs framework of the site, keeping the first one detected
+func (m *CrawlManifest) SetFramework(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.Metadata.Framework == "" {
+		m.Metadata.Framework = name
+	}
+}
+
+// AddVersions records docs versions found in a version switcher, keeping
+// the order they were first seen in
+func (m *CrawlManifest) AddVersions(versions []string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, version := range versions {
+		known := false
+		for _, existing := range m.Metadata.Versions {
+			if strings.EqualFold(existing, version) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			m.Metadata.Versions = append(m.Metadata.Versions, version)
+		}
+	}
+}
+
 // IsVisited checks if a URL has been visited
 func (m *CrawlManifest) IsVisited(url string) bool {
 	m.mutex.RLock()
@@ -306,6 +570,21 @@ func CalculateContentHash(content string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+// pageContentHash hashes page content for duplicate detection. Short pages
+// include the URL path and title so that pages sharing a template aren't
+// reported as duplicates.
+func pageContentHash(pageURL, title, content string) string {
+	if len(content) >= 500 {
+		return CalculateContentHash(content)
+	}
+
+	urlPath := "/"
+	if parsed, err := url.Parse(pageURL); err == nil && parsed.Path != "" {
+		urlPath = parsed.Path
+	}
+	return CalculateContentHash(fmt.Sprintf("URL:%s\nTITLE:%s\n%s", urlPath, title, content))
+}
+
 // GetProgress returns current crawl progress
 func (m *CrawlManifest) GetProgress() (completed, total int, percentage float64) {
 	m.mutex.RLock()
@@ -0,0 +1,643 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Output formats supported by the crawler
+const (
+	FormatMarkdown = "markdown"
+	FormatText     = "text"
+)
+
+var (
+	blockMarkerRe = regexp.MustCompile(`^(#{1,6}\s|>|[-+]\s|\d+[.)]\s)`)
+)
+
+// markdownConverter renders a DOM subtree as Markdown
+type markdownConverter struct {
+	baseURL *url.URL
+	// assets downloads images and attachments; images are dropped when nil
+	assets *assetStore
+	// assetRefs collects the assets referenced by the page; the rendered
+	// Markdown holds placeholders until assets.Resolve replaces them
+	assetRefs *assetRefs
+	// admonitions is the callout style; empty means GitHub alerts
+	admonitions string
+	// headingIDs is the heading anchor style; empty means {#id} attributes
+	headingIDs string
+	// permalink is the fragment of the heading being rendered
+	permalink string
+	// fileDir is the directory of the output file relative to the output
+	// directory; asset paths are made relative to it
+	fileDir string
+}
+
+// mdBlock is a rendered block-level element
+type mdBlock struct {
+	text string
+	list bool
+}
+
+// newMarkdownConverter creates a converter that resolves links against baseURL
+func newMarkdownConverter(baseURL *url.URL) *markdownConverter {
+	return &markdownConverter{baseURL: baseURL}
+}
+
+// Convert renders every node in the selection as Markdown
+func (mc *markdownConverter) Convert(sel *goquery.Selection) string {
+	var blocks []mdBlock
+	for _, n := range sel.Nodes {
+		blocks = append(blocks, mc.renderBlocks(n)...)
+	}
+	return normalizeMarkdown(joinBlocks(blocks, false))
+}
+
+// renderBlocks renders the children of n as a sequence of blocks,
+// grouping consecutive inline content into paragraphs
+func (mc *markdownConverter) renderBlocks(n *html.Node) []mdBlock {
+	var blocks []mdBlock
+	var inline strings.Builder
+
+	flush := func() {
+		if text := cleanParagraph(inline.String()); text != "" {
+			blocks = append(blocks, mdBlock{text: text})
+		}
+		inline.Reset()
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if mathKind(child) == "display" {
+			flush()
+			blocks = append(blocks, mdBlock{text: displayMathBlock(child)})
+			continue
+		}
+		if child.Type == html.ElementNode && isSkippedElement(child) {
+			continue
+		}
+		if child.Type == html.ElementNode && isBlockElement(child) {
+			flush()
+			if block, ok := mc.renderBlock(child); ok {
+				blocks = append(blocks, block...)
+			}
+			continue
+		}
+		inline.WriteString(mc.renderInline(child))
+	}
+	flush()
+
+	return blocks
+}
+
+// renderBlock renders a single block-level element
+func (mc *markdownConverter) renderBlock(n *html.Node) ([]mdBlock, bool) {
+	if mc.admonitions != AdmonitionNone {
+		if kind, ok := admonitionType(n); ok {
+			return mc.renderAdmonition(n, kind)
+		}
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return mc.renderHeading(n)
+
+	case atom.Ul, atom.Ol:
+		text := mc.renderList(n)
+		if text == "" {
+			return nil, false
+		}
+		return []mdBlock{{text: text, list: true}}, true
+
+	case atom.Pre:
+		text := mc.renderCodeBlock(n)
+		if text == "" {
+			return nil, false
+		}
+		return []mdBlock{{text: text}}, true
+
+	case atom.Blockquote:
+		inner := joinBlocks(mc.renderBlocks(n), false)
+		if inner == "" {
+			return nil, false
+		}
+		return []mdBlock{{text: prefixLines(inner, "> ", ">")}}, true
+
+	case atom.Hr:
+		return []mdBlock{{text: "---"}}, true
+
+	case atom.Table:
+		text := mc.renderTable(n)
+		if text == "" {
+			return nil, false
+		}
+		return []mdBlock{{text: text}}, true
+
+	case atom.Dt:
+		text := cleanInline(mc.renderInlineChildren(n))
+		if text == "" {
+			return nil, false
+		}
+		return []mdBlock{{text: "**" + text + "**"}}, true
+
+	default:
+		blocks := mc.renderBlocks(n)
+		return blocks, len(blocks) > 0
+	}
+}
+
+// renderList renders an ordered or unordered list, including nested lists
+func (mc *markdownConverter) renderList(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	index := 1
+	if ordered {
+		if start, err := strconv.Atoi(getAttr(n, "start")); err == nil {
+			index = start
+		}
+	}
+
+	var items []string
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.DataAtom != atom.Li {
+			continue
+		}
+
+		content := joinBlocks(mc.renderBlocks(child), true)
+		if content == "" {
+			continue
+		}
+
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(index) + ". "
+			index++
+		}
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+indentLines(content, indent))
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// renderCodeBlock renders a <pre> element as a fenced code block
+func (mc *markdownConverter) renderCodeBlock(n *html.Node) string {
+	return fencedCode(codeText(n), detectCodeLanguage(n))
+}
+
+// renderInlineChildren renders all children of n as inline content
+func (mc *markdownConverter) renderInlineChildren(n *html.Node) string {
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(mc.renderInline(child))
+	}
+	return sb.String()
+}
+
+// renderInline renders a node as inline Markdown
+func (mc *markdownConverter) renderInline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escapeMarkdown(collapseWhitespace(n.Data))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	if isSkippedElement(n) {
+		return ""
+	}
+	if mathKind(n) != "" {
+		// TeX is kept verbatim, without Markdown escaping
+		return nodeText(n)
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "  \n"
+
+	case atom.Strong, atom.B:
+		return wrapInline(mc.renderInlineChildren(n), "**")
+
+	case atom.Em, atom.I:
+		return wrapInline(mc.renderInlineChildren(n), "*")
+
+	case atom.Del, atom.S, atom.Strike:
+		return wrapInline(mc.renderInlineChildren(n), "~~")
+
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		return codeSpan(nodeText(n))
+
+	case atom.A:
+		return mc.renderLink(n)
+
+	case atom.Img:
+		return mc.renderImage(n)
+	}
+
+	text := mc.renderInlineChildren(n)
+	if isBlockElement(n) {
+		// Block inside inline context (e.g. <a><div>...</div></a>)
+		return " " + text + " "
+	}
+	return text
+}
+
+// renderLink renders an anchor as an inline link
+func (mc *markdownConverter) renderLink(n *html.Node) string {
+	text := strings.TrimSpace(collapseWhitespace(mc.renderInlineChildren(n)))
+	href := strings.TrimSpace(getAttr(n, "href"))
+	if text == "" {
+		return ""
+	}
+	if href == "" || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return text
+	}
+	if mc.permalink != "" && href == "#"+mc.permalink && !strings.ContainsFunc(text, isWordRune) {
+		// Permalink icon (¶, #, 🔗) next to a heading that keeps its anchor
+		return ""
+	}
+
+	absolute := mc.absoluteURL(href)
+	href = mc.resolveURL(href)
+	link := "[" + text + "](" + href + ")"
+	if title := getAttr(n, "title"); title != "" {
+		link = "[" + text + "](" + href + " \"" + strings.ReplaceAll(title, "\"", "\\\"") + "\")"
+	}
+	if mc.assets != nil && mc.assets.IsAttachment(absolute) {
+		if assetURL, ok := mc.assets.Accepts(absolute); ok {
+			return mc.addAsset(assetRef{url: assetURL, text: text, fallback: link})
+		}
+	}
+	return link
+}
+
+// renderImage renders an image as a reference to its downloaded copy
+func (mc *markdownConverter) renderImage(n *html.Node) string {
+	if mc.assets == nil || mc.baseURL == nil {
+		return ""
+	}
+	src := strings.TrimSpace(getAttr(n, "src"))
+	if src == "" || strings.HasPrefix(src, "data:") {
+		// Lazy-loading themes keep the real source in data-src
+		src = strings.TrimSpace(getAttr(n, "data-src"))
+	}
+	if src == "" {
+		return ""
+	}
+
+	assetURL, ok := mc.assets.Accepts(mc.absoluteURL(src))
+	if !ok {
+		return ""
+	}
+	alt := strings.NewReplacer("[", `\[`, "]", `\]`).Replace(collapseWhitespace(getAttr(n, "alt")))
+	return mc.addAsset(assetRef{url: assetURL, text: strings.TrimSpace(alt), image: true})
+}
+
+// addAsset records an asset reference for the page and returns its
+// placeholder; the asset is downloaded when the page is kept
+func (mc *markdownConverter) addAsset(ref assetRef) string {
+	if mc.assetRefs == nil {
+		mc.assetRefs = &assetRefs{}
+	}
+	ref.pageURL = mc.baseURL.String()
+	ref.fileDir = mc.fileDir
+	return mc.assetRefs.add(ref)
+}
+
+// absoluteURL resolves href against the page URL without escaping it
+func (mc *markdownConverter) absoluteURL(href string) string {
+	ref, err := url.Parse(href)
+	if err != nil || mc.baseURL == nil {
+		return href
+	}
+	return mc.baseURL.ResolveReference(ref).String()
+}
+
+// resolveURL makes href absolute relative to the page URL; fragments stay local
+func (mc *markdownConverter) resolveURL(href string) string {
+	if strings.HasPrefix(href, "#") || mc.baseURL == nil {
+		return escapeURL(href)
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return escapeURL(href)
+	}
+	return escapeURL(mc.baseURL.ResolveReference(ref).String())
+}
+
+// isSkippedElement reports whether an element never contributes content
+func isSkippedElement(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Head,
+		atom.Iframe, atom.Object, atom.Embed, atom.Canvas,
+		atom.Input, atom.Select, atom.Textarea, atom.Button:
+		return true
+	}
+	return false
+}
+
+// isBlockElement reports whether an element starts a new block
+func isBlockElement(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Address, atom.Article, atom.Aside, atom.Blockquote, atom.Body,
+		atom.Details, atom.Dialog, atom.Dd, atom.Div, atom.Dl, atom.Dt,
+		atom.Fieldset, atom.Figcaption, atom.Figure, atom.Footer, atom.Form,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Header,
+		atom.Hgroup, atom.Hr, atom.Html, atom.Li, atom.Main, atom.Nav,
+		atom.Ol, atom.P, atom.Pre, atom.Section, atom.Summary, atom.Table,
+		atom.Tbody, atom.Td, atom.Tfoot, atom.Th, atom.Thead, atom.Tr, atom.Ul:
+		return true
+	}
+	return false
+}
+
+// joinBlocks joins rendered blocks with blank lines; tight joins attach
+// nested lists directly to the preceding line (used inside list items)
+func joinBlocks(blocks []mdBlock, tight bool) string {
+	var sb strings.Builder
+	for i, block := range blocks {
+		if i > 0 {
+			if tight && block.list {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString("\n\n")
+			}
+		}
+		sb.WriteString(block.text)
+	}
+	return sb.String()
+}
+
+// wrapInline wraps text in emphasis markers, keeping surrounding whitespace outside
+func wrapInline(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	leading := text[:strings.Index(text, trimmed)]
+	trailing := text[len(leading)+len(trimmed):]
+	return leading + marker + trimmed + marker + trailing
+}
+
+// codeSpan renders text as an inline code span
+func codeSpan(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return ""
+	}
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		return fence + " " + text + " " + fence
+	}
+	return fence + text + fence
+}
+
+// nodeText returns the raw text content of a node, skipping non-content elements
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		switch node.Type {
+		case html.TextNode:
+			sb.WriteString(node.Data)
+		case html.ElementNode:
+			if isSkippedElement(node) {
+				return
+			}
+			if node.DataAtom == atom.Br {
+				sb.WriteString("\n")
+				return
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// findAll returns all descendants of n with the given tag, in document order
+func findAll(n *html.Node, tag atom.Atom) []*html.Node {
+	var result []*html.Node
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode && child.DataAtom == tag {
+				result = append(result, child)
+			}
+			walk(child)
+		}
+	}
+	walk(n)
+	return result
+}
+
+// getAttr returns the value of an attribute or an empty string
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// collapseWhitespace replaces runs of whitespace with a single space
+func collapseWhitespace(text string) string {
+	var sb strings.Builder
+	sb.Grow(len(text))
+	space := false
+	for _, r := range text {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			if !space {
+				sb.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		sb.WriteRune(r)
+		space = false
+	}
+	return sb.String()
+}
+
+// escapeMarkdown escapes characters that would otherwise be read as Markdown
+// syntax or, for <, as raw HTML
+func escapeMarkdown(text string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		"`", "\\`",
+		"*", `\*`,
+		"[", `\[`,
+		"]", `\]`,
+		"<", `\<`,
+	)
+	return escapeUnderscores(replacer.Replace(text))
+}
+
+// escapeUnderscores escapes the runs of underscores that could open emphasis.
+// A run after a letter or digit (snake_case, the end of __init__) or before
+// whitespace cannot, and closing runs are literal without an opener, so
+// identifiers keep as few backslashes as possible
+func escapeUnderscores(text string) string {
+	if !strings.Contains(text, "_") {
+		return text
+	}
+	runes := []rune(text)
+	var sb strings.Builder
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '_' {
+			sb.WriteRune(runes[i])
+			continue
+		}
+		end := i
+		for end < len(runes) && runes[end] == '_' {
+			end++
+		}
+		afterWord := i > 0 && isWordRune(runes[i-1])
+		beforeSpace := end < len(runes) && unicode.IsSpace(runes[end])
+		for ; i < end; i++ {
+			if !afterWord && !beforeSpace {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte('_')
+		}
+		i--
+	}
+	return sb.String()
+}
+
+// escapeURL escapes characters that would terminate a Markdown link destination
+func escapeURL(href string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(href)
+}
+
+// cleanInline trims inline content and collapses the spaces left between elements
+func cleanInline(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		hardBreak := strings.HasSuffix(line, "  ") && i < len(lines)-1
+		line = strings.TrimSpace(collapseWhitespace(line))
+		if hardBreak && line != "" {
+			line += "  "
+		}
+		lines[i] = line
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// cleanParagraph cleans a run of inline content and guards against
+// lines that would be misread as a block marker
+func cleanParagraph(text string) string {
+	text = cleanInline(text)
+	if text == "" {
+		return ""
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if blockMarkerRe.MatchString(line) {
+			lines[i] = `\` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// prefixLines prefixes every line of text, using emptyPrefix for blank lines
+func prefixLines(text, prefix, emptyPrefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = emptyPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indentLines indents every line after the first
+func indentLines(text, indent string) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// normalizeMarkdown collapses runs of blank lines and trims the document.
+// Blank lines inside fenced code are part of the code and are kept.
+func normalizeMarkdown(text string) string {
+	var out []string
+	var blanks []string
+	fence := ""
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" && trimmed == "" {
+			blanks = append(blanks, line)
+			continue
+		}
+		if len(blanks) > 1 {
+			out = append(out, "")
+		} else {
+			out = append(out, blanks...)
+		}
+		blanks = nil
+		out = append(out, line)
+
+		fence = nextFence(line, fence)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// fenceLine strips the indentation and blockquote markers from a line, so
+// fences inside blockquotes and callouts (> ```go) are recognised too
+func fenceLine(line string) string {
+	trimmed := strings.TrimSpace(line)
+	for strings.HasPrefix(trimmed, ">") {
+		trimmed = strings.TrimSpace(trimmed[1:])
+	}
+	return trimmed
+}
+
+// nextFence tracks fenced code across lines: given the fence open before
+// line ("" outside code), it returns the fence open after it
+func nextFence(line, fence string) string {
+	trimmed := fenceLine(line)
+	switch {
+	case fence != "":
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, "`") == "" {
+			return ""
+		}
+		return fence
+	case strings.HasPrefix(trimmed, "```"):
+		return trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, "`"))]
+	}
+	return ""
+}
+
+// validateMarkdown checks if converted Markdown is worth saving
+func validateMarkdown(markdown string) ContentValidation {
+	cleaned := normalizeMarkdown(markdown)
+	contentLength := len(cleaned)
+
+	return ContentValidation{
+		ContentLength:  contentLength,
+		CleanedContent: cleaned,
+		IsValid:        contentLength >= minContentLength,
+		HasMeaningful:  contentLength >= minContentLength,
+	}
+}
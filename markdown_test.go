@@ -0,0 +1,393 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// convertHTML converts an HTML fragment using the page URL as link base
+func convertHTML(t *testing.T, fragment string) string {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + fragment + "</body></html>"))
+	if err != nil {
+		t.Fatalf("failed to parse HTML: %v", err)
+	}
+	base, _ := url.Parse("https://docs.example.com/guide/intro")
+	return newMarkdownConverter(base).Convert(doc.Find("body"))
+}
+
+func TestMarkdownConverter(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Headings and paragraphs",
+			input:    "<h1>Title</h1><p>First   paragraph.</p><h3>Sub</h3><p>Second.</p>",
+			expected: "# Title\n\nFirst paragraph.\n\n### Sub\n\nSecond.",
+		},
+		{
+			name:     "Emphasis and inline code",
+			input:    "<p>Use <strong>bold</strong>, <em>italic </em>and <code>go run</code>.</p>",
+			expected: "Use **bold**, *italic* and `go run`.",
+		},
+		{
+			name:     "Relative links resolved",
+			input:    `<p>See <a href="../api/">the API</a> or <a href="#setup">setup</a>.</p>`,
+			expected: "See [the API](https://docs.example.com/api/) or [setup](#setup).",
+		},
+		{
+			name:     "Nested lists",
+			input:    "<ul><li>One<ul><li>Nested</li></ul></li><li>Two</li></ul><ol start=\"3\"><li>Three</li></ol>",
+			expected: "- One\n  - Nested\n- Two\n\n3. Three",
+		},
+		{
+			name:     "Blockquote",
+			input:    "<blockquote><p>Quoted</p><p>Twice</p></blockquote>",
+			expected: "> Quoted\n>\n> Twice",
+		},
+		{
+			name:     "Fenced code keeps whitespace",
+			input:    "<pre><code>func main() {\n\tfmt.Println(\"hi\")\n}</code></pre>",
+			expected: "```\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```",
+		},
+		{
+			name:     "Blank lines in fenced code kept",
+			input:    "<p>One</p><p></p><p></p><pre><code>a = 1\n\n\n\nb = 2</code></pre>",
+			expected: "One\n\n```\na = 1\n\n\n\nb = 2\n```",
+		},
+		{
+			name:     "Markdown syntax in text escaped",
+			input:    "<p>&lt;div&gt; and snake_case [x] *y*</p><p># not a heading<br>&gt; not a quote</p>",
+			expected: "\\<div> and snake_case \\[x\\] \\*y\\*\n\n\\# not a heading  \n\\> not a quote",
+		},
+		{
+			name:     "Underscores escaped only at word boundaries",
+			input:    "<p>Call my_func or __init__, not _this_ or a _ b</p>",
+			expected: "Call my_func or \\_\\_init__, not \\_this_ or a _ b",
+		},
+		{
+			name:     "Code span in link not escaped",
+			input:    `<p><a href="/ref#a"><code>a[0]</code> and b[1]</a></p>`,
+			expected: "[`a[0]` and b\\[1\\]](https://docs.example.com/ref#a)",
+		},
+		{
+			name:     "Scripts and styles dropped",
+			input:    "<style>.a { color: red; }</style><p>Text</p><script>var x = 1;</script>",
+			expected: "Text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := convertHTML(t, tt.input)
+			if result != tt.expected {
+				t.Errorf("Convert() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Prism language class",
+			input:    `<pre class="line-numbers"><code class="language-go">x := 1<span class="line-numbers-rows"><span></span></span></code></pre>`,
+			expected: "```go\nx := 1\n```",
+		},
+		{
+			name:     "highlight.js lang class with copy button",
+			input:    `<div class="code"><button class="copy">Copy</button><pre><code class="hljs lang-js">a: b;</code></pre></div>`,
+			expected: "```js\na: b;\n```",
+		},
+		{
+			name: "Pygments table with line numbers",
+			input: `<div class="highlight-python notranslate"><table class="highlighttable"><tr>` +
+				`<td class="linenos"><div class="linenodiv"><pre>1` + "\n" + `2</pre></div></td>` +
+				`<td class="code"><div class="highlight"><pre>import os` + "\n" + `print(os.sep)</pre></div></td></tr></table></div>`,
+			expected: "```python\nimport os\nprint(os.sep)\n```",
+		},
+		{
+			name:     "Shiki per-line elements",
+			input:    `<div class="language-ts"><pre class="shiki"><code><div class="line">let a = 1</div><div class="line">let b = 2</div></code></pre></div>`,
+			expected: "```ts\nlet a = 1\nlet b = 2\n```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + tt.input + "</body></html>"))
+			if err != nil {
+				t.Fatalf("failed to parse HTML: %v", err)
+			}
+			body := doc.Find("body")
+			prepareCodeBlocks(body)
+			result := newMarkdownConverter(nil).Convert(body)
+			if result != tt.expected {
+				t.Errorf("Convert() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+
+	t.Run("Protected from text cleaning", func(t *testing.T) {
+		doc, _ := goquery.NewDocumentFromReader(strings.NewReader(
+			`<html><body><p>Set the option. Then run it.</p><pre class="language-css">body { color: red; }</pre></body></html>`))
+		body := doc.Find("body")
+		blocks := protectCodeBlocks(body)
+		result := restoreCodeBlocks(cleanHTMLOptimized(body.Text()), blocks)
+		if !strings.Contains(result, "```css\nbody { color: red; }\n```") {
+			t.Errorf("code block not preserved: %q", result)
+		}
+	})
+
+	t.Run("Blank lines kept on restore", func(t *testing.T) {
+		doc, _ := goquery.NewDocumentFromReader(strings.NewReader(
+			"<html><body><p>Intro</p><pre>a = 1\n\n\n\nb = 2</pre></body></html>"))
+		body := doc.Find("body")
+		blocks := protectCodeBlocks(body)
+		result := restoreCodeBlocks(cleanHTMLOptimized(body.Text()), blocks)
+		if !strings.Contains(result, "```\na = 1\n\n\n\nb = 2\n```") {
+			t.Errorf("blank lines collapsed: %q", result)
+		}
+	})
+}
+
+func TestTables(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name: "Header row with code and links",
+			input: `<table><thead><tr><th>Option</th><th align="right">Default</th></tr></thead>` +
+				`<tbody><tr><td><a href="/opts#port">port</a></td><td><code>8080</code></td></tr>` +
+				`<tr><td>mode</td><td><code>a|b</code></td></tr></tbody></table>`,
+			expected: "| Option | Default |\n| --- | ---: |\n| [port](https://docs.example.com/opts#port) | `8080` |\n| mode | `a\\|b` |",
+		},
+		{
+			name:     "No header row",
+			input:    `<table><tr><td>a</td><td>b</td></tr></table>`,
+			expected: "|  |  |\n| --- | --- |\n| a | b |",
+		},
+		{
+			name: "Colspan and rowspan",
+			input: `<table><tr><th colspan="2">Version</th><th>Notes</th></tr>` +
+				`<tr><td rowspan="2">1.x</td><td>1.0</td><td>first</td></tr>` +
+				`<tr><td>1.1</td><td>second</td></tr></table>`,
+			expected: "| Version |  | Notes |\n| --- | --- | --- |\n| 1.x | 1.0 | first |\n|  | 1.1 | second |",
+		},
+		{
+			name:     "Multi-line code falls back to HTML",
+			input:    "<table><tr><th>Example</th></tr><tr><td><pre>a\nb</pre></td></tr></table>",
+			expected: "<table><tbody><tr><th>Example</th></tr><tr><td><pre>a\nb</pre></td></tr></tbody></table>",
+		},
+		{
+			name:     "Single-cell layout table",
+			input:    `<table><tr><td><p>Just content</p></td></tr></table>`,
+			expected: "Just content",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := convertHTML(t, tt.input)
+			if result != tt.expected {
+				t.Errorf("Convert() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAdmonitions(t *testing.T) {
+	tests := []struct {
+		name     string
+		style    string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Sphinx warning",
+			input:    `<div class="admonition warning"><p class="admonition-title">Warning</p><p>Back up first.</p></div>`,
+			expected: "> [!WARNING]\n> Back up first.",
+		},
+		{
+			name:     "Custom title kept",
+			input:    `<div class="admonition note"><p class="admonition-title">Before you start</p><p>Install Go.</p></div>`,
+			expected: "> [!NOTE]\n> **Before you start**\n>\n> Install Go.",
+		},
+		{
+			name: "Docusaurus v3",
+			input: `<div class="theme-admonition theme-admonition-danger alert alert--danger admonition_xJq3">` +
+				`<div class="admonitionHeading_Gvgb"><span class="admonitionIcon_Rf37"><svg></svg></span>danger</div>` +
+				`<div class="admonitionContent_BuS1"><p>This deletes data.</p></div></div>`,
+			expected: "> [!CAUTION]\n> This deletes data.",
+		},
+		{
+			name:     "MkDocs collapsible",
+			input:    `<details class="tip"><summary>Shortcut</summary><p>Press <code>Ctrl</code>.</p></details>`,
+			expected: "> [!TIP]\n> **Shortcut**\n>\n> Press `Ctrl`.",
+		},
+		{
+			name:     "GitBook hint",
+			input:    `<div class="hint" data-hint-style="info"><p>Read the guide.</p><p>Twice.</p></div>`,
+			expected: "> [!NOTE]\n> Read the guide.\n>\n> Twice.",
+		},
+		{
+			name:     "Unknown type is a note",
+			input:    `<div class="admonition admonition-my-custom"><p>Custom.</p></div>`,
+			expected: "> [!NOTE]\n> Custom.",
+		},
+		{
+			name:     "Blockquote style",
+			style:    AdmonitionBlockquote,
+			input:    `<div class="admonition important"><p class="admonition-title">Heads up</p><p>Read this.</p></div>`,
+			expected: "> **Important: Heads up**\n>\n> Read this.",
+		},
+		{
+			name:     "None style",
+			style:    AdmonitionNone,
+			input:    `<div class="admonition warning"><p class="admonition-title">Warning</p><p>Back up first.</p></div>`,
+			expected: "Warning\n\nBack up first.",
+		},
+		{
+			name:     "Code inside a callout",
+			input:    "<div class=\"admonition note\"><p>Run:</p><pre><code class=\"language-go\">a := 1\n\n\nb := 2</code></pre></div>",
+			expected: "> [!NOTE]\n> Run:\n>\n> ```go\n> a := 1\n>\n>\n> b := 2\n> ```",
+		},
+		{
+			name:     "Plain details untouched",
+			input:    `<details><summary>More</summary><p>Hidden text.</p></details>`,
+			expected: "More\n\nHidden text.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + tt.input + "</body></html>"))
+			if err != nil {
+				t.Fatalf("failed to parse HTML: %v", err)
+			}
+			converter := newMarkdownConverter(nil)
+			converter.admonitions = tt.style
+			if result := converter.Convert(doc.Find("body")); result != tt.expected {
+				t.Errorf("Convert() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMath(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name: "KaTeX inline",
+			input: `<p>Energy <span class="katex"><span class="katex-mathml"><math><semantics><mrow><mi>E</mi></mrow>` +
+				`<annotation encoding="application/x-tex">E = mc^2</annotation></semantics></math></span>` +
+				`<span class="katex-html" aria-hidden="true"><span class="mord">E</span><span class="mrel">=</span></span></span> holds.</p>`,
+			expected: "Energy $E = mc^2$ holds.",
+		},
+		{
+			name: "KaTeX display",
+			input: `<p>Sum:</p><p><span class="katex-display"><span class="katex"><span class="katex-mathml"><math display="block"><semantics>` +
+				`<annotation encoding="application/x-tex">\sum_{i=1}^n x_i</annotation></semantics></math></span>` +
+				`<span class="katex-html">∑xi</span></span></span></p>`,
+			expected: "Sum:\n\n$$\n\\sum_{i=1}^n x_i\n$$",
+		},
+		{
+			name: "MathJax 2 scripts",
+			input: `<p>Let <span class="MathJax_Preview">a*b</span><span class="MathJax" id="MathJax-Element-1-Frame">a∗b</span>` +
+				`<script type="math/tex" id="MathJax-Element-1">a * b</script> be given.</p>` +
+				`<div class="MathJax_Display"><span class="MathJax">x</span></div><script type="math/tex; mode=display">x^2</script>`,
+			expected: "Let $a * b$ be given.\n\n$$\nx^2\n$$",
+		},
+		{
+			name:     "MathML alttext",
+			input:    `<p>Ratio <math alttext="\frac{a}{b}"><mfrac><mi>a</mi><mi>b</mi></mfrac></math>.</p>`,
+			expected: "Ratio $\\frac{a}{b}$.",
+		},
+		{
+			name:     "Sphinx source before MathJax",
+			input:    `<p>Where <span class="math notranslate nohighlight">\(\alpha_1\)</span> is fixed.</p><div class="math notranslate nohighlight">\[f(x) = \alpha x\]</div>`,
+			expected: "Where $\\alpha_1$ is fixed.\n\n$$\nf(x) = \\alpha x\n$$",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + tt.input + "</body></html>"))
+			if err != nil {
+				t.Fatalf("failed to parse HTML: %v", err)
+			}
+			body := doc.Find("body")
+			prepareMath(body)
+			if result := newMarkdownConverter(nil).Convert(body); result != tt.expected {
+				t.Errorf("Convert() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHeadingAnchors(t *testing.T) {
+	input := `<h1 id="intro">Intro</h1><p>Text.</p>` +
+		`<section id="install"><h2>Install<a class="headerlink" href="#install">¶</a></h2><p>Run it.</p></section>` +
+		`<a name="usage"></a><h3>Usage</h3><h3>No anchor</h3>`
+
+	tests := []struct {
+		style    string
+		expected string
+	}{
+		{
+			style:    HeadingIDsAttr,
+			expected: "# Intro {#intro}\n\nText.\n\n## Install {#install}\n\nRun it.\n\n### Usage {#usage}\n\n### No anchor",
+		},
+		{
+			style:    HeadingIDsHTML,
+			expected: "<a id=\"intro\"></a>\n\n# Intro\n\nText.\n\n<a id=\"install\"></a>\n\n## Install\n\nRun it.\n\n<a id=\"usage\"></a>\n\n### Usage\n\n### No anchor",
+		},
+		{
+			style:    HeadingIDsNone,
+			expected: "# Intro\n\nText.\n\n## Install[¶](#install)\n\nRun it.\n\n### Usage\n\n### No anchor",
+		},
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + input + "</body></html>"))
+	if err != nil {
+		t.Fatalf("failed to parse HTML: %v", err)
+	}
+	body := doc.Find("body")
+
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			converter := newMarkdownConverter(nil)
+			converter.headingIDs = tt.style
+			if result := converter.Convert(body); result != tt.expected {
+				t.Errorf("Convert() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+
+	t.Run("outline", func(t *testing.T) {
+		expected := []HeadingInfo{
+			{Level: 1, Text: "Intro", Anchor: "intro"},
+			{Level: 2, Text: "Install", Anchor: "install"},
+			{Level: 3, Text: "Usage", Anchor: "usage"},
+			{Level: 3, Text: "No anchor"},
+		}
+		if outline := extractOutline(body); !reflect.DeepEqual(outline, expected) {
+			t.Errorf("extractOutline() = %+v, want %+v", outline, expected)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// mathAttr marks the elements prepareMath leaves in place of rendered math;
+// the value is "inline" or "display" and the text is the delimited TeX
+const mathAttr = "data-crawldocs-math"
+
+// mathJaxChrome matches the output MathJax 2 renders next to its source scripts
+const mathJaxChrome = ".MathJax_Preview, span.MathJax, div.MathJax_Display, .MathJax_SVG, " +
+	".MathJax_SVG_Display, .MathJax_CHTML, .MathJax_MathML"
+
+var (
+	inlineMathRe  = regexp.MustCompile(`(?s)^\\\((.*)\\\)$`)
+	displayMathRe = regexp.MustCompile(`(?s)^(?:\\\[(.*)\\\]|\$\$(.*)\$\$)$`)
+)
+
+// prepareMath replaces KaTeX, MathJax and MathML markup with its TeX source,
+// so the rendered glyphs and the duplicate MathML aren't both kept as text.
+// Unrendered sources (Sphinx and arithmatex \(...\) spans) are normalized too.
+func prepareMath(root *goquery.Selection) {
+	// KaTeX: display wrappers first, so their inner .katex isn't seen as inline
+	root.Find(".katex-display").Each(func(i int, s *goquery.Selection) {
+		replaceMath(s, texAnnotation(s), true)
+	})
+	root.Find(".katex").Each(func(i int, s *goquery.Selection) {
+		replaceMath(s, texAnnotation(s), false)
+	})
+
+	// MathJax 2 keeps the source in script tags next to the rendered output
+	scripts := root.Find("script[type^='math/tex']")
+	if scripts.Length() > 0 {
+		root.Find(mathJaxChrome).Remove()
+		scripts.Each(func(i int, s *goquery.Selection) {
+			replaceMath(s, s.Text(), strings.Contains(s.AttrOr("type", ""), "mode=display"))
+		})
+	}
+
+	// MathJax 3 and plain MathML
+	root.Find("mjx-container").Each(func(i int, s *goquery.Selection) {
+		replaceMath(s, texAnnotation(s), s.AttrOr("display", "") == "true")
+	})
+	root.Find("math").Each(func(i int, s *goquery.Selection) {
+		replaceMath(s, texAnnotation(s), s.AttrOr("display", "") == "block")
+	})
+
+	// Sphinx and pymdownx.arithmatex before MathJax runs
+	root.Find(".math, .arithmatex").Each(func(i int, s *goquery.Selection) {
+		if s.Find("["+mathAttr+"]").Length() > 0 {
+			return
+		}
+		text := strings.TrimSpace(s.Text())
+		if match := displayMathRe.FindStringSubmatch(text); match != nil {
+			replaceMath(s, match[1]+match[2], true)
+		} else if match := inlineMathRe.FindStringSubmatch(text); match != nil {
+			replaceMath(s, match[1], false)
+		}
+	})
+}
+
+// texAnnotation returns the TeX source kept in a MathML annotation or alttext
+func texAnnotation(s *goquery.Selection) string {
+	if annotation := s.Find(`annotation[encoding="application/x-tex"]`).First(); annotation.Length() > 0 {
+		return annotation.Text()
+	}
+	if s.Is("math") {
+		return s.AttrOr("alttext", "")
+	}
+	return s.Find("math[alttext]").First().AttrOr("alttext", "")
+}
+
+// replaceMath swaps a math element for its TeX source. Elements without a
+// source are left alone.
+func replaceMath(s *goquery.Selection, tex string, display bool) {
+	tex = strings.TrimSpace(tex)
+	if tex == "" {
+		return
+	}
+	if display {
+		s.ReplaceWithHtml(`<span ` + mathAttr + `="display">$$` + html.EscapeString(tex) + `$$</span>`)
+	} else {
+		s.ReplaceWithHtml(`<span ` + mathAttr + `="inline">$` + html.EscapeString(tex) + `$</span>`)
+	}
+}
+
+// mathKind returns "inline" or "display" for elements left by prepareMath
+func mathKind(n *html.Node) string {
+	if n.Type != html.ElementNode {
+		return ""
+	}
+	return getAttr(n, mathAttr)
+}
+
+// displayMathBlock renders display math as a $$ block on its own lines
+func displayMathBlock(n *html.Node) string {
+	tex := strings.TrimSuffix(strings.TrimPrefix(nodeText(n), "$$"), "$$")
+	return "$$\n" + strings.TrimSpace(tex) + "\n$$"
+}
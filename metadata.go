@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Metadata keys with a fixed meaning; OpenGraph and Twitter card fields are
+// stored under their own property names (og:title, twitter:card, ...)
+const (
+	MetaDescription   = "description"
+	MetaKeywords      = "keywords"
+	MetaAuthor        = "author"
+	MetaPublishedTime = "published_time"
+	MetaModifiedTime  = "modified_time"
+	MetaLanguage      = "language"
+	MetaCanonical     = "canonical"
+	MetaJSONLD        = "jsonld"
+)
+
+// extractContentMetadata extracts page metadata from meta tags, the canonical
+// link, the document language and JSON-LD blocks. Fields missing from meta
+// tags are filled in from OpenGraph and JSON-LD where possible.
+func extractContentMetadata(doc *goquery.Selection, resolve func(string) string) map[string]string {
+	metadata := make(map[string]string)
+	set := func(key, value string) {
+		value = strings.TrimSpace(value)
+		if value != "" && metadata[key] == "" {
+			metadata[key] = value
+		}
+	}
+
+	doc.Find("meta[content]").Each(func(i int, s *goquery.Selection) {
+		content := s.AttrOr("content", "")
+		name := strings.ToLower(s.AttrOr("name", ""))
+		property := strings.ToLower(s.AttrOr("property", ""))
+		itemprop := strings.ToLower(s.AttrOr("itemprop", ""))
+		httpEquiv := strings.ToLower(s.AttrOr("http-equiv", ""))
+
+		switch {
+		case name == "description":
+			set(MetaDescription, content)
+		case name == "keywords":
+			set(MetaKeywords, content)
+		case name == "author":
+			set(MetaAuthor, content)
+		case property == "article:published_time" || itemprop == "datepublished":
+			set(MetaPublishedTime, content)
+		case property == "article:modified_time" || itemprop == "datemodified":
+			set(MetaModifiedTime, content)
+		case httpEquiv == "content-language":
+			set(MetaLanguage, content)
+		case strings.HasPrefix(property, "og:") || strings.HasPrefix(property, "article:"):
+			set(property, content)
+		case strings.HasPrefix(name, "twitter:") || strings.HasPrefix(property, "twitter:"):
+			set(name+property, content)
+		}
+	})
+
+	if lang := doc.AttrOr("lang", ""); lang != "" {
+		metadata[MetaLanguage] = strings.TrimSpace(lang)
+	} else {
+		set(MetaLanguage, doc.Find("html").AttrOr("lang", ""))
+	}
+
+	if href := strings.TrimSpace(doc.Find("link[rel='canonical']").AttrOr("href", "")); href != "" {
+		set(MetaCanonical, resolve(href))
+	}
+
+	// JSON-LD objects, with @graph containers flattened
+	var objects []map[string]interface{}
+	doc.Find("script[type='application/ld+json']").Each(func(i int, s *goquery.Selection) {
+		objects = append(objects, parseJSONLD(s.Text())...)
+	})
+	if len(objects) > 0 {
+		if data, err := json.Marshal(objects); err == nil {
+			metadata[MetaJSONLD] = string(data)
+		}
+		for _, obj := range objects {
+			set(MetaAuthor, jsonLDString(obj["author"]))
+			set(MetaPublishedTime, jsonLDString(obj["datePublished"]))
+			set(MetaModifiedTime, jsonLDString(obj["dateModified"]))
+			set(MetaDescription, jsonLDString(obj["description"]))
+			set(MetaKeywords, jsonLDString(obj["keywords"]))
+		}
+	}
+
+	// OpenGraph and Twitter fallbacks
+	set(MetaDescription, metadata["og:description"])
+	set(MetaDescription, metadata["twitter:description"])
+	set(MetaAuthor, metadata["article:author"])
+	set(MetaModifiedTime, metadata["og:updated_time"])
+	set(MetaCanonical, metadata["og:url"])
+
+	return metadata
+}
+
+// parseJSONLD decodes a JSON-LD block into its top-level objects
+func parseJSONLD(text string) []map[string]interface{} {
+	var data interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &data); err != nil {
+		return nil
+	}
+
+	var objects []map[string]interface{}
+	var collect func(interface{})
+	collect = func(v interface{}) {
+		switch value := v.(type) {
+		case []interface{}:
+			for _, item := range value {
+				collect(item)
+			}
+		case map[string]interface{}:
+			if graph, ok := value["@graph"]; ok {
+				collect(graph)
+				return
+			}
+			objects = append(objects, value)
+		}
+	}
+	collect(data)
+
+	return objects
+}
+
+// jsonLDString reads a JSON-LD value as text: strings as-is, objects by their
+// name, arrays as a comma-separated list
+func jsonLDString(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case map[string]interface{}:
+		if name, ok := value["name"].(string); ok {
+			return name
+		}
+	case []interface{}:
+		var parts []string
+		for _, item := range value {
+			if text := jsonLDString(item); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, ", ")
+	}
+	return ""
+}
+
+// metaFilter restricts saved pages by a metadata field
+type metaFilter struct {
+	key    string
+	negate bool
+	re     *regexp.Regexp
+}
+
+// parseMetaFilter parses "key=regex" (field must match) or "key!=regex" (field must not match)
+func parseMetaFilter(spec string) (metaFilter, error) {
+	negate := false
+	idx := strings.Index(spec, "!=")
+	if idx > 0 {
+		negate = true
+	} else {
+		idx = strings.Index(spec, "=")
+	}
+	if idx <= 0 {
+		return metaFilter{}, fmt.Errorf("invalid metadata filter %q (expected key=regex or key!=regex)", spec)
+	}
+
+	key := strings.TrimSpace(spec[:idx])
+	pattern := spec[idx+1:]
+	if negate {
+		pattern = spec[idx+2:]
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return metaFilter{}, fmt.Errorf("invalid metadata filter %q: %w", spec, err)
+	}
+	return metaFilter{key: key, negate: negate, re: re}, nil
+}
+
+// Allows reports whether a page's metadata passes the filter
+func (f metaFilter) Allows(metadata map[string]string) bool {
+	return f.re.MatchString(metadata[f.key]) != f.negate
+}
+
+// String renders the filter as it was given on the command line
+func (f metaFilter) String() string {
+	if f.negate {
+		return f.key + "!=" + f.re.String()
+	}
+	return f.key + "=" + f.re.String()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractContentMetadata(t *testing.T) {
+	page := `<html lang="en-US"><head>
+		<meta name="keywords" content="api, sdk">
+		<meta property="og:description" content="OG description">
+		<meta property="og:type" content="article">
+		<meta property="article:published_time" content="2024-01-02T03:04:05Z">
+		<meta name="twitter:card" content="summary">
+		<link rel="canonical" href="/docs/page">
+		<script type="application/ld+json">
+			{"@context": "https://schema.org", "@graph": [
+				{"@type": "WebSite", "name": "Docs"},
+				{"@type": "TechArticle", "author": [{"@type": "Person", "name": "Ada"}], "dateModified": "2024-02-01"}
+			]}
+		</script>
+	</head><body></body></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("failed to parse HTML: %v", err)
+	}
+	resolve := func(href string) string { return "https://example.com" + href }
+	metadata := extractContentMetadata(doc.Find("html"), resolve)
+
+	expected := map[string]string{
+		MetaDescription:   "OG description",
+		MetaKeywords:      "api, sdk",
+		MetaAuthor:        "Ada",
+		MetaPublishedTime: "2024-01-02T03:04:05Z",
+		MetaModifiedTime:  "2024-02-01",
+		MetaLanguage:      "en-US",
+		MetaCanonical:     "https://example.com/docs/page",
+		"og:type":         "article",
+		"twitter:card":    "summary",
+	}
+	for key, want := range expected {
+		if got := metadata[key]; got != want {
+			t.Errorf("metadata[%q] = %q, want %q", key, got, want)
+		}
+	}
+	if !strings.Contains(metadata[MetaJSONLD], `"@type":"TechArticle"`) || strings.Contains(metadata[MetaJSONLD], "@graph") {
+		t.Errorf("JSON-LD graph not flattened: %s", metadata[MetaJSONLD])
+	}
+
+	filter, err := parseMetaFilter("og:type!=^website$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filter.Allows(metadata) {
+		t.Errorf("filter %s should allow og:type=article", filter)
+	}
+}
@@ -0,0 +1,1125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Metadata keys of pages rendered from OpenAPI specs
+const (
+	MetaOpenAPISpec = "openapi_spec"
+	MetaAPIVersion  = "api_version"
+)
+
+const (
+	// maxOpenAPISpecSize caps how much of an OpenAPI spec is read
+	maxOpenAPISpecSize = 32 * 1024 * 1024
+	// maxSchemaDepth limits how deeply nested schema properties are listed
+	maxSchemaDepth = 4
+)
+
+// openAPIMethods are the operations a path item can hold, in display order
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+var (
+	// openAPIPathRe matches the file names specs are usually published under,
+	// including Springdoc's /v3/api-docs
+	openAPIPathRe = regexp.MustCompile(`(?i)(^|/)(openapi|swagger)[\w.-]*\.(json|ya?ml)$|/api-docs(\.json|\.ya?ml)?$`)
+	redocInitRe   = regexp.MustCompile(`Redoc\.init\(\s*["']([^"']+)["']`)
+	swaggerURLRe  = regexp.MustCompile(`\burl\s*:\s*["']([^"']+)["']`)
+)
+
+// openAPISpec is a parsed OpenAPI 3 or Swagger 2 document
+type openAPISpec struct {
+	URL  string
+	root *specNode
+}
+
+// openAPIPage is a Markdown page rendered from a spec. Fragment follows
+// Redoc's deep links: tag/<name> or operation/<operationId>.
+type openAPIPage struct {
+	Fragment string
+	Title    string
+	Markdown string
+	Outline  []HeadingInfo
+}
+
+// openAPIOperation is one method of a path
+type openAPIOperation struct {
+	Method string
+	Path   string
+	Tags   []string
+	node   *specNode
+	item   *specNode // the path item, for parameters shared by its operations
+}
+
+// openAPILoader finds the OpenAPI specs API portals render with Redoc,
+// Swagger UI and similar viewers, and fetches each of them once
+type openAPILoader struct {
+	client    *http.Client
+	userAgent string
+	domain    string
+	verbose   bool
+
+	mu      sync.Mutex
+	fetched map[string]bool // spec and script URLs already tried
+}
+
+// newOpenAPILoader creates a loader for specs on the crawl's domain
+func newOpenAPILoader(client *http.Client, domain string, config CrawlConfig) *openAPILoader {
+	return &openAPILoader{
+		client:    client,
+		userAgent: config.UserAgent,
+		domain:    domain,
+		verbose:   config.Verbose,
+		fetched:   make(map[string]bool),
+	}
+}
+
+// Discover returns the specs a page embeds or points at that haven't been
+// loaded yet
+func (l *openAPILoader) Discover(doc *goquery.Selection, pageURL *url.URL) []*openAPISpec {
+	var specs []*openAPISpec
+	if spec := embeddedOpenAPISpec(doc, pageURL); spec != nil && l.claim(spec.URL) {
+		specs = append(specs, spec)
+	}
+
+	for _, specURL := range l.specURLs(doc, pageURL) {
+		if !l.claim(specURL) {
+			continue
+		}
+		data, err := l.fetch(specURL)
+		if err == nil {
+			var spec *openAPISpec
+			if spec, err = parseOpenAPISpec(data, specURL); err == nil {
+				specs = append(specs, spec)
+				continue
+			}
+		}
+		if l.verbose {
+			logDim("No OpenAPI spec at %s: %v", specURL, err)
+		}
+	}
+	return specs
+}
+
+// claim marks a spec or script URL as tried, returning false if it already was
+func (l *openAPILoader) claim(rawURL string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.fetched[rawURL] {
+		return false
+	}
+	l.fetched[rawURL] = true
+	return true
+}
+
+// specURLs lists the spec URLs a page points at: the spec-url of Redoc and
+// RapiDoc elements, the spec passed to Redoc.init or Swagger UI, and links
+// to files named like specs
+func (l *openAPILoader) specURLs(doc *goquery.Selection, pageURL *url.URL) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	add := func(ref string) {
+		ref = strings.TrimSpace(ref)
+		if ref == "" || strings.HasPrefix(ref, "#") {
+			return
+		}
+		resolved := resolveReference(pageURL, ref)
+		if parsed, err := url.Parse(resolved); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return
+		}
+		if !seen[resolved] {
+			seen[resolved] = true
+			urls = append(urls, resolved)
+		}
+	}
+
+	doc.Find("[spec-url], [apidescriptionurl]").Each(func(i int, s *goquery.Selection) {
+		add(s.AttrOr("spec-url", s.AttrOr("apidescriptionurl", "")))
+	})
+
+	scripts := []string{}
+	doc.Find("script").Each(func(i int, s *goquery.Selection) {
+		if src, ok := s.Attr("src"); ok {
+			// Swagger UI's dist keeps its configuration in a separate script
+			if strings.Contains(path.Base(src), "swagger-initializer") {
+				scriptURL := resolveReference(pageURL, src)
+				if scriptURL != "" && l.claim(scriptURL) {
+					if data, err := l.fetch(scriptURL); err == nil {
+						scripts = append(scripts, string(data))
+					}
+				}
+			}
+			return
+		}
+		scripts = append(scripts, s.Text())
+	})
+	for _, script := range scripts {
+		for _, match := range redocInitRe.FindAllStringSubmatch(script, -1) {
+			add(match[1])
+		}
+		if strings.Contains(script, "SwaggerUI") {
+			for _, match := range swaggerURLRe.FindAllStringSubmatch(script, -1) {
+				add(match[1])
+			}
+		}
+	}
+
+	doc.Find("a[href], link[href]").Each(func(i int, s *goquery.Selection) {
+		href := s.AttrOr("href", "")
+		if parsed, err := url.Parse(resolveReference(pageURL, href)); err == nil && openAPIPathRe.MatchString(parsed.Path) {
+			add(href)
+		}
+	})
+	return urls
+}
+
+// fetch downloads a spec or script. Like the pages, they are only fetched
+// from the crawl's domain.
+func (l *openAPILoader) fetch(rawURL string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.Host != l.domain {
+		return nil, fmt.Errorf("not on %s", l.domain)
+	}
+	req.Header.Set("User-Agent", l.userAgent)
+	req.Header.Set("Accept", "application/json, application/yaml;q=0.9, */*;q=0.8")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxOpenAPISpecSize))
+}
+
+// embeddedOpenAPISpec returns the spec a server-rendered Redoc page embeds
+// in its __redoc_state script, or nil
+func embeddedOpenAPISpec(doc *goquery.Selection, pageURL *url.URL) *openAPISpec {
+	var spec *openAPISpec
+	doc.Find("script:not([src])").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		text := s.Text()
+		start := strings.Index(text, "__redoc_state")
+		if start < 0 {
+			return true
+		}
+		rest := strings.TrimLeft(text[start+len("__redoc_state"):], " \t\r\n=")
+
+		var state struct {
+			Spec struct {
+				Data json.RawMessage `json:"data"`
+			} `json:"spec"`
+		}
+		if json.NewDecoder(strings.NewReader(rest)).Decode(&state) != nil {
+			return true
+		}
+		specURL := *pageURL
+		specURL.Fragment = ""
+		if parsed, err := parseOpenAPISpec(state.Spec.Data, specURL.String()); err == nil {
+			spec = parsed
+		}
+		return false
+	})
+	return spec
+}
+
+// openAPIContentType reports whether a response could hold a spec: JSON or
+// YAML, or plain text with a spec's file name
+func openAPIContentType(contentType, urlPath string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	if strings.Contains(mediaType, "json") || strings.Contains(mediaType, "yaml") {
+		return true
+	}
+	return mediaType == "text/plain" && openAPIPathRe.MatchString(urlPath)
+}
+
+// parseOpenAPISpec parses a JSON or YAML document, checking that it is an
+// OpenAPI 3 or Swagger 2 spec
+func parseOpenAPISpec(data []byte, specURL string) (*openAPISpec, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var root *specNode
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		root, err = parseSpecJSON(trimmed)
+	} else {
+		root, err = parseSpecYAML(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec: %w", err)
+	}
+	if root.Get("openapi") == nil && root.Get("swagger") == nil {
+		return nil, fmt.Errorf("not an OpenAPI spec: no openapi or swagger version")
+	}
+	if root.Get("paths") == nil {
+		return nil, fmt.Errorf("not an OpenAPI spec: no paths")
+	}
+	return &openAPISpec{URL: specURL, root: root}, nil
+}
+
+// Title returns the title of the API
+func (s *openAPISpec) Title() string {
+	if title := strings.TrimSpace(s.root.Get("info").Get("title").String()); title != "" {
+		return title
+	}
+	return "API Reference"
+}
+
+// Version returns the version of the API (not of the OpenAPI format)
+func (s *openAPISpec) Version() string {
+	return s.root.Get("info").Get("version").String()
+}
+
+// BaseURL returns the URL the API's paths are relative to, from the first
+// server of an OpenAPI 3 spec or the host and basePath of a Swagger 2 spec
+func (s *openAPISpec) BaseURL() string {
+	specURL, _ := url.Parse(s.URL)
+	if servers := s.root.Get("servers").Items(); len(servers) > 0 {
+		server := servers[0].Get("url").String()
+		if server == "" || specURL == nil {
+			return server
+		}
+		return resolveReference(specURL, server)
+	}
+
+	host := s.root.Get("host").String()
+	basePath := s.root.Get("basePath").String()
+	if s.root.Get("swagger") == nil || (host == "" && basePath == "") {
+		return ""
+	}
+	scheme := "https"
+	if schemes := s.root.Get("schemes").Items(); len(schemes) > 0 {
+		scheme = schemes[0].String()
+	} else if specURL != nil && specURL.Scheme != "" {
+		scheme = specURL.Scheme
+	}
+	if host == "" && specURL != nil {
+		host = specURL.Host
+	}
+	return scheme + "://" + host + basePath
+}
+
+// Operations lists the operations of the spec in the order it declares them
+func (s *openAPISpec) Operations() []*openAPIOperation {
+	var operations []*openAPIOperation
+	paths := s.root.Get("paths")
+	for _, pathName := range paths.Keys() {
+		item := s.resolve(paths.Get(pathName))
+		for _, method := range item.Keys() {
+			if !isOpenAPIMethod(method) {
+				continue
+			}
+			node := item.Get(method)
+			operation := &openAPIOperation{Method: strings.ToUpper(method), Path: pathName, node: node, item: item}
+			for _, tag := range node.Get("tags").Items() {
+				if name := tag.String(); name != "" {
+					operation.Tags = append(operation.Tags, name)
+				}
+			}
+			operations = append(operations, operation)
+		}
+	}
+	return operations
+}
+
+// isOpenAPIMethod reports whether a path item key is an HTTP method
+func isOpenAPIMethod(key string) bool {
+	for _, method := range openAPIMethods {
+		if key == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Pages renders the spec as Markdown: an overview when the API has a
+// description, one page per tag, and one page per operation without a tag.
+// Operations with several tags appear on each of their pages.
+func (s *openAPISpec) Pages() []openAPIPage {
+	var pages []openAPIPage
+	if overview := s.renderOverview(); overview.Markdown != "" {
+		pages = append(pages, overview)
+	}
+
+	operations := s.Operations()
+	tagged := make(map[string][]*openAPIOperation)
+	var tags []string
+	addTag := func(name string) {
+		if _, ok := tagged[name]; !ok {
+			tagged[name] = nil
+			tags = append(tags, name)
+		}
+	}
+	// Declared tags come first, in the order the spec lists them
+	for _, tag := range s.root.Get("tags").Items() {
+		if name := tag.Get("name").String(); name != "" {
+			addTag(name)
+		}
+	}
+	var untagged []*openAPIOperation
+	for _, operation := range operations {
+		if len(operation.Tags) == 0 {
+			untagged = append(untagged, operation)
+		}
+		for _, tag := range operation.Tags {
+			addTag(tag)
+			tagged[tag] = append(tagged[tag], operation)
+		}
+	}
+
+	for _, tag := range tags {
+		if len(tagged[tag]) > 0 {
+			pages = append(pages, s.renderTag(tag, tagged[tag]))
+		}
+	}
+	for _, operation := range untagged {
+		r := &openAPIRenderer{}
+		title := operationTitle(operation)
+		r.heading(1, title)
+		s.renderOperation(r, operation, 2)
+		pages = append(pages, openAPIPage{
+			Fragment: "operation/" + operationID(operation),
+			Title:    title + " - " + s.Title(),
+			Markdown: r.String(),
+			Outline:  r.outline,
+		})
+	}
+	return pages
+}
+
+// renderOverview renders the API description, or nothing if there is none
+func (s *openAPISpec) renderOverview() openAPIPage {
+	description := strings.TrimSpace(s.root.Get("info").Get("description").String())
+	if description == "" {
+		return openAPIPage{}
+	}
+	r := &openAPIRenderer{}
+	r.heading(1, s.Title())
+	r.block(s.versionLine())
+	r.block(description)
+	return openAPIPage{Fragment: "overview", Title: s.Title(), Markdown: r.String(), Outline: r.outline}
+}
+
+// versionLine names the API version and base URL
+func (s *openAPISpec) versionLine() string {
+	var parts []string
+	if version := s.Version(); version != "" {
+		parts = append(parts, "Version: `"+version+"`")
+	}
+	if baseURL := s.BaseURL(); baseURL != "" {
+		parts = append(parts, "Base URL: `"+baseURL+"`")
+	}
+	return strings.Join(parts, "  \n")
+}
+
+// renderTag renders the page of a tag and its operations
+func (s *openAPISpec) renderTag(tag string, operations []*openAPIOperation) openAPIPage {
+	r := &openAPIRenderer{}
+	title := tag
+	var description string
+	for _, declared := range s.root.Get("tags").Items() {
+		if declared.Get("name").String() == tag {
+			if name := declared.Get("x-displayName").String(); name != "" {
+				title = name
+			}
+			description = declared.Get("description").String()
+			break
+		}
+	}
+
+	r.heading(1, title)
+	r.block(description)
+	r.block(s.versionLine())
+	for _, operation := range operations {
+		r.heading(2, operationTitle(operation))
+		s.renderOperation(r, operation, 3)
+	}
+	return openAPIPage{
+		Fragment: "tag/" + url.PathEscape(tag),
+		Title:    title + " - " + s.Title(),
+		Markdown: r.String(),
+		Outline:  r.outline,
+	}
+}
+
+// operationTitle names an operation by its summary, its ID or its method and path
+func operationTitle(operation *openAPIOperation) string {
+	if summary := strings.TrimSpace(operation.node.Get("summary").String()); summary != "" {
+		return summary
+	}
+	if id := operation.node.Get("operationId").String(); id != "" {
+		return id
+	}
+	return operation.Method + " " + operation.Path
+}
+
+// operationID returns the operationId of an operation, or one made from its
+// method and path
+func operationID(operation *openAPIOperation) string {
+	if id := operation.node.Get("operationId").String(); id != "" {
+		return url.PathEscape(id)
+	}
+	return strings.ToLower(operation.Method) + "-" + slugify("http://x/"+operation.Path)
+}
+
+// renderOperation renders an operation's description, parameters, request
+// body and responses, with section headings at level
+func (s *openAPISpec) renderOperation(r *openAPIRenderer, operation *openAPIOperation, level int) {
+	r.block("`" + operation.Method + " " + operation.Path + "`")
+	if operation.node.Get("deprecated").Bool() {
+		r.block("**Deprecated**")
+	}
+	if summary := operation.node.Get("summary").String(); operation.node.Get("description").String() != summary {
+		r.block(operation.node.Get("description").String())
+	}
+
+	// Swagger 2 passes the request body as a parameter
+	var parameters []*specNode
+	var bodyParameter *specNode
+	for _, parameter := range s.parameters(operation) {
+		if parameter.Get("in").String() == "body" {
+			bodyParameter = parameter
+			continue
+		}
+		parameters = append(parameters, parameter)
+	}
+	if len(parameters) > 0 {
+		r.heading(level, "Parameters")
+		rows := [][]string{{"Name", "In", "Type", "Required", "Description"}}
+		for _, parameter := range parameters {
+			schema := parameter.Get("schema")
+			if schema == nil {
+				schema = parameter
+			}
+			rows = append(rows, []string{
+				"`" + parameter.Get("name").String() + "`",
+				parameter.Get("in").String(),
+				s.schemaType(schema, map[string]bool{}),
+				yesNo(parameter.Get("required").Bool()),
+				s.fieldDescription(parameter, schema),
+			})
+		}
+		r.table(rows)
+	}
+
+	if body := s.resolve(operation.node.Get("requestBody")); body != nil {
+		r.heading(level, "Request body")
+		r.block(body.Get("description").String())
+		if mediaType, content := preferredContent(body.Get("content")); content != nil {
+			s.renderContent(r, mediaType, content.Get("schema"), content)
+		}
+	} else if bodyParameter != nil {
+		r.heading(level, "Request body")
+		r.block(bodyParameter.Get("description").String())
+		s.renderContent(r, s.mediaType(operation, "consumes"), bodyParameter.Get("schema"), nil)
+	}
+
+	responses := operation.node.Get("responses")
+	if len(responses.Keys()) == 0 {
+		return
+	}
+	r.heading(level, "Responses")
+	for _, code := range responses.Keys() {
+		response := s.resolve(responses.Get(code))
+		heading := code
+		if status, err := strconv.Atoi(code); err == nil && http.StatusText(status) != "" {
+			heading += " " + http.StatusText(status)
+		}
+		r.heading(level+1, heading)
+		r.block(response.Get("description").String())
+
+		if mediaType, content := preferredContent(response.Get("content")); content != nil {
+			s.renderContent(r, mediaType, content.Get("schema"), content)
+		} else if schema := response.Get("schema"); schema != nil {
+			mediaType := s.mediaType(operation, "produces")
+			var examples *specNode
+			if example := response.Get("examples").Get(mediaType); example != nil {
+				examples = &specNode{kind: specObject, fields: map[string]*specNode{"example": example}, keys: []string{"example"}}
+			}
+			s.renderContent(r, mediaType, schema, examples)
+		}
+	}
+}
+
+// parameters merges the parameters of a path item with those of one of its
+// operations, which override them by name and location
+func (s *openAPISpec) parameters(operation *openAPIOperation) []*specNode {
+	var parameters []*specNode
+	index := make(map[string]int)
+	for _, list := range []*specNode{operation.item.Get("parameters"), operation.node.Get("parameters")} {
+		for _, parameter := range list.Items() {
+			parameter = s.resolve(parameter)
+			key := parameter.Get("in").String() + ":" + parameter.Get("name").String()
+			if i, ok := index[key]; ok {
+				parameters[i] = parameter
+				continue
+			}
+			index[key] = len(parameters)
+			parameters = append(parameters, parameter)
+		}
+	}
+	return parameters
+}
+
+// mediaType returns the first media type a Swagger 2 operation consumes or
+// produces, falling back to the spec's defaults and then JSON
+func (s *openAPISpec) mediaType(operation *openAPIOperation, key string) string {
+	for _, list := range []*specNode{operation.node.Get(key), s.root.Get(key)} {
+		if items := list.Items(); len(items) > 0 {
+			return items[0].String()
+		}
+	}
+	return "application/json"
+}
+
+// preferredContent picks the JSON entry of an OpenAPI 3 content map, or its first entry
+func preferredContent(content *specNode) (string, *specNode) {
+	keys := content.Keys()
+	for _, mediaType := range keys {
+		if strings.Contains(mediaType, "json") {
+			return mediaType, content.Get(mediaType)
+		}
+	}
+	if len(keys) > 0 {
+		return keys[0], content.Get(keys[0])
+	}
+	return "", nil
+}
+
+// renderContent renders a request or response body: its media type, the
+// fields of its schema and an example. media holds the example(s) given for
+// the media type, if any.
+func (s *openAPISpec) renderContent(r *openAPIRenderer, mediaType string, schema, media *specNode) {
+	if mediaType != "" {
+		r.block("Content type: `" + mediaType + "`")
+	}
+	if schema != nil {
+		rows := s.schemaFields(s.itemsOrSelf(schema), "", 0, map[string]bool{})
+		if typ := s.schemaType(schema, map[string]bool{}); typ != "object" || len(rows) == 0 {
+			r.block("Schema: `" + typ + "`")
+		}
+		if len(rows) > 0 {
+			table := [][]string{{"Field", "Type", "Required", "Description"}}
+			for _, row := range rows {
+				table = append(table, []string{"`" + row.name + "`", row.typ, yesNo(row.required), row.description})
+			}
+			r.table(table)
+		}
+	}
+
+	example := s.mediaExample(media)
+	if example == nil && schema != nil && strings.Contains(mediaType, "json") {
+		example = s.schemaExample(schema, 0, map[string]bool{})
+	}
+	if example == nil {
+		return
+	}
+	lang := ""
+	switch {
+	case strings.Contains(mediaType, "json"):
+		lang = "json"
+	case strings.Contains(mediaType, "xml"):
+		lang = "xml"
+	case strings.Contains(mediaType, "yaml"):
+		lang = "yaml"
+	}
+	if example.kind == specScalar && lang != "json" {
+		r.block("Example:\n\n" + fencedCode(example.String(), lang))
+		return
+	}
+	if data, err := example.JSON(); err == nil {
+		r.block("Example:\n\n" + fencedCode(data, "json"))
+	}
+}
+
+// mediaExample returns the example given for a media type: its example, or
+// the value of the first of its named examples
+func (s *openAPISpec) mediaExample(media *specNode) *specNode {
+	if example := media.Get("example"); example != nil {
+		return example
+	}
+	examples := media.Get("examples")
+	for _, name := range examples.Keys() {
+		if value := s.resolve(examples.Get(name)).Get("value"); value != nil {
+			return value
+		}
+	}
+	return nil
+}
+
+// schemaField is a row of a schema's field table
+type schemaField struct {
+	name        string
+	typ         string
+	required    bool
+	description string
+}
+
+// itemsOrSelf returns the items of an array schema, whose fields are listed
+// in place of the array's
+func (s *openAPISpec) itemsOrSelf(schema *specNode) *specNode {
+	resolved := s.resolve(schema)
+	if items := resolved.Get("items"); items != nil && schemaTypes(resolved) == "array" {
+		return items
+	}
+	return schema
+}
+
+// schemaFields lists the properties of an object schema, following nested
+// objects and arrays of objects with dotted names (owner.name, tags[].id).
+// seen holds the schemas being expanded, so recursive schemas stop.
+func (s *openAPISpec) schemaFields(schema *specNode, prefix string, depth int, seen map[string]bool) []schemaField {
+	if schema == nil || depth >= maxSchemaDepth {
+		return nil
+	}
+	if ref := schema.Get("$ref").String(); ref != "" {
+		if seen[ref] {
+			return nil
+		}
+		seen[ref] = true
+		defer delete(seen, ref)
+	}
+	schema = s.resolve(schema)
+
+	var fields []schemaField
+	for _, part := range schema.Get("allOf").Items() {
+		fields = append(fields, s.schemaFields(part, prefix, depth, seen)...)
+	}
+
+	required := make(map[string]bool)
+	for _, name := range schema.Get("required").Items() {
+		required[name.String()] = true
+	}
+	properties := schema.Get("properties")
+	for _, name := range properties.Keys() {
+		property := properties.Get(name)
+		resolved := s.resolve(property)
+		fields = append(fields, schemaField{
+			name:        prefix + name,
+			typ:         s.schemaType(property, map[string]bool{}),
+			required:    required[name],
+			description: s.fieldDescription(resolved, resolved),
+		})
+
+		switch {
+		case resolved.Get("properties") != nil || resolved.Get("allOf") != nil:
+			fields = append(fields, s.schemaFields(property, prefix+name+".", depth+1, seen)...)
+		case resolved.Get("items") != nil:
+			fields = append(fields, s.schemaFields(resolved.Get("items"), prefix+name+"[].", depth+1, seen)...)
+		}
+	}
+	return fields
+}
+
+// schemaType describes the type of a schema: a primitive with its format
+// (string (date-time)), the name of a referenced schema, or an array of one.
+// seen holds the schemas being described, so recursive schemas stop at
+// their name.
+func (s *openAPISpec) schemaType(schema *specNode, seen map[string]bool) string {
+	if schema == nil {
+		return ""
+	}
+	if ref := schema.Get("$ref").String(); ref != "" {
+		if seen[ref] {
+			return refName(ref)
+		}
+		seen[ref] = true
+		defer delete(seen, ref)
+
+		resolved := s.resolve(schema)
+		switch typ := schemaTypes(resolved); typ {
+		case "", "object":
+			return refName(ref)
+		default:
+			if resolved.Get("enum") != nil {
+				return refName(ref) + " (" + typ + ")"
+			}
+			return s.schemaType(resolved, seen)
+		}
+	}
+
+	for _, key := range []string{"oneOf", "anyOf", "allOf"} {
+		variants := schema.Get(key).Items()
+		if len(variants) == 0 {
+			continue
+		}
+		if len(variants) == 1 {
+			return s.schemaType(variants[0], seen)
+		}
+		names := make([]string, len(variants))
+		for i, variant := range variants {
+			names[i] = s.schemaType(variant, seen)
+		}
+		label := map[string]string{"oneOf": "one of ", "anyOf": "any of ", "allOf": "all of "}[key]
+		return label + strings.Join(names, ", ")
+	}
+
+	typ := schemaTypes(schema)
+	switch typ {
+	case "array":
+		if items := schema.Get("items"); items != nil {
+			return "array of " + s.schemaType(items, seen)
+		}
+	case "", "object":
+		if additional := schema.Get("additionalProperties"); additional != nil && additional.kind == specObject && schema.Get("properties") == nil {
+			return "map of " + s.schemaType(additional, seen)
+		}
+		if typ == "" && schema.Get("properties") == nil {
+			return "any"
+		}
+		typ = "object"
+	}
+	if format := schema.Get("format").String(); format != "" {
+		typ += " (" + format + ")"
+	}
+	if schema.Get("nullable").Bool() {
+		typ += " or null"
+	}
+	return typ
+}
+
+// schemaTypes returns the type of a schema; OpenAPI 3.1 lists several
+// types, such as [string, null]
+func schemaTypes(schema *specNode) string {
+	typeNode := schema.Get("type")
+	if typeNode == nil || typeNode.kind != specArray {
+		return typeNode.String()
+	}
+	var types []string
+	for _, item := range typeNode.Items() {
+		types = append(types, item.String())
+	}
+	return strings.Join(types, " or ")
+}
+
+// refName returns the name a $ref points at (Pet for #/components/schemas/Pet)
+func refName(ref string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(path.Base(ref))
+}
+
+// fieldDescription renders the description of a parameter or property on
+// one line, with its allowed values, default and deprecation
+func (s *openAPISpec) fieldDescription(field, schema *specNode) string {
+	var parts []string
+	if field.Get("deprecated").Bool() {
+		parts = append(parts, "**Deprecated.**")
+	}
+	if description := field.Get("description").String(); description != "" {
+		parts = append(parts, description)
+	}
+	schema = s.resolve(schema)
+	if enum := schema.Get("enum").Items(); len(enum) > 0 {
+		values := make([]string, len(enum))
+		for i, value := range enum {
+			values[i] = "`" + value.String() + "`"
+		}
+		parts = append(parts, "One of: "+strings.Join(values, ", ")+".")
+	}
+	if def := schema.Get("default"); def != nil && def.kind == specScalar {
+		parts = append(parts, "Default: `"+def.String()+"`.")
+	}
+	text := strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+	return strings.ReplaceAll(text, "|", `\|`)
+}
+
+// schemaExample builds an example value for a schema from the examples,
+// defaults and enums it declares, with placeholders for the rest
+func (s *openAPISpec) schemaExample(schema *specNode, depth int, seen map[string]bool) *specNode {
+	if schema == nil || depth > maxSchemaDepth*2 {
+		return nil
+	}
+	if ref := schema.Get("$ref").String(); ref != "" {
+		if seen[ref] {
+			return nil
+		}
+		seen[ref] = true
+		defer delete(seen, ref)
+	}
+	schema = s.resolve(schema)
+
+	for _, key := range []string{"example", "default", "const"} {
+		if value := schema.Get(key); value != nil {
+			return value
+		}
+	}
+	if examples := schema.Get("examples").Items(); len(examples) > 0 {
+		return examples[0]
+	}
+	if enum := schema.Get("enum").Items(); len(enum) > 0 {
+		return enum[0]
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if variants := schema.Get(key).Items(); len(variants) > 0 {
+			return s.schemaExample(variants[0], depth+1, seen)
+		}
+	}
+
+	typ := schemaTypes(schema)
+	if i := strings.Index(typ, " or "); i >= 0 {
+		typ = typ[:i]
+	}
+	switch typ {
+	case "array":
+		example := &specNode{kind: specArray}
+		if item := s.schemaExample(schema.Get("items"), depth+1, seen); item != nil {
+			example.items = append(example.items, item)
+		}
+		return example
+	case "string":
+		return scalarNode(stringExample(schema.Get("format").String()))
+	case "integer", "number":
+		return scalarNode(0)
+	case "boolean":
+		return scalarNode(true)
+	case "null":
+		return scalarNode(nil)
+	}
+
+	example := &specNode{kind: specObject, fields: make(map[string]*specNode)}
+	add := func(object *specNode) {
+		for _, name := range object.Keys() {
+			if _, exists := example.fields[name]; !exists {
+				example.keys = append(example.keys, name)
+			}
+			example.fields[name] = object.Get(name)
+		}
+	}
+	for _, part := range schema.Get("allOf").Items() {
+		if value := s.schemaExample(part, depth+1, seen); value != nil && value.kind == specObject {
+			add(value)
+		}
+	}
+	properties := schema.Get("properties")
+	for _, name := range properties.Keys() {
+		property := properties.Get(name)
+		if s.resolve(property).Get("writeOnly").Bool() {
+			continue
+		}
+		if value := s.schemaExample(property, depth+1, seen); value != nil {
+			add(&specNode{kind: specObject, keys: []string{name}, fields: map[string]*specNode{name: value}})
+		}
+	}
+	if len(example.keys) == 0 && schema.Get("properties") == nil && schema.Get("allOf") == nil {
+		return nil
+	}
+	return example
+}
+
+// stringExample returns a placeholder for a string of a given format
+func stringExample(format string) string {
+	switch format {
+	case "date-time":
+		return "2024-01-01T00:00:00Z"
+	case "date":
+		return "2024-01-01"
+	case "email":
+		return "user@example.com"
+	case "uuid":
+		return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+	case "uri", "url":
+		return "https://example.com"
+	}
+	return "string"
+}
+
+// resolve follows local $ref pointers (#/components/schemas/Pet). External
+// references are returned as they are.
+func (s *openAPISpec) resolve(node *specNode) *specNode {
+	for i := 0; i < 16 && node != nil; i++ {
+		ref := node.Get("$ref").String()
+		if !strings.HasPrefix(ref, "#/") {
+			return node
+		}
+		target := s.root
+		for _, token := range strings.Split(ref[2:], "/") {
+			if unescaped, err := url.PathUnescape(token); err == nil {
+				token = unescaped
+			}
+			token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+			if target.kind == specArray {
+				index, err := strconv.Atoi(token)
+				if err != nil || index < 0 || index >= len(target.items) {
+					return node
+				}
+				target = target.items[index]
+				continue
+			}
+			target = target.Get(token)
+		}
+		if target == nil {
+			return node
+		}
+		node = target
+	}
+	return node
+}
+
+// yesNo renders a boolean table cell
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+// openAPIRenderer collects the blocks and outline of a rendered page
+type openAPIRenderer struct {
+	blocks  []string
+	outline []HeadingInfo
+}
+
+// heading adds a heading and records it in the outline
+func (r *openAPIRenderer) heading(level int, text string) {
+	text = strings.Join(strings.Fields(text), " ")
+	r.blocks = append(r.blocks, strings.Repeat("#", level)+" "+text)
+	r.outline = append(r.outline, HeadingInfo{Level: level, Text: text})
+}
+
+// block adds a block of Markdown, skipping empty ones
+func (r *openAPIRenderer) block(text string) {
+	if text = strings.TrimSpace(text); text != "" {
+		r.blocks = append(r.blocks, text)
+	}
+}
+
+// table adds a table whose first row is the header
+func (r *openAPIRenderer) table(rows [][]string) {
+	var sb strings.Builder
+	for i, row := range rows {
+		writeTableRow(&sb, row)
+		if i == 0 {
+			separators := make([]string, len(row))
+			for col := range separators {
+				separators[col] = "---"
+			}
+			writeTableRow(&sb, separators)
+		}
+	}
+	r.block(sb.String())
+}
+
+// String returns the rendered page
+func (r *openAPIRenderer) String() string {
+	return strings.Join(r.blocks, "\n\n") + "\n"
+}
+
+// saveOpenAPISpec renders a spec and saves each of its pages, recording the
+// spec in the manifest. parentURL is the page the spec was found on.
+func (c *Crawler) saveOpenAPISpec(spec *openAPISpec, parentURL string, depth int) {
+	pages := spec.Pages()
+	c.manifest.AddOpenAPISpec(OpenAPISpecInfo{
+		URL:       spec.URL,
+		Title:     spec.Title(),
+		Version:   spec.Version(),
+		Pages:     len(pages),
+		FetchedAt: time.Now(),
+	})
+	if c.verbose {
+		logInfo("Found OpenAPI spec %q with %d pages: %s", spec.Title(), len(pages), spec.URL)
+	}
+
+	for _, page := range pages {
+		pageURL := spec.URL + "#" + page.Fragment
+		if err := c.saveOpenAPIPage(spec, page, pageURL, parentURL, depth); err != nil {
+			logError("Failed to save page %s: %v", pageURL, err)
+
+			c.manifest.AddPage(&PageInfo{
+				URL:          pageURL,
+				Status:       "failed",
+				ErrorMessage: err.Error(),
+				CrawledAt:    time.Now(),
+			})
+		}
+	}
+}
+
+// saveOpenAPIPage saves one page rendered from a spec under its own URL
+func (c *Crawler) saveOpenAPIPage(spec *openAPISpec, page openAPIPage, pageURL, parentURL string, depth int) error {
+	startTime := time.Now()
+	if c.urlBloom.Test([]byte(pageURL)) && c.manifest.IsVisited(pageURL) {
+		return nil
+	}
+	c.urlBloom.Add([]byte(pageURL))
+	if c.limitReached() {
+		return nil
+	}
+
+	validation := validateMarkdown(page.Markdown)
+	if !validation.IsValid {
+		c.manifest.AddPage(&PageInfo{
+			URL:            pageURL,
+			Status:         "skipped",
+			ErrorMessage:   "minimal content",
+			CrawledAt:      time.Now(),
+			ProcessingTime: time.Since(startTime).Milliseconds(),
+		})
+
+		if c.verbose {
+			logSkip("Minimal content: %s", pageURL)
+		}
+		return nil
+	}
+
+	contentHash, duplicate := c.checkDuplicate(pageURL, page.Title, validation.CleanedContent, startTime)
+	if duplicate {
+		return nil
+	}
+
+	// Name the file after the spec and the fragment, which slugify ignores
+	fileURL := parseURLOrEmpty(spec.URL)
+	fileURL.Path = strings.TrimSuffix(fileURL.Path, "/") + "/" + page.Fragment
+	fileURL.Fragment = ""
+
+	atomic.AddInt32(&c.pageCount, 1)
+	filename, filePath, err := c.outputFile(fileURL.String(), "")
+	if err != nil {
+		return err
+	}
+
+	metadata := map[string]string{MetaOpenAPISpec: spec.URL}
+	if version := spec.Version(); version != "" {
+		metadata[MetaAPIVersion] = version
+	}
+	pageInfo := &PageInfo{
+		URL:            pageURL,
+		Title:          page.Title,
+		ContentHash:    contentHash,
+		FileName:       filename,
+		CrawledAt:      time.Now(),
+		ProcessingTime: time.Since(startTime).Milliseconds(),
+		Status:         "completed",
+		ContentSource:  SourceOpenAPI,
+		Depth:          depth,
+		ParentURL:      parentURL,
+		Metadata:       metadata,
+		WordCount:      countWords(validation.CleanedContent),
+		Outline:        page.Outline,
+	}
+	c.writePage(pageInfo, validation.CleanedContent, nil, filePath)
+	return nil
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const petstoreYAML = `openapi: "3.0.0"
+info:
+  title: Petstore
+  version: 1.0.0
+servers:
+  - url: /v1
+tags:
+  - name: pets
+    description: Everything about your pets
+paths:
+  /pets:
+    parameters:
+      - $ref: "#/components/parameters/Trace"
+    get:
+      summary: List all pets
+      operationId: listPets
+      tags: [pets]
+      parameters:
+        - name: limit
+          in: query
+          description: How many items to return
+          schema: {type: integer, format: int32}
+      responses:
+        "200":
+          description: A page of pets
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: "#/components/schemas/Pet"}
+    post:
+      summary: Create a pet
+      tags: [pets]
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/Pet"}
+            example: {id: 10, name: doggie}
+      responses:
+        "201":
+          description: Created
+  /health:
+    get:
+      operationId: health
+      responses:
+        "200":
+          description: The service is up
+components:
+  parameters:
+    Trace:
+      name: X-Trace
+      in: header
+      schema: {type: string}
+  schemas:
+    Pet:
+      type: object
+      required: [id, name]
+      properties:
+        id: {type: integer, format: int64}
+        name: {type: string}
+        status:
+          type: string
+          enum: [available, sold]
+        owner: {$ref: "#/components/schemas/Owner"}
+    Owner:
+      type: object
+      properties:
+        email: {type: string, format: email}
+        pets:
+          type: array
+          items: {$ref: "#/components/schemas/Pet"}
+`
+
+func TestOpenAPIPages(t *testing.T) {
+	spec, err := parseOpenAPISpec([]byte(petstoreYAML), "https://api.example.com/openapi.yaml")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if spec.BaseURL() != "https://api.example.com/v1" {
+		t.Errorf("BaseURL() = %q", spec.BaseURL())
+	}
+
+	pages := spec.Pages()
+	var fragments []string
+	for _, page := range pages {
+		fragments = append(fragments, page.Fragment)
+	}
+	if expected := []string{"tag/pets", "operation/health"}; !reflect.DeepEqual(fragments, expected) {
+		t.Fatalf("fragments = %v, expected %v", fragments, expected)
+	}
+
+	pets := pages[0]
+	if pets.Title != "pets - Petstore" {
+		t.Errorf("title = %q", pets.Title)
+	}
+	for _, expected := range []string{
+		"# pets\n\nEverything about your pets",
+		"## List all pets\n\n`GET /pets`",
+		"| `X-Trace` | header | string | no |  |",
+		"| `limit` | query | integer (int32) | no | How many items to return |",
+		"Schema: `array of Pet`",
+		"| `status` | string | no | One of: `available`, `sold`. |",
+		"| `owner.email` | string (email) | no |  |",
+		"| `owner.pets` | array of Pet | no |  |",
+		"\"owner\": {\n      \"email\": \"user@example.com\",",
+		"### Request body",
+		"```json\n{\n  \"id\": 10,\n  \"name\": \"doggie\"\n}\n```",
+		"#### 201 Created",
+	} {
+		if !strings.Contains(pets.Markdown, expected) {
+			t.Errorf("tag page missing %q\n%s", expected, pets.Markdown)
+		}
+	}
+	// Recursive schemas stop after one level
+	if strings.Contains(pets.Markdown, "owner.pets[].owner") {
+		t.Errorf("recursive schema expanded:\n%s", pets.Markdown)
+	}
+
+	var outline []string
+	for _, heading := range pets.Outline {
+		outline = append(outline, strings.Repeat("#", heading.Level)+" "+heading.Text)
+	}
+	expectedOutline := []string{"# pets", "## List all pets", "### Parameters", "### Responses", "#### 200 OK",
+		"## Create a pet", "### Parameters", "### Request body", "### Responses", "#### 201 Created"}
+	if !reflect.DeepEqual(outline, expectedOutline) {
+		t.Errorf("outline = %v, expected %v", outline, expectedOutline)
+	}
+
+	if !strings.HasPrefix(pages[1].Markdown, "# health\n\n`GET /health`\n\n## Responses") {
+		t.Errorf("operation page:\n%s", pages[1].Markdown)
+	}
+}
+
+func TestSwagger2Pages(t *testing.T) {
+	spec, err := parseOpenAPISpec([]byte(`{
+		"swagger": "2.0",
+		"info": {"title": "Store", "version": "2", "description": "The store API."},
+		"host": "store.example.com",
+		"basePath": "/api",
+		"schemes": ["https"],
+		"paths": {
+			"/orders": {
+				"post": {
+					"tags": ["store"],
+					"summary": "Place an order",
+					"parameters": [{"in": "body", "name": "body", "required": true, "schema": {"$ref": "#/definitions/Order"}}],
+					"responses": {"200": {"description": "The order", "schema": {"$ref": "#/definitions/Order"}}}
+				}
+			}
+		},
+		"definitions": {
+			"Order": {"type": "object", "properties": {"id": {"type": "integer"}, "shipDate": {"type": "string", "format": "date-time"}}}
+		}
+	}`), "https://store.example.com/swagger.json")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if spec.BaseURL() != "https://store.example.com/api" {
+		t.Errorf("BaseURL() = %q", spec.BaseURL())
+	}
+
+	pages := spec.Pages()
+	if len(pages) != 2 || pages[0].Fragment != "overview" || pages[1].Fragment != "tag/store" {
+		t.Fatalf("unexpected pages: %+v", pages)
+	}
+	if !strings.Contains(pages[0].Markdown, "The store API.") {
+		t.Errorf("overview:\n%s", pages[0].Markdown)
+	}
+	for _, expected := range []string{
+		"### Request body\n\nContent type: `application/json`\n\nSchema: `Order`",
+		"| `shipDate` | string (date-time) | no |  |",
+		"\"shipDate\": \"2024-01-01T00:00:00Z\"",
+	} {
+		if !strings.Contains(pages[1].Markdown, expected) {
+			t.Errorf("tag page missing %q\n%s", expected, pages[1].Markdown)
+		}
+	}
+	if strings.Contains(pages[1].Markdown, "### Parameters") {
+		t.Errorf("body parameter listed as a parameter:\n%s", pages[1].Markdown)
+	}
+}
+
+func TestRecursiveArraySchema(t *testing.T) {
+	spec, err := parseOpenAPISpec([]byte(`openapi: 3.0.0
+info: {title: Forest, version: "1"}
+paths:
+  /trees:
+    get:
+      operationId: listTrees
+      responses:
+        "200":
+          description: The trees
+          content:
+            application/json:
+              schema: {$ref: "#/components/schemas/Tree"}
+components:
+  schemas:
+    Tree: {type: array, items: {$ref: "#/components/schemas/Tree"}}
+`), "https://example.com/openapi.yaml")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	pages := spec.Pages()
+	if len(pages) != 1 || !strings.Contains(pages[0].Markdown, "Schema: `array of Tree`") {
+		t.Errorf("unexpected pages: %+v", pages)
+	}
+}
+
+func TestParseOpenAPISpecErrors(t *testing.T) {
+	tests := map[string]string{
+		"package.json": `{"name": "app", "version": "1.0.0"}`,
+		"no paths":     `{"openapi": "3.1.0", "info": {"title": "x"}}`,
+		"HTML":         `<!DOCTYPE html><html></html>`,
+		"broken YAML":  "openapi: 3.0.0\npaths: [\n",
+	}
+	for name, data := range tests {
+		if _, err := parseOpenAPISpec([]byte(data), "https://example.com/spec"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSpecNodeOrder(t *testing.T) {
+	for name, data := range map[string]string{
+		"JSON": `{"b": 1, "a": {"d": true, "c": null}, "list": ["x", 2]}`,
+		"YAML": "b: 1\na:\n  d: true\n  c: null\nlist: [x, 2]\n",
+	} {
+		var root *specNode
+		var err error
+		if name == "JSON" {
+			root, err = parseSpecJSON([]byte(data))
+		} else {
+			root, err = parseSpecYAML([]byte(data))
+		}
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", name, err)
+		}
+		if !reflect.DeepEqual(root.Keys(), []string{"b", "a", "list"}) {
+			t.Errorf("%s: keys = %v", name, root.Keys())
+		}
+		encoded, err := root.JSON()
+		if err != nil {
+			t.Fatalf("%s: encode error: %v", name, err)
+		}
+		expected := "{\n  \"b\": 1,\n  \"a\": {\n    \"d\": true,\n    \"c\": null\n  },\n  \"list\": [\n    \"x\",\n    2\n  ]\n}"
+		if encoded != expected {
+			t.Errorf("%s: JSON() = %s", name, encoded)
+		}
+	}
+}
+
+func TestOpenAPISpecURLs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/swagger-initializer.js":
+			w.Write([]byte(`window.onload = function() {
+				window.ui = SwaggerUIBundle({
+					urls: [{url: "/specs/public.yaml", name: "Public"}],
+					dom_id: '#swagger-ui',
+				});
+			};`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(`<html><head>
+		<link rel="alternate" type="application/json" href="/openapi.json">
+		<script src="swagger-initializer.js"></script>
+		<script>Redoc.init('./specs/admin.yaml', {}, document.getElementById('redoc'))</script>
+	</head><body>
+		<redoc spec-url="https://cdn.example.com/api.json"></redoc>
+		<a href="/guide/">Guide</a>
+		<a href="/v3/api-docs">Raw spec</a>
+	</body></html>`))
+	pageURL, _ := url.Parse(server.URL + "/docs/")
+
+	loader := newOpenAPILoader(server.Client(), pageURL.Host, CrawlConfig{})
+	expected := []string{
+		"https://cdn.example.com/api.json",
+		server.URL + "/specs/public.yaml",
+		server.URL + "/docs/specs/admin.yaml",
+		server.URL + "/openapi.json",
+		server.URL + "/v3/api-docs",
+	}
+	if urls := loader.specURLs(doc.Selection, pageURL); !reflect.DeepEqual(urls, expected) {
+		t.Errorf("specURLs() = %v\nexpected %v", urls, expected)
+	}
+}
+
+func TestOpenAPIDiscover(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/openapi.yaml" {
+			w.Write([]byte(petstoreYAML))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	pageURL, _ := url.Parse(server.URL + "/reference#tag/pets")
+	// The same server under another host name is another site
+	otherHost := "http://localhost:" + pageURL.Port() + "/openapi.yaml"
+
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+		<redoc spec-url="/openapi.yaml"></redoc>
+		<a href="/swagger.json">Missing spec</a>
+		<a href="` + otherHost + `">Other site</a>
+		<script>const __redoc_state = {"menu":{"activeItemIdx":-1},"spec":{"data":{"openapi":"3.0.0","info":{"title":"Embedded"},"paths":{}}}};</script>
+	</body></html>`))
+
+	loader := newOpenAPILoader(server.Client(), pageURL.Host, CrawlConfig{})
+	specs := loader.Discover(doc.Selection, pageURL)
+	var found []string
+	for _, spec := range specs {
+		found = append(found, spec.Title()+" "+spec.URL)
+	}
+	expected := []string{"Embedded " + server.URL + "/reference", "Petstore " + server.URL + "/openapi.yaml"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Discover() = %v, expected %v", found, expected)
+	}
+
+	// Specs are only loaded once
+	if specs := loader.Discover(doc.Selection, pageURL); len(specs) != 0 {
+		t.Errorf("second Discover() returned %d specs", len(specs))
+	}
+}
+
+func TestOpenAPIContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		path        string
+		expected    bool
+	}{
+		{"application/json", "/openapi.json", true},
+		{"application/vnd.oai.openapi+json;version=3.0", "/spec", true},
+		{"application/x-yaml", "/api.yaml", true},
+		{"text/plain; charset=utf-8", "/swagger.yaml", true},
+		{"text/plain", "/notes.txt", false},
+		{"image/png", "/logo.png", false},
+	}
+	for _, tt := range tests {
+		if got := openAPIContentType(tt.contentType, tt.path); got != tt.expected {
+			t.Errorf("openAPIContentType(%q, %q) = %v, expected %v", tt.contentType, tt.path, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,893 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxPDFStreamSize caps the decompressed size of a single PDF stream
+const maxPDFStreamSize = 64 * 1024 * 1024
+
+var (
+	errPDFEncrypted = errors.New("encrypted PDF")
+	errPDFNoText    = errors.New("no text found in PDF")
+
+	pdfObjectRe = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfInfoRe   = regexp.MustCompile(`/Info\s+(\d+)\s+\d+\s+R`)
+)
+
+// PDF values are decoded into these types, along with float64, bool, nil,
+// []interface{} for arrays and map[string]interface{} for dictionaries
+type (
+	pdfName   string
+	pdfString []byte
+	pdfOp     string
+	pdfRef    int
+)
+
+// pdfDelimiter marks the end of an array or dictionary while parsing
+type pdfDelimiter byte
+
+// pdfObject is an indirect object: its value and, for streams, the raw stream data
+type pdfObject struct {
+	value  interface{}
+	stream []byte
+}
+
+// pdfFile is a PDF parsed far enough to pull out the text of its pages. The
+// cross-reference table is ignored; objects are found by scanning the file,
+// which also copes with files that were truncated or edited by hand.
+type pdfFile struct {
+	objects map[int]*pdfObject
+	cmaps   map[int]*pdfCMap
+}
+
+// pdfText extracts the text of a PDF, page by page, with a blank line
+// between paragraphs and pages, along with the title from its document
+// information dictionary
+func pdfText(data []byte) (text, title string, err error) {
+	doc, err := parsePDF(data)
+	if err != nil {
+		return "", "", err
+	}
+
+	var pages []string
+	for _, page := range doc.pages() {
+		text := doc.pageText(page)
+		if strings.TrimSpace(text) != "" {
+			pages = append(pages, text)
+		}
+	}
+	text = normalizeMarkdown(strings.Join(pages, "\n\n"))
+	if text == "" {
+		return "", "", errPDFNoText
+	}
+	return text, doc.title(data), nil
+}
+
+// title returns the title from the document information dictionary that the
+// trailer of data points at
+func (doc *pdfFile) title(data []byte) string {
+	matches := pdfInfoRe.FindAllSubmatch(data, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	num, _ := strconv.Atoi(string(matches[len(matches)-1][1]))
+	info, _ := doc.resolve(pdfRef(num)).(map[string]interface{})
+	title, _ := doc.resolve(info["Title"]).(pdfString)
+	return strings.TrimSpace(decodePDFTextString(title))
+}
+
+// parsePDF finds the indirect objects of a PDF, including those packed into
+// object streams
+func parsePDF(data []byte) (*pdfFile, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-")) {
+		return nil, errors.New("not a PDF file")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return nil, errPDFEncrypted
+	}
+
+	doc := &pdfFile{objects: make(map[int]*pdfObject), cmaps: make(map[int]*pdfCMap)}
+	for _, loc := range pdfObjectRe.FindAllSubmatchIndex(data, -1) {
+		num, err := strconv.Atoi(string(data[loc[2]:loc[3]]))
+		if err != nil {
+			continue
+		}
+		lexer := &pdfLexer{data: data, pos: loc[1]}
+		value, err := lexer.readObject()
+		if err != nil {
+			continue
+		}
+		obj := &pdfObject{value: value}
+		if dict, ok := value.(map[string]interface{}); ok {
+			obj.stream = lexer.readStream(dict)
+		}
+		// Later definitions win, as with incremental updates
+		doc.objects[num] = obj
+	}
+
+	// Objects packed into object streams
+	for _, num := range doc.objectNumbers() {
+		obj := doc.objects[num]
+		if dict, ok := obj.value.(map[string]interface{}); ok && dict["Type"] == pdfName("ObjStm") {
+			doc.unpackObjectStream(dict, doc.decodeStream(obj))
+		}
+	}
+
+	if len(doc.objects) == 0 {
+		return nil, errors.New("no objects found in PDF")
+	}
+	return doc, nil
+}
+
+// unpackObjectStream adds the objects stored in an object stream
+func (doc *pdfFile) unpackObjectStream(dict map[string]interface{}, data []byte) {
+	n, _ := doc.resolve(dict["N"]).(float64)
+	first, _ := doc.resolve(dict["First"]).(float64)
+	if data == nil || first < 0 || int(first) > len(data) {
+		return
+	}
+
+	header := &pdfLexer{data: data[:int(first)]}
+	for i := 0; i < int(n); i++ {
+		num, err1 := header.readObject()
+		offset, err2 := header.readObject()
+		numValue, ok1 := num.(float64)
+		offsetValue, ok2 := offset.(float64)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 || offsetValue < 0 {
+			return
+		}
+		pos := int(first) + int(offsetValue)
+		if pos < int(first) || pos >= len(data) {
+			continue
+		}
+		if _, exists := doc.objects[int(numValue)]; exists {
+			continue
+		}
+		value, err := (&pdfLexer{data: data, pos: pos}).readObject()
+		if err == nil {
+			doc.objects[int(numValue)] = &pdfObject{value: value}
+		}
+	}
+}
+
+// resolve follows an indirect reference
+func (doc *pdfFile) resolve(value interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := value.(pdfRef)
+		if !ok {
+			return value
+		}
+		obj := doc.objects[int(ref)]
+		if obj == nil {
+			return nil
+		}
+		value = obj.value
+	}
+	return nil
+}
+
+// decodeStream returns the decoded data of a stream object. Streams with
+// filters other than FlateDecode (images, mostly) return nil.
+func (doc *pdfFile) decodeStream(obj *pdfObject) []byte {
+	if obj == nil || obj.stream == nil {
+		return nil
+	}
+	dict, _ := obj.value.(map[string]interface{})
+
+	var filters []interface{}
+	switch filter := doc.resolve(dict["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{filter}
+	case []interface{}:
+		filters = filter
+	}
+
+	data := obj.stream
+	for _, filter := range filters {
+		if doc.resolve(filter) != pdfName("FlateDecode") {
+			return nil
+		}
+		data = inflate(data)
+		if data == nil {
+			return nil
+		}
+	}
+	return data
+}
+
+// inflate decompresses zlib data, falling back to raw deflate for streams
+// written without the zlib header
+func inflate(data []byte) []byte {
+	var reader io.Reader
+	if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		reader = zr
+	} else {
+		reader = flate.NewReader(bytes.NewReader(data))
+	}
+	// Truncated streams still yield the data before the damage
+	out, _ := io.ReadAll(io.LimitReader(reader, maxPDFStreamSize))
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// pdfPage is a page object with the resources it inherits from its parents
+type pdfPage struct {
+	dict      map[string]interface{}
+	resources map[string]interface{}
+}
+
+// pages lists the pages of the document in order, following the page tree
+// from the catalog. Files without a usable catalog fall back to every page
+// object in object number order.
+func (doc *pdfFile) pages() []pdfPage {
+	var pages []pdfPage
+	visited := make(map[int]bool)
+
+	var walk func(node interface{}, resources map[string]interface{}, depth int)
+	walk = func(node interface{}, resources map[string]interface{}, depth int) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[int(ref)] {
+				return
+			}
+			visited[int(ref)] = true
+		}
+		dict, ok := doc.resolve(node).(map[string]interface{})
+		if !ok || depth > 64 {
+			return
+		}
+		if own, ok := doc.resolve(dict["Resources"]).(map[string]interface{}); ok {
+			resources = own
+		}
+		if kids, ok := doc.resolve(dict["Kids"]).([]interface{}); ok {
+			for _, kid := range kids {
+				walk(kid, resources, depth+1)
+			}
+			return
+		}
+		if dict["Type"] == pdfName("Page") || dict["Contents"] != nil {
+			pages = append(pages, pdfPage{dict: dict, resources: resources})
+		}
+	}
+
+	for _, num := range doc.objectNumbers() {
+		if dict, ok := doc.objects[num].value.(map[string]interface{}); ok && dict["Type"] == pdfName("Catalog") {
+			walk(dict["Pages"], nil, 0)
+			break
+		}
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	for _, num := range doc.objectNumbers() {
+		if dict, ok := doc.objects[num].value.(map[string]interface{}); ok && dict["Type"] == pdfName("Page") {
+			resources, _ := doc.resolve(dict["Resources"]).(map[string]interface{})
+			pages = append(pages, pdfPage{dict: dict, resources: resources})
+		}
+	}
+	return pages
+}
+
+// objectNumbers returns the object numbers in ascending order
+func (doc *pdfFile) objectNumbers() []int {
+	nums := make([]int, 0, len(doc.objects))
+	for num := range doc.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// pageText runs the content streams of a page and collects the text they show
+func (doc *pdfFile) pageText(page pdfPage) string {
+	var content []byte
+	switch contents := page.dict["Contents"].(type) {
+	case pdfRef:
+		if array, ok := doc.resolve(contents).([]interface{}); ok {
+			for _, item := range array {
+				if ref, ok := item.(pdfRef); ok {
+					content = append(append(content, doc.decodeStream(doc.objects[int(ref)])...), '\n')
+				}
+			}
+		} else {
+			content = doc.decodeStream(doc.objects[int(contents)])
+		}
+	case []interface{}:
+		for _, item := range contents {
+			if ref, ok := item.(pdfRef); ok {
+				content = append(append(content, doc.decodeStream(doc.objects[int(ref)])...), '\n')
+			}
+		}
+	}
+	if len(content) == 0 {
+		return ""
+	}
+
+	fonts := make(map[string]*pdfCMap)
+	if fontDict, ok := doc.resolve(page.resources["Font"]).(map[string]interface{}); ok {
+		for name, ref := range fontDict {
+			fonts[name] = doc.fontCMap(ref)
+		}
+	}
+	return runPDFContent(content, fonts)
+}
+
+// fontCMap returns the ToUnicode map of a font, or nil if it has none
+func (doc *pdfFile) fontCMap(fontRef interface{}) *pdfCMap {
+	font, ok := doc.resolve(fontRef).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	ref, ok := font["ToUnicode"].(pdfRef)
+	if !ok {
+		return nil
+	}
+	if cmap, ok := doc.cmaps[int(ref)]; ok {
+		return cmap
+	}
+	cmap := parseCMap(doc.decodeStream(doc.objects[int(ref)]))
+	doc.cmaps[int(ref)] = cmap
+	return cmap
+}
+
+// runPDFContent interprets the text operators of a content stream. A change
+// of baseline starts a new line, and a gap of more than about two lines
+// starts a new paragraph; spacing inside TJ arrays becomes a space.
+func runPDFContent(content []byte, fonts map[string]*pdfCMap) string {
+	var sb strings.Builder
+	var operands []interface{}
+	var font *pdfCMap
+	fontSize, leading := 10.0, 12.0
+	var y, lineY float64
+	started, spaced := false, false
+
+	show := func(s pdfString) {
+		text := font.decode(s)
+		if text == "" {
+			return
+		}
+		if started && math.Abs(y-lineY) > 0.5 {
+			if math.Abs(y-lineY) > 2.2*math.Max(fontSize, 1) {
+				sb.WriteString("\n\n")
+			} else {
+				sb.WriteString("\n")
+			}
+		}
+		started = true
+		lineY = y
+		sb.WriteString(text)
+		spaced = strings.HasSuffix(text, " ")
+	}
+	number := func(i int) float64 {
+		if i < 0 || i >= len(operands) {
+			return 0
+		}
+		f, _ := operands[i].(float64)
+		return f
+	}
+
+	lexer := &pdfLexer{data: content}
+	for {
+		token, err := lexer.readToken()
+		if err != nil {
+			break
+		}
+		op, ok := token.(pdfOp)
+		if !ok {
+			if _, delimiter := token.(pdfDelimiter); !delimiter {
+				operands = append(operands, token)
+			}
+			continue
+		}
+
+		switch op {
+		case "BT":
+			// Each text object starts from the origin
+			y = 0
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					font = fonts[string(name)]
+				}
+				if size := math.Abs(number(1)); size > 0 {
+					fontSize = size
+				}
+			}
+		case "TL":
+			leading = number(0)
+		case "Td":
+			y += number(1)
+		case "TD":
+			leading = -number(1)
+			y += number(1)
+		case "Tm":
+			y = number(5)
+		case "T*":
+			y -= leading
+		case "Tj":
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					show(s)
+				}
+			}
+		case "'", "\"":
+			y -= leading
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					show(s)
+				}
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				items, _ := operands[len(operands)-1].([]interface{})
+				for _, item := range items {
+					switch value := item.(type) {
+					case pdfString:
+						show(value)
+					case float64:
+						// Large negative adjustments separate words
+						if value < -200 && started && !spaced {
+							sb.WriteByte(' ')
+							spaced = true
+						}
+					}
+				}
+			}
+		case "ID":
+			// Inline image data runs up to EI
+			lexer.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+
+	lines := strings.Split(sb.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(collapseWhitespace(line))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// pdfCMap maps character codes to text, from a font's ToUnicode stream
+type pdfCMap struct {
+	codeLen int
+	chars   map[uint32]string
+}
+
+// parseCMap reads the bfchar and bfrange mappings of a ToUnicode CMap
+func parseCMap(data []byte) *pdfCMap {
+	if data == nil {
+		return nil
+	}
+	cmap := &pdfCMap{codeLen: 1, chars: make(map[uint32]string)}
+	lexer := &pdfLexer{data: data}
+	var operands []interface{}
+	for {
+		token, err := lexer.readToken()
+		if err != nil {
+			break
+		}
+		op, ok := token.(pdfOp)
+		if !ok {
+			if _, delimiter := token.(pdfDelimiter); !delimiter {
+				operands = append(operands, token)
+			}
+			continue
+		}
+		switch op {
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if lo, ok := operands[0].(pdfString); ok && len(lo) > 0 {
+					cmap.codeLen = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					cmap.chars[codeValue(src)] = decodeUTF16(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := codeValue(lo), codeValue(hi)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					base := []rune(decodeUTF16(dst))
+					if len(base) == 0 {
+						continue
+					}
+					for code := start; code <= end; code++ {
+						r := append([]rune(nil), base...)
+						r[len(r)-1] += rune(code - start)
+						cmap.chars[code] = string(r)
+					}
+				case []interface{}:
+					for j, item := range dst {
+						if s, ok := item.(pdfString); ok && start+uint32(j) <= end {
+							cmap.chars[start+uint32(j)] = decodeUTF16(s)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return cmap
+}
+
+// decode turns the bytes of a shown string into text. Without a ToUnicode
+// map the bytes are read as Latin-1, which covers the standard encodings
+// for ASCII text.
+func (cmap *pdfCMap) decode(s pdfString) string {
+	if cmap == nil || len(cmap.chars) == 0 {
+		var sb strings.Builder
+		for _, b := range s {
+			if b >= 0x20 || b == '\t' {
+				sb.WriteRune(rune(b))
+			}
+		}
+		return sb.String()
+	}
+
+	var sb strings.Builder
+	for i := 0; i+cmap.codeLen <= len(s); i += cmap.codeLen {
+		code := codeValue(s[i : i+cmap.codeLen])
+		if text, ok := cmap.chars[code]; ok {
+			sb.WriteString(text)
+		} else if cmap.codeLen == 1 && code >= 0x20 {
+			sb.WriteRune(rune(code))
+		}
+	}
+	return sb.String()
+}
+
+// codeValue reads a big-endian character code
+func codeValue(b []byte) uint32 {
+	var code uint32
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+// decodeUTF16 decodes big-endian UTF-16 text
+func decodeUTF16(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// decodePDFTextString decodes a text string from a PDF dictionary, which is
+// either UTF-16 with a byte order mark or PDFDocEncoding (read as Latin-1)
+func decodePDFTextString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		return decodeUTF16(b[2:])
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// pdfLexer reads PDF objects and content stream tokens
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+// isPDFWhitespace reports whether c is PDF whitespace
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+// isPDFDelimiter reports whether c ends a name, number or keyword
+func isPDFDelimiter(c byte) bool {
+	return isPDFWhitespace(c) || strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// skipSpace skips whitespace and comments
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFWhitespace(c) {
+			l.pos++
+		} else if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+// readObject reads a complete value, turning "num gen R" into a reference
+func (l *pdfLexer) readObject() (interface{}, error) {
+	token, err := l.readToken()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := token.(pdfDelimiter); ok {
+		return nil, fmt.Errorf("unexpected %q at %d", token, l.pos)
+	}
+	if num, ok := token.(float64); ok && num == math.Trunc(num) && num >= 0 {
+		// Look ahead for "gen R"
+		save := l.pos
+		if gen, err := l.readToken(); err == nil {
+			if _, ok := gen.(float64); ok {
+				if op, err := l.readToken(); err == nil && op == pdfOp("R") {
+					return pdfRef(int(num)), nil
+				}
+			}
+		}
+		l.pos = save
+	}
+	return token, nil
+}
+
+// readToken reads a value or an operator. Arrays and dictionaries are read
+// whole; their closing brackets are returned as delimiters only when unbalanced.
+func (l *pdfLexer) readToken() (interface{}, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.EOF
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		return pdfName(decodePDFName(l.data[start:l.pos])), nil
+	case c == '(':
+		return l.readLiteralString(), nil
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		dict := make(map[string]interface{})
+		for {
+			key, err := l.readToken()
+			if err != nil {
+				return dict, nil
+			}
+			if key == pdfDelimiter('>') {
+				return dict, nil
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				continue
+			}
+			value, err := l.readObject()
+			if err != nil {
+				return dict, nil
+			}
+			dict[string(name)] = value
+		}
+	case c == '<':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && l.data[l.pos] != '>' {
+			l.pos++
+		}
+		hex := l.data[start:l.pos]
+		l.pos++
+		return decodePDFHex(hex), nil
+	case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+		l.pos += 2
+		return pdfDelimiter('>'), nil
+	case c == '[':
+		l.pos++
+		var array []interface{}
+		for {
+			item, err := l.readObject()
+			if err != nil {
+				// The closing bracket or the end of the data
+				return array, nil
+			}
+			array = append(array, item)
+		}
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		return pdfDelimiter(c), nil
+	case (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.':
+		start := l.pos
+		l.pos++
+		for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		num, err := strconv.ParseFloat(string(l.data[start:l.pos]), 64)
+		if err != nil {
+			return pdfOp(l.data[start:l.pos]), nil
+		}
+		return num, nil
+	default:
+		start := l.pos
+		for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		if l.pos == start {
+			l.pos++
+		}
+		switch word := string(l.data[start:l.pos]); word {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		default:
+			return pdfOp(word), nil
+		}
+	}
+}
+
+// readLiteralString reads a (string) with nested parentheses and escapes
+func (l *pdfLexer) readLiteralString() pdfString {
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					value := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						value = value*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(value))
+				} else {
+					out = append(out, e)
+				}
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// readStream reads the data of a stream following its dictionary
+func (l *pdfLexer) readStream(dict map[string]interface{}) []byte {
+	l.skipSpace()
+	if !bytes.HasPrefix(l.data[l.pos:], []byte("stream")) {
+		return nil
+	}
+	start := l.pos + len("stream")
+	if start < len(l.data) && l.data[start] == '\r' {
+		start++
+	}
+	if start < len(l.data) && l.data[start] == '\n' {
+		start++
+	}
+
+	// Trust a direct /Length when "endstream" follows it
+	if length, ok := dict["Length"].(float64); ok {
+		end := start + int(length)
+		if end <= len(l.data) {
+			rest := bytes.TrimLeft(l.data[end:min(end+16, len(l.data))], " \r\n")
+			if bytes.HasPrefix(rest, []byte("endstream")) {
+				return l.data[start:end]
+			}
+		}
+	}
+
+	end := bytes.Index(l.data[start:], []byte("endstream"))
+	if end < 0 {
+		return l.data[start:]
+	}
+	return bytes.TrimRight(l.data[start:start+end], "\r\n")
+}
+
+// skipInlineImage skips the binary data of an inline image up to its EI operator
+func (l *pdfLexer) skipInlineImage() {
+	for l.pos+2 < len(l.data) {
+		if isPDFWhitespace(l.data[l.pos]) && l.data[l.pos+1] == 'E' && l.data[l.pos+2] == 'I' &&
+			(l.pos+3 >= len(l.data) || isPDFDelimiter(l.data[l.pos+3])) {
+			l.pos += 3
+			return
+		}
+		l.pos++
+	}
+	l.pos = len(l.data)
+}
+
+// decodePDFName decodes #xx escapes in a name
+func decodePDFName(b []byte) string {
+	if !bytes.Contains(b, []byte("#")) {
+		return string(b)
+	}
+	var out []byte
+	for i := 0; i < len(b); i++ {
+		if b[i] == '#' && i+2 < len(b) {
+			if v, err := strconv.ParseUint(string(b[i+1:i+3]), 16, 8); err == nil {
+				out = append(out, byte(v))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, b[i])
+	}
+	return string(out)
+}
+
+// decodePDFHex decodes a <hex> string; a missing final digit counts as 0
+func decodePDFHex(hex []byte) pdfString {
+	var digits []byte
+	for _, c := range hex {
+		if !isPDFWhitespace(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i+1 < len(digits); i += 2 {
+		v, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			break
+		}
+		out = append(out, byte(v))
+	}
+	return out
+}
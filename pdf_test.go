@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+)
+
+// buildPDF assembles a PDF from object bodies; object i+1 is objects[i].
+// The cross-reference table is left out since the parser doesn't need it.
+func buildPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	buf.WriteString("trailer\n<< /Root 1 0 R /Info 8 0 R >>\n%%EOF\n")
+	return buf.Bytes()
+}
+
+// pdfStream renders a stream object, compressing the data if asked to
+func pdfStream(data string, compress bool) string {
+	if !compress {
+		return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(data), data)
+	}
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte(data))
+	w.Close()
+	return fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", buf.Len(), buf.String())
+}
+
+func TestPDFText(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin
+12 dict begin begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar <0001> <0048> <0002> <0069> endbfchar
+1 beginbfrange <0010> <0012> <0061> endbfrange
+endcmap end end`
+
+	data := buildPDF(
+		`<< /Type /Catalog /Pages 2 0 R >>`,
+		`<< /Type /Pages /Kids [3 0 R 5 0 R] /Count 2 /Resources << /Font << /F1 7 0 R >> >> >>`,
+		`<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>`,
+		pdfStream(`BT /F1 12 Tf 72 720 Td (Getting \(started\)) Tj 0 -14 Td [(Install the ) -300 (client.)] TJ 0 -60 Td (New paragraph) Tj ET`, false),
+		`<< /Type /Page /Parent 2 0 R /Contents 6 0 R /Resources << /Font << /F2 9 0 R >> >> >>`,
+		pdfStream(`BT /F2 10 Tf 1 0 0 1 72 700 Tm <00010002> Tj 1 0 0 1 72 686 Tm <001000110012> Tj ET`, true),
+		`<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>`,
+		`<< /Title (Client Guide) >>`,
+		`<< /Type /Font /Subtype /Type0 /ToUnicode 10 0 R >>`,
+		pdfStream(cmap, true),
+	)
+
+	text, title, err := pdfText(data)
+	if err != nil {
+		t.Fatalf("pdfText() error: %v", err)
+	}
+	expected := "Getting (started)\nInstall the client.\n\nNew paragraph\n\nHi\nabc"
+	if text != expected {
+		t.Errorf("pdfText() = %q, want %q", text, expected)
+	}
+	if title != "Client Guide" {
+		t.Errorf("pdfText() title = %q, want %q", title, "Client Guide")
+	}
+}
+
+func TestPDFTextErrors(t *testing.T) {
+	if _, _, err := pdfText([]byte("<html>not a pdf</html>")); err == nil {
+		t.Error("expected an error for non-PDF data")
+	}
+	encrypted := buildPDF(`<< /Type /Catalog /Pages 2 0 R >>`, `<< /Filter /Standard /V 2 >>`)
+	encrypted = append(encrypted, []byte("trailer << /Encrypt 2 0 R >>")...)
+	if _, _, err := pdfText(encrypted); err != errPDFEncrypted {
+		t.Errorf("pdfText() error = %v, want %v", err, errPDFEncrypted)
+	}
+	if _, _, err := pdfText(buildPDF(`<< /Type /Catalog >>`)); err != errPDFNoText {
+		t.Errorf("pdfText() error = %v, want %v", err, errPDFNoText)
+	}
+}
+
+func TestPDFMalformedObjectStream(t *testing.T) {
+	objectStream := func(first int, header string) string {
+		data := header + " << /Type /Font >>"
+		return fmt.Sprintf("<< /Type /ObjStm /N 1 /First %d /Length %d >>\nstream\n%s\nendstream", first, len(data), data)
+	}
+	tests := map[string]string{
+		"negative first":  objectStream(-3, "11 0"),
+		"negative offset": objectStream(7, "11 -44"),
+	}
+	for name, stream := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := buildPDF(`<< /Type /Catalog /Pages 2 0 R >>`, stream)
+			if _, _, err := pdfText(data); err != errPDFNoText {
+				t.Errorf("pdfText() error = %v, want %v", err, errPDFNoText)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Content sources recorded in the manifest
+const (
+	SourceSelector    = "selector"
+	SourceFramework   = "framework"
+	SourceReadability = "readability"
+	SourceFullPage    = "full page"
+	SourceSearchIndex = "search index"
+	SourceSPAPayload  = "spa payload"
+	SourceDocument    = "document"
+	SourceOpenAPI     = "openapi"
+)
+
+const (
+	// minParagraphLength is the shortest text block that counts towards a candidate score
+	minParagraphLength = 25
+	// minCandidateScore is the lowest score accepted as the content root
+	minCandidateScore = 20
+)
+
+// Class/id patterns from Mozilla Readability, extended with docs-theme names
+var (
+	unlikelyCandidatesRe = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumb|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|cookie|consent`)
+	maybeCandidateRe     = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow|doc`)
+	positiveScoreRe      = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story|doc|markdown|prose`)
+	negativeScoreRe      = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget|nav|toc|menu`)
+)
+
+// findContentByScore picks the subtree most likely to hold the main content,
+// scoring candidates by text length, commas, link density and class/id hints.
+// It returns nil if no candidate scores high enough.
+func findContentByScore(page *goquery.Selection) (*goquery.Selection, float64) {
+	doc := page.Clone()
+	doc.Find("script, style, noscript, template, nav, header, footer, aside, form, svg").Remove()
+
+	// Drop elements whose class or id marks them as unlikely content
+	doc.Find("*").Each(func(i int, s *goquery.Selection) {
+		node := s.Get(0)
+		if node.DataAtom == atom.Body || node.DataAtom == atom.Html || node.DataAtom == atom.Main ||
+			node.DataAtom == atom.Article {
+			return
+		}
+		hints := getAttr(node, "class") + " " + getAttr(node, "id")
+		if unlikelyCandidatesRe.MatchString(hints) && !maybeCandidateRe.MatchString(hints) {
+			s.Remove()
+		}
+	})
+
+	scores := make(map[*html.Node]float64)
+	var order []*html.Node
+
+	addScore := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialScore(n)
+			order = append(order, n)
+		}
+		scores[n] += score
+	}
+
+	doc.Find("p, pre, td, blockquote, li, dd, div, section").Each(func(i int, s *goquery.Selection) {
+		node := s.Get(0)
+
+		// Divs and sections only count when they hold text directly
+		if (node.DataAtom == atom.Div || node.DataAtom == atom.Section) && hasBlockChild(node) {
+			return
+		}
+
+		text := strings.TrimSpace(s.Text())
+		length := utf8.RuneCountInString(text)
+		if length < minParagraphLength {
+			return
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(length)/100, 3)
+
+		// Parents get the full score, ancestors further up get a decreasing share
+		ancestor := node.Parent
+		for level := 0; ancestor != nil && level < 3; level++ {
+			switch level {
+			case 0:
+				addScore(ancestor, score)
+			case 1:
+				addScore(ancestor, score/2)
+			default:
+				addScore(ancestor, score/float64(level*3))
+			}
+			ancestor = ancestor.Parent
+		}
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, n := range order {
+		score := scores[n] * (1 - linkDensity(n))
+		if best == nil || score > bestScore {
+			best = n
+			bestScore = score
+		}
+	}
+
+	if best == nil || bestScore < minCandidateScore {
+		return nil, 0
+	}
+
+	return goquery.NewDocumentFromNode(best).Selection, bestScore
+}
+
+// initialScore gives a candidate its starting score from its tag and class/id hints
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.DataAtom {
+	case atom.Div, atom.Article, atom.Main, atom.Section:
+		score = 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score = 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score = -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score = -5
+	}
+	return score + classWeight(n)
+}
+
+// classWeight scores the class and id of an element against the positive/negative patterns
+func classWeight(n *html.Node) float64 {
+	var weight float64
+	for _, hint := range []string{getAttr(n, "class"), getAttr(n, "id")} {
+		if hint == "" {
+			continue
+		}
+		if negativeScoreRe.MatchString(hint) {
+			weight -= 25
+		}
+		if positiveScoreRe.MatchString(hint) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// linkDensity returns the share of an element's text that sits inside links
+func linkDensity(n *html.Node) float64 {
+	textLength := utf8.RuneCountInString(strings.TrimSpace(nodeText(n)))
+	if textLength == 0 {
+		return 0
+	}
+
+	linkLength := 0
+	for _, a := range findAll(n, atom.A) {
+		linkLength += utf8.RuneCountInString(strings.TrimSpace(nodeText(a)))
+	}
+	return math.Min(float64(linkLength)/float64(textLength), 1)
+}
+
+// hasBlockChild reports whether an element directly contains block-level elements
+func hasBlockChild(n *html.Node) bool {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && isBlockElement(child) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestFindContentByScore(t *testing.T) {
+	paragraph := "<p>The configuration file controls logging, caching, retries and timeouts for every client in the cluster.</p>"
+	page := `<html><body>
+		<div class="sidebar-menu"><a href="/a">Getting started with the client library</a><a href="/b">Advanced configuration topics</a></div>
+		<div class="wrapper"><div id="doc-body">` + strings.Repeat(paragraph, 5) + `</div>
+		<div class="related-links"><p><a href="/c">Related: the very long title of another page, with commas</a></p></div></div>
+		<div class="site-footer"><p>Copyright 2024 Example Corp, all rights reserved, no warranty.</p></div>
+	</body></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("failed to parse HTML: %v", err)
+	}
+
+	content, score := findContentByScore(doc.Selection)
+	if content == nil {
+		t.Fatal("expected a content candidate")
+	}
+	if id, _ := content.Attr("id"); id != "doc-body" {
+		t.Errorf("picked <%s id=%q>, want #doc-body", goquery.NodeName(content), id)
+	}
+	if score < minCandidateScore {
+		t.Errorf("score = %.1f, want at least %d", score, minCandidateScore)
+	}
+
+	sparse, _ := goquery.NewDocumentFromReader(strings.NewReader(`<html><body><p>Short.</p></body></html>`))
+	if content, _ := findContentByScore(sparse.Selection); content != nil {
+		t.Error("expected no candidate for a page without real content")
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/gobwas/glob"
+)
+
+const (
+	defaultContentSelector = "main, article, [role='main'], .content, #content"
+	defaultRuleName        = "default"
+)
+
+// ExtractionRule maps a set of URLs to the selectors used to extract their content
+type ExtractionRule struct {
+	Name    string `json:"name"`
+	Glob    string `json:"glob,omitempty"`    // matched against the URL path, or the full URL if it contains "://"
+	Regex   string `json:"regex,omitempty"`   // matched against the full URL
+	Content string `json:"content,omitempty"` // content root selector
+	Exclude string `json:"exclude,omitempty"` // elements removed from the content root
+
+	glob  glob.Glob
+	regex *regexp.Regexp
+}
+
+// rulesFile is the on-disk format of a rules file
+type rulesFile struct {
+	Rules []*ExtractionRule `json:"rules"`
+}
+
+// ruleSet picks the extraction rule for each URL
+type ruleSet struct {
+	rules    []*ExtractionRule
+	fallback *ExtractionRule
+}
+
+// newRuleSet builds the rules from the command line selectors and an optional rules file.
+// Rules without a content selector use the global one; global exclusions apply to every rule.
+func newRuleSet(contentSelector, excludeSelector, path string) (*ruleSet, error) {
+	if contentSelector == "" {
+		contentSelector = defaultContentSelector
+	}
+
+	rs := &ruleSet{
+		fallback: &ExtractionRule{
+			Name:    defaultRuleName,
+			Content: contentSelector,
+			Exclude: excludeSelector,
+		},
+	}
+	if err := rs.fallback.compile(); err != nil {
+		return nil, err
+	}
+
+	if path == "" {
+		return rs, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	var file rulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+
+	for i, rule := range file.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if rule.Glob == "" && rule.Regex == "" {
+			return nil, fmt.Errorf("rule %q: glob or regex is required", rule.Name)
+		}
+		if rule.Content == "" {
+			rule.Content = contentSelector
+		}
+		rule.Exclude = joinSelectors(rule.Exclude, excludeSelector)
+
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+		rs.rules = append(rs.rules, rule)
+	}
+
+	return rs, nil
+}
+
+// compile validates the selectors and compiles the URL patterns of a rule
+func (r *ExtractionRule) compile() error {
+	for _, selector := range []string{r.Content, r.Exclude} {
+		if selector == "" {
+			continue
+		}
+		if _, err := cascadia.ParseGroup(selector); err != nil {
+			return fmt.Errorf("rule %q: invalid selector %q: %w", r.Name, selector, err)
+		}
+	}
+
+	if r.Glob != "" {
+		g, err := glob.Compile(r.Glob, '/')
+		if err != nil {
+			return fmt.Errorf("rule %q: invalid glob %q: %w", r.Name, r.Glob, err)
+		}
+		r.glob = g
+	}
+
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return fmt.Errorf("rule %q: invalid regex %q: %w", r.Name, r.Regex, err)
+		}
+		r.regex = re
+	}
+
+	return nil
+}
+
+// matches reports whether the rule applies to a URL
+func (r *ExtractionRule) matches(u *url.URL) bool {
+	if r.glob != nil {
+		target := u.Path
+		if target == "" {
+			target = "/"
+		}
+		if strings.Contains(r.Glob, "://") {
+			target = u.String()
+		}
+		if r.glob.Match(target) {
+			return true
+		}
+	}
+	return r.regex != nil && r.regex.MatchString(u.String())
+}
+
+// Match returns the first rule that applies to a URL, or the default rule
+func (rs *ruleSet) Match(u *url.URL) *ExtractionRule {
+	for _, rule := range rs.rules {
+		if rule.matches(u) {
+			return rule
+		}
+	}
+	return rs.fallback
+}
+
+// joinSelectors combines selector groups into one
+func joinSelectors(selectors ...string) string {
+	var parts []string
+	for _, selector := range selectors {
+		if selector = strings.TrimSpace(selector); selector != "" {
+			parts = append(parts, selector)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
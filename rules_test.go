@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRuleSetMatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	rules := `{"rules": [
+		{"name": "api", "glob": "/api/**", "content": ".api-body", "exclude": ".try-it"},
+		{"name": "blog", "regex": "^https://example\\.com/blog/\\d+"}
+	]}`
+	if err := os.WriteFile(path, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := newRuleSet("", ".sidebar", path)
+	if err != nil {
+		t.Fatalf("newRuleSet() error = %v", err)
+	}
+
+	tests := []struct {
+		url     string
+		name    string
+		content string
+		exclude string
+	}{
+		{"https://example.com/api/v1/users", "api", ".api-body", ".try-it, .sidebar"},
+		{"https://example.com/blog/2024/post", "blog", defaultContentSelector, ".sidebar"},
+		{"https://example.com/guide/", defaultRuleName, defaultContentSelector, ".sidebar"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, _ := url.Parse(tt.url)
+			rule := rs.Match(u)
+			if rule.Name != tt.name || rule.Content != tt.content || rule.Exclude != tt.exclude {
+				t.Errorf("Match() = {%s %q %q}, want {%s %q %q}",
+					rule.Name, rule.Content, rule.Exclude, tt.name, tt.content, tt.exclude)
+			}
+		})
+	}
+
+	if _, err := newRuleSet("main[", "", ""); err == nil {
+		t.Error("expected error for invalid selector")
+	}
+}
@@ -0,0 +1,603 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Search index formats shipped by static docs generators
+const (
+	SearchIndexMkDocs     = "mkdocs"
+	SearchIndexSphinx     = "sphinx"
+	SearchIndexDocusaurus = "docusaurus"
+	SearchIndexVitePress  = "vitepress"
+)
+
+// maxSearchIndexSize caps how much of a search index is read
+const maxSearchIndexSize = 64 * 1024 * 1024
+
+var (
+	sphinxArrayRe     = regexp.MustCompile(`"?\b(docnames|titles)"?\s*:\s*(\[[^\]]*\])`)
+	mkdocsBaseURLRe   = regexp.MustCompile(`\bbase_url\s*=\s*["']([^"']*)["']`)
+	jsStringRe        = regexp.MustCompile(`(?s)(?:=|export\s+default)\s*("(?:[^"\\]|\\.)*")`)
+	vitepressIndexRe  = regexp.MustCompile(`@localSearchIndexroot[\w-]*\.[\w-]+\.js`)
+	vitepressSearchRe = regexp.MustCompile(`VPLocalSearchBox\.[\w-]+\.js`)
+)
+
+// searchIndexPage is a page listed in a docs search index
+type searchIndexPage struct {
+	URL      string
+	Index    string // URL of the index the page was found in
+	Title    string
+	Text     string
+	Sections []*searchIndexSection
+}
+
+// searchIndexSection is a heading within an indexed page
+type searchIndexSection struct {
+	Anchor string
+	Title  string
+	Text   string
+}
+
+// searchIndex fetches the client-side search indexes of static docs sites
+// to discover pages no link reaches and to recover the text of pages that
+// are rendered in the browser
+type searchIndex struct {
+	client    *http.Client
+	userAgent string
+	baseURL   *url.URL
+	domain    string
+	manifest  *CrawlManifest
+	verbose   bool
+
+	mu      sync.Mutex
+	fetched map[string]bool             // index and script URLs already tried
+	pages   map[string]*searchIndexPage // normalized page URL -> page
+}
+
+// newSearchIndex creates a search index loader for the site at baseURL
+func newSearchIndex(client *http.Client, baseURL *url.URL, config CrawlConfig, manifest *CrawlManifest) *searchIndex {
+	return &searchIndex{
+		client:    client,
+		userAgent: config.UserAgent,
+		baseURL:   baseURL,
+		domain:    baseURL.Host,
+		manifest:  manifest,
+		verbose:   config.Verbose,
+		fetched:   make(map[string]bool),
+		pages:     make(map[string]*searchIndexPage),
+	}
+}
+
+// Discover fetches the search indexes a page points at that haven't been
+// tried yet and returns the pages they list
+func (si *searchIndex) Discover(doc *goquery.Selection, pageURL *url.URL, framework *docsFramework) []*searchIndexPage {
+	var found []*searchIndexPage
+	for kind, indexURL := range si.candidates(doc, pageURL, framework) {
+		if !si.claim(indexURL) {
+			continue
+		}
+
+		pages, err := si.load(kind, indexURL, pageURL)
+		if err != nil {
+			if si.verbose {
+				logDim("No %s search index at %s: %v", kind, indexURL, err)
+			}
+			continue
+		}
+		if len(pages) == 0 {
+			continue
+		}
+
+		si.mu.Lock()
+		for _, page := range pages {
+			key := normalizeLinkURL(page.URL)
+			if _, exists := si.pages[key]; !exists {
+				si.pages[key] = page
+			}
+		}
+		si.mu.Unlock()
+
+		si.manifest.AddSearchIndex(SearchIndexInfo{
+			URL:       indexURL,
+			Kind:      kind,
+			Pages:     len(pages),
+			FetchedAt: time.Now(),
+		})
+		if si.verbose {
+			logInfo("Found %s search index with %d pages: %s", kind, len(pages), indexURL)
+		}
+		found = append(found, pages...)
+	}
+	return found
+}
+
+// PageText renders the indexed text of a page as Markdown. It returns an
+// empty string if the index has no text for the page.
+func (si *searchIndex) PageText(pageURL string) string {
+	si.mu.Lock()
+	page := si.pages[normalizeLinkURL(pageURL)]
+	si.mu.Unlock()
+	if page == nil {
+		return ""
+	}
+
+	base, _ := url.Parse(page.URL)
+	var blocks []string
+	if text := indexTextToMarkdown(page.Text, base); text != "" {
+		blocks = append(blocks, text)
+	}
+	for _, section := range page.Sections {
+		text := indexTextToMarkdown(section.Text, base)
+		if text == "" || (page.Text != "" && strings.Contains(page.Text, section.Text)) {
+			// Older MkDocs versions repeat every section in the page entry
+			continue
+		}
+		if section.Title != "" && section.Title != page.Title {
+			blocks = append(blocks, "## "+section.Title)
+		}
+		blocks = append(blocks, text)
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// claim marks an index URL as tried, returning false if it already was
+func (si *searchIndex) claim(indexURL string) bool {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	if si.fetched[indexURL] {
+		return false
+	}
+	si.fetched[indexURL] = true
+	return true
+}
+
+// candidates returns the index URLs to try for a page, keyed by index format
+func (si *searchIndex) candidates(doc *goquery.Selection, pageURL *url.URL, framework *docsFramework) map[string]string {
+	kinds := []string{SearchIndexMkDocs, SearchIndexSphinx, SearchIndexDocusaurus}
+	if framework != nil {
+		switch framework.Name {
+		case "readthedocs":
+			kinds = []string{SearchIndexSphinx, SearchIndexMkDocs}
+		case "sphinx":
+			kinds = []string{SearchIndexSphinx}
+		case "mkdocs", "mkdocs-material":
+			kinds = []string{SearchIndexMkDocs}
+		case "docusaurus":
+			kinds = []string{SearchIndexDocusaurus}
+		case "vitepress":
+			kinds = []string{SearchIndexVitePress}
+		default:
+			return nil
+		}
+	}
+
+	root := searchIndexRoot(doc, pageURL, si.baseURL)
+	candidates := make(map[string]string)
+	for _, kind := range kinds {
+		switch kind {
+		case SearchIndexMkDocs:
+			candidates[kind] = resolveReference(root, "search/search_index.json")
+		case SearchIndexSphinx:
+			candidates[kind] = resolveReference(root, "searchindex.js")
+		case SearchIndexDocusaurus:
+			candidates[kind] = resolveReference(pageURL, "/search-index.json")
+		case SearchIndexVitePress:
+			if indexURL := si.vitepressIndexURL(doc, pageURL); indexURL != "" {
+				candidates[kind] = indexURL
+			}
+		}
+	}
+	return candidates
+}
+
+// vitepressIndexURL finds the local search index chunk of a VitePress site.
+// The chunk name is hashed, so it is looked up in the theme scripts.
+func (si *searchIndex) vitepressIndexURL(doc *goquery.Selection, pageURL *url.URL) string {
+	var scripts []string
+	doc.Find("script[src], link[rel='modulepreload'][href]").Each(func(i int, s *goquery.Selection) {
+		src := s.AttrOr("src", s.AttrOr("href", ""))
+		if strings.Contains(src, "@localSearchIndex") {
+			scripts = append([]string{src}, scripts...)
+		} else if strings.Contains(path.Base(src), "theme.") || strings.Contains(src, "VPLocalSearchBox") {
+			scripts = append(scripts, src)
+		}
+	})
+
+	for _, src := range scripts {
+		scriptURL := resolveReference(pageURL, src)
+		if vitepressIndexRe.MatchString(src) {
+			return scriptURL
+		}
+		if !si.claim(scriptURL) {
+			continue
+		}
+		data, err := si.fetch(scriptURL)
+		if err != nil {
+			continue
+		}
+		if name := vitepressIndexRe.Find(data); name != nil {
+			return resolveReference(parseURLOrEmpty(scriptURL), "./"+string(name))
+		}
+		// The theme chunk only loads the search box, which in turn loads the index
+		if name := vitepressSearchRe.Find(data); name != nil {
+			boxURL := resolveReference(parseURLOrEmpty(scriptURL), "./"+string(name))
+			if !si.claim(boxURL) {
+				continue
+			}
+			if data, err := si.fetch(boxURL); err == nil {
+				if name := vitepressIndexRe.Find(data); name != nil {
+					return resolveReference(parseURLOrEmpty(boxURL), "./"+string(name))
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// load fetches and parses one search index
+func (si *searchIndex) load(kind, indexURL string, pageURL *url.URL) ([]*searchIndexPage, error) {
+	data, err := si.fetch(indexURL)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := url.Parse(indexURL)
+	if err != nil {
+		return nil, err
+	}
+	root := index.ResolveReference(&url.URL{Path: "./"})
+
+	var pages []*searchIndexPage
+	switch kind {
+	case SearchIndexMkDocs:
+		pages, err = parseMkDocsIndex(data, parseURLOrEmpty(resolveReference(root, "../")))
+	case SearchIndexSphinx:
+		suffix := "/"
+		if strings.HasSuffix(pageURL.Path, ".html") {
+			suffix = ".html"
+		}
+		pages, err = parseSphinxIndex(data, root, suffix)
+	case SearchIndexDocusaurus:
+		pages, err = parseDocusaurusIndex(data, root)
+	case SearchIndexVitePress:
+		pages, err = parseVitePressIndex(data, pageURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Only keep pages on the crawled site
+	var result []*searchIndexPage
+	for _, page := range pages {
+		if parsed, err := url.Parse(page.URL); err == nil && parsed.Host == si.domain {
+			page.Index = indexURL
+			result = append(result, page)
+		}
+	}
+	return result, nil
+}
+
+// fetch downloads a search index or script from the crawl domain
+func (si *searchIndex) fetch(rawURL string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.Host != si.domain {
+		return nil, fmt.Errorf("not on %s", si.domain)
+	}
+	req.Header.Set("User-Agent", si.userAgent)
+
+	resp, err := si.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSearchIndexSize))
+}
+
+// searchIndexRoot returns the root of the docs site a page belongs to, from
+// the hints Sphinx and MkDocs leave in their pages. Without a hint the
+// directory of the crawl's start URL is used.
+func searchIndexRoot(doc *goquery.Selection, pageURL, baseURL *url.URL) *url.URL {
+	var hint string
+	if root, ok := doc.Find("html").Attr("data-content_root"); ok {
+		hint = root
+	} else if root, ok := doc.Find("#documentation_options").Attr("data-url_root"); ok {
+		hint = root
+	} else if config := doc.Find("script#__config").Text(); config != "" {
+		// MkDocs Material keeps the site root in its theme config
+		var parsed struct {
+			Base string `json:"base"`
+		}
+		if json.Unmarshal([]byte(config), &parsed) == nil {
+			hint = parsed.Base
+		}
+	} else {
+		doc.Find("script:not([src])").EachWithBreak(func(i int, s *goquery.Selection) bool {
+			if match := mkdocsBaseURLRe.FindStringSubmatch(s.Text()); match != nil {
+				hint = match[1]
+				return false
+			}
+			return true
+		})
+	}
+
+	if hint == "" {
+		return parseURLOrEmpty(resolveReference(baseURL, "./"))
+	}
+	if !strings.HasSuffix(hint, "/") {
+		hint += "/"
+	}
+	return parseURLOrEmpty(resolveReference(pageURL, hint))
+}
+
+// parseMkDocsIndex reads search/search_index.json. Locations are relative
+// to the site root; entries with a fragment are sections of a page.
+func parseMkDocsIndex(data []byte, root *url.URL) ([]*searchIndexPage, error) {
+	var index struct {
+		Docs []struct {
+			Location string `json:"location"`
+			Title    string `json:"title"`
+			Text     string `json:"text"`
+		} `json:"docs"`
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid MkDocs search index: %w", err)
+	}
+
+	pages := newIndexPageSet()
+	for _, doc := range index.Docs {
+		location, anchor, _ := strings.Cut(doc.Location, "#")
+		page := pages.get(resolveReference(root, location))
+		if page == nil {
+			continue
+		}
+		if anchor == "" {
+			page.Title = doc.Title
+			page.Text = doc.Text
+		} else {
+			page.section(anchor).Title = doc.Title
+			page.section(anchor).Text = doc.Text
+		}
+	}
+	return pages.list(), nil
+}
+
+// parseSphinxIndex reads searchindex.js. It only holds document names and
+// titles, so it is used for discovery; suffix is ".html" or "/" (dirhtml).
+func parseSphinxIndex(data []byte, root *url.URL, suffix string) ([]*searchIndexPage, error) {
+	var docnames, titles []string
+	for _, match := range sphinxArrayRe.FindAllSubmatch(data, -1) {
+		var values []string
+		if err := json.Unmarshal(match[2], &values); err != nil {
+			continue
+		}
+		switch string(match[1]) {
+		case "docnames":
+			if docnames == nil {
+				docnames = values
+			}
+		case "titles":
+			if titles == nil {
+				titles = values
+			}
+		}
+	}
+	if docnames == nil {
+		return nil, fmt.Errorf("invalid Sphinx search index: no docnames")
+	}
+
+	pages := newIndexPageSet()
+	for i, docname := range docnames {
+		location := docname + suffix
+		if suffix == "/" {
+			if docname == "index" {
+				location = ""
+			} else if strings.HasSuffix(docname, "/index") {
+				location = strings.TrimSuffix(docname, "index")
+			}
+		}
+		page := pages.get(resolveReference(root, location))
+		if page != nil && i < len(titles) {
+			page.Title = titles[i]
+		}
+	}
+	return pages.list(), nil
+}
+
+// parseDocusaurusIndex reads search-index.json from docusaurus-search-local.
+// Documents without a parent are pages, documents with a section name hold
+// text and the rest are headings.
+func parseDocusaurusIndex(data []byte, root *url.URL) ([]*searchIndexPage, error) {
+	var index []struct {
+		Documents []struct {
+			Text    string `json:"t"`
+			URL     string `json:"u"`
+			Hash    string `json:"h"`
+			Section string `json:"s"`
+			Parent  *int   `json:"p"`
+		} `json:"documents"`
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid Docusaurus search index: %w", err)
+	}
+
+	pages := newIndexPageSet()
+	for _, group := range index {
+		for _, doc := range group.Documents {
+			page := pages.get(resolveReference(root, doc.URL))
+			if page == nil {
+				continue
+			}
+			anchor := strings.TrimPrefix(doc.Hash, "#")
+			switch {
+			case doc.Parent == nil:
+				page.Title = doc.Text
+			case doc.Section != "" && anchor == "":
+				page.Text = joinIndexText(page.Text, doc.Text)
+			case doc.Section != "":
+				section := page.section(anchor)
+				section.Title = doc.Section
+				section.Text = joinIndexText(section.Text, doc.Text)
+			case anchor != "":
+				page.section(anchor).Title = doc.Text
+			}
+		}
+	}
+	return pages.list(), nil
+}
+
+// parseVitePressIndex reads a VitePress local search chunk, an ES module
+// exporting the serialized MiniSearch index as a string. Only titles are
+// stored, so it is used for discovery.
+func parseVitePressIndex(data []byte, base *url.URL) ([]*searchIndexPage, error) {
+	match := jsStringRe.FindSubmatch(data)
+	if match == nil {
+		return nil, fmt.Errorf("invalid VitePress search index: no index string")
+	}
+	var serialized string
+	if err := json.Unmarshal(match[1], &serialized); err != nil {
+		return nil, fmt.Errorf("invalid VitePress search index: %w", err)
+	}
+
+	var index struct {
+		DocumentIDs  map[string]string `json:"documentIds"`
+		StoredFields map[string]struct {
+			Title  string   `json:"title"`
+			Titles []string `json:"titles"`
+		} `json:"storedFields"`
+	}
+	if err := json.Unmarshal([]byte(serialized), &index); err != nil {
+		return nil, fmt.Errorf("invalid VitePress search index: %w", err)
+	}
+
+	ids := make([]string, 0, len(index.DocumentIDs))
+	for id := range index.DocumentIDs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) < len(ids[j])
+		}
+		return ids[i] < ids[j]
+	})
+
+	pages := newIndexPageSet()
+	for _, id := range ids {
+		location, anchor, _ := strings.Cut(index.DocumentIDs[id], "#")
+		page := pages.get(resolveReference(base, location))
+		if page == nil {
+			continue
+		}
+		fields := index.StoredFields[id]
+		if len(fields.Titles) == 0 && page.Title == "" {
+			page.Title = fields.Title
+		}
+		if anchor != "" {
+			page.section(anchor).Title = fields.Title
+		}
+	}
+	return pages.list(), nil
+}
+
+// indexPageSet collects index entries by page, keeping the index order
+type indexPageSet struct {
+	pages map[string]*searchIndexPage
+	order []*searchIndexPage
+}
+
+func newIndexPageSet() *indexPageSet {
+	return &indexPageSet{pages: make(map[string]*searchIndexPage)}
+}
+
+// get returns the page for an absolute URL, creating it on first use
+func (s *indexPageSet) get(pageURL string) *searchIndexPage {
+	if pageURL == "" {
+		return nil
+	}
+	key := normalizeLinkURL(pageURL)
+	if page, ok := s.pages[key]; ok {
+		return page
+	}
+	page := &searchIndexPage{URL: key}
+	s.pages[key] = page
+	s.order = append(s.order, page)
+	return page
+}
+
+// list returns the pages in the order they were first seen
+func (s *indexPageSet) list() []*searchIndexPage {
+	return s.order
+}
+
+// section returns the section with the given anchor, creating it on first use
+func (p *searchIndexPage) section(anchor string) *searchIndexSection {
+	for _, section := range p.Sections {
+		if section.Anchor == anchor {
+			return section
+		}
+	}
+	section := &searchIndexSection{Anchor: anchor}
+	p.Sections = append(p.Sections, section)
+	return section
+}
+
+// indexTextToMarkdown converts index text, which some generators store as
+// HTML, to Markdown
+func indexTextToMarkdown(text string, base *url.URL) string {
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + text + "</body></html>"))
+	if err != nil {
+		return strings.TrimSpace(text)
+	}
+	return newMarkdownConverter(base).Convert(doc.Find("body"))
+}
+
+// joinIndexText appends a text fragment to the text collected so far
+func joinIndexText(text, fragment string) string {
+	if text == "" {
+		return fragment
+	}
+	return text + "\n\n" + fragment
+}
+
+// resolveReference resolves ref against base, returning "" if ref can't be parsed
+func resolveReference(base *url.URL, ref string) string {
+	parsed, err := url.Parse(ref)
+	if err != nil || base == nil {
+		return ""
+	}
+	return base.ResolveReference(parsed).String()
+}
+
+// parseURLOrEmpty parses a URL produced by resolveReference, returning an
+// empty URL if it can't be parsed. Requests for URLs resolved against the
+// empty URL have no host, so fetch refuses them.
+func parseURLOrEmpty(rawURL string) *url.URL {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return &url.URL{}
+	}
+	return parsed
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseSearchIndexes(t *testing.T) {
+	root, _ := url.Parse("https://docs.example.com/v1/")
+
+	tests := []struct {
+		name     string
+		parse    func() ([]*searchIndexPage, error)
+		expected []string
+		titles   []string
+	}{
+		{
+			name: "MkDocs",
+			parse: func() ([]*searchIndexPage, error) {
+				return parseMkDocsIndex([]byte(`{"config":{},"docs":[
+					{"location":"","title":"Home","text":"Welcome"},
+					{"location":"guide/install/","title":"Install","text":"<p>Run it</p>"},
+					{"location":"guide/install/#linux","title":"Linux","text":"apt install"}]}`), root)
+			},
+			expected: []string{"https://docs.example.com/v1/", "https://docs.example.com/v1/guide/install/"},
+			titles:   []string{"Home", "Install"},
+		},
+		{
+			name: "Sphinx html builder",
+			parse: func() ([]*searchIndexPage, error) {
+				return parseSphinxIndex([]byte(`Search.setIndex({"alltitles":{},"docnames":["index","usage/quickstart"],"titles":["Welcome","Quickstart"]})`), root, ".html")
+			},
+			expected: []string{"https://docs.example.com/v1/index.html", "https://docs.example.com/v1/usage/quickstart.html"},
+			titles:   []string{"Welcome", "Quickstart"},
+		},
+		{
+			name: "Sphinx dirhtml builder with unquoted keys",
+			parse: func() ([]*searchIndexPage, error) {
+				return parseSphinxIndex([]byte(`Search.setIndex({docnames:["index","api/index"],filenames:["index.rst","api/index.rst"],titles:["Welcome","API"]})`), root, "/")
+			},
+			expected: []string{"https://docs.example.com/v1/", "https://docs.example.com/v1/api/"},
+			titles:   []string{"Welcome", "API"},
+		},
+		{
+			name: "Docusaurus",
+			parse: func() ([]*searchIndexPage, error) {
+				return parseDocusaurusIndex([]byte(`[
+					{"documents":[{"i":1,"t":"Intro","u":"/docs/intro","b":["Docs"]}]},
+					{"documents":[{"i":2,"t":"Setup","u":"/docs/intro","h":"#setup","p":1}]},
+					{"documents":[{"i":3,"t":"Install the CLI.","s":"Setup","u":"/docs/intro","h":"#setup","p":1}]}]`), root)
+			},
+			expected: []string{"https://docs.example.com/docs/intro"},
+			titles:   []string{"Intro"},
+		},
+		{
+			name: "VitePress",
+			parse: func() ([]*searchIndexPage, error) {
+				return parseVitePressIndex([]byte(`const t="{\"documentCount\":2,\"documentIds\":{\"0\":\"/guide/#getting-started\",\"1\":\"/guide/#install\"},\"storedFields\":{\"0\":{\"title\":\"Getting Started\",\"titles\":[]},\"1\":{\"title\":\"Install\",\"titles\":[\"Getting Started\"]}}}";export{t as default};`), root)
+			},
+			expected: []string{"https://docs.example.com/guide/"},
+			titles:   []string{"Getting Started"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages, err := tt.parse()
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			var urls, titles []string
+			for _, page := range pages {
+				urls = append(urls, page.URL)
+				titles = append(titles, page.Title)
+			}
+			if !reflect.DeepEqual(urls, tt.expected) {
+				t.Errorf("urls = %v, want %v", urls, tt.expected)
+			}
+			if !reflect.DeepEqual(titles, tt.titles) {
+				t.Errorf("titles = %v, want %v", titles, tt.titles)
+			}
+		})
+	}
+}
+
+func TestSearchIndexDiscover(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/search/search_index.json":
+			w.Write([]byte(`{"docs":[
+				{"location":"","title":"Home","text":"Welcome"},
+				{"location":"hidden/","title":"Hidden","text":""},
+				{"location":"hidden/#usage","title":"Usage","text":"<p>Call <code>run()</code> to start.</p>"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	pageURL, _ := url.Parse(server.URL + "/docs/guide/")
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(
+		`<html><head><meta name="generator" content="mkdocs-1.5.3"></head><body><script>var base_url = "..";</script></body></html>`))
+	if err != nil {
+		t.Fatalf("failed to parse HTML: %v", err)
+	}
+
+	manifest := NewManifest(server.URL, pageURL.Host, t.TempDir(), CrawlConfig{})
+	index := newSearchIndex(server.Client(), pageURL, CrawlConfig{}, manifest)
+
+	pages := index.Discover(doc.Selection, pageURL, detectFramework(doc.Selection))
+	if len(pages) != 2 || pages[1].URL != server.URL+"/docs/hidden/" {
+		t.Fatalf("Discover() = %v", pages)
+	}
+	if pages[1].Index != server.URL+"/docs/search/search_index.json" {
+		t.Errorf("Index = %q", pages[1].Index)
+	}
+	if len(manifest.SearchIndexes) != 1 || manifest.SearchIndexes[0].Kind != SearchIndexMkDocs {
+		t.Errorf("SearchIndexes = %+v", manifest.SearchIndexes)
+	}
+
+	// The index is only fetched once
+	if again := index.Discover(doc.Selection, pageURL, detectFramework(doc.Selection)); len(again) != 0 {
+		t.Errorf("second Discover() = %v", again)
+	}
+
+	if text := index.PageText(server.URL + "/docs/hidden/"); text != "## Usage\n\nCall `run()` to start." {
+		t.Errorf("PageText() = %q", text)
+	}
+}
+
+func TestSearchIndexStaysOnDomain(t *testing.T) {
+	var offHost int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&offHost, 1)
+		w.Write([]byte(`import("./@localSearchIndexroot.a1b2c3.js")`))
+	}))
+	defer other.Close()
+
+	pageURL, _ := url.Parse("http://docs.example.com/guide/")
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(
+		`<html><head><script type="module" src="` + other.URL + `/assets/theme.d4e5f6.js"></script></head><body></body></html>`))
+	if err != nil {
+		t.Fatalf("failed to parse HTML: %v", err)
+	}
+
+	manifest := NewManifest(pageURL.String(), pageURL.Host, t.TempDir(), CrawlConfig{})
+	index := newSearchIndex(other.Client(), pageURL, CrawlConfig{}, manifest)
+
+	if indexURL := index.vitepressIndexURL(doc.Selection, pageURL); indexURL != "" {
+		t.Errorf("vitepressIndexURL() = %q from an off-host theme script", indexURL)
+	}
+	if _, err := index.fetch(other.URL + "/search-index.json"); err == nil {
+		t.Error("fetch() of an off-host index succeeded")
+	}
+	if n := atomic.LoadInt32(&offHost); n != 0 {
+		t.Errorf("made %d requests to another host, want 0", n)
+	}
+}
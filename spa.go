@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Single-page app frameworks whose server-side payloads can be read
+const (
+	SPANext   = "nextjs"
+	SPANuxt   = "nuxt"
+	SPAGatsby = "gatsby"
+)
+
+// MetaSPA records which framework's payload a page was saved from
+const MetaSPA = "spa"
+
+const (
+	// minPayloadLength is the shortest payload field considered page content
+	minPayloadLength = 200
+	// maxPageDataSize caps how much of a Gatsby page-data.json is read
+	maxPageDataSize = 16 * 1024 * 1024
+)
+
+var (
+	nuxtAssignRe   = regexp.MustCompile(`window\.__NUXT__\s*=\s*`)
+	jsPropertyRe   = regexp.MustCompile(`([A-Za-z_$][\w$]*)\s*:\s*("(?:[^"\\]|\\.)*")`)
+	htmlFragmentRe = regexp.MustCompile(`(?i)<(p|h[1-6]|ul|ol|pre|div|section|article|table)[\s>]`)
+	compiledMDXRe  = regexp.MustCompile(`\b(_jsx|_jsxs|mdx|React\.createElement)\(|function MDXContent`)
+	mdxStatementRe = regexp.MustCompile(`(?m)^(import|export)\s.*$\n?`)
+	frontMatterRe  = regexp.MustCompile(`(?s)\A---\n.*?\n---\n`)
+	pageDataPathRe = regexp.MustCompile(`window\.pagePath\s*=\s*"([^"]*)"`)
+	gatsbyPrefixRe = regexp.MustCompile(`window\.___pathPrefix\s*=\s*"([^"]*)"`)
+)
+
+// payloadHTMLKeys hold rendered HTML in common CMS and MDX pipelines
+var payloadHTMLKeys = map[string]bool{
+	"html": true, "bodyhtml": true, "contenthtml": true, "renderedhtml": true, "rendered": true,
+}
+
+// payloadTextKeys hold Markdown, MDX source or plain text
+var payloadTextKeys = map[string]bool{
+	"markdown": true, "rawmarkdownbody": true, "rawbody": true, "mdx": true, "body": true,
+	"content": true, "source": true, "text": true,
+}
+
+// spaPayload is the content found in a single-page app's server-side data
+type spaPayload struct {
+	Framework string
+	Title     string
+	HTML      string // rendered HTML, converted like the page itself
+	Markdown  string // Markdown, MDX source or plain text
+}
+
+// spaExtractor reads the data Next.js, Nuxt and Gatsby embed in or ship
+// next to their pages, so their content can be saved without running JavaScript
+type spaExtractor struct {
+	client    *http.Client
+	userAgent string
+}
+
+// newSPAExtractor creates an extractor that fetches Gatsby page data with client
+func newSPAExtractor(client *http.Client, userAgent string) *spaExtractor {
+	return &spaExtractor{client: client, userAgent: userAgent}
+}
+
+// Extract finds the page content in a single-page app's payload. It returns
+// nil if the page has no payload or none of its fields look like content.
+func (s *spaExtractor) Extract(doc *goquery.Selection, pageURL *url.URL) *spaPayload {
+	framework, data := s.payload(doc, pageURL)
+	if data == nil {
+		return nil
+	}
+
+	payload := findPayloadContent(data)
+	if payload == nil {
+		return nil
+	}
+	payload.Framework = framework
+	return payload
+}
+
+// payload decodes the server-side data of a page
+func (s *spaExtractor) payload(doc *goquery.Selection, pageURL *url.URL) (string, interface{}) {
+	if script := doc.Find("script#__NEXT_DATA__"); script.Length() > 0 {
+		var data interface{}
+		if json.Unmarshal([]byte(script.Text()), &data) != nil {
+			return "", nil
+		}
+		if props := lookupPath(data, []string{"props", "pageProps"}); props != nil {
+			return SPANext, props
+		}
+		return SPANext, data
+	}
+
+	if script := doc.Find("script#__NUXT_DATA__"); script.Length() > 0 {
+		var flat []interface{}
+		if json.Unmarshal([]byte(script.Text()), &flat) != nil {
+			return "", nil
+		}
+		return SPANuxt, unflattenDevalue(flat)
+	}
+
+	var nuxt string
+	doc.Find("script:not([src])").EachWithBreak(func(i int, script *goquery.Selection) bool {
+		text := script.Text()
+		if loc := nuxtAssignRe.FindStringIndex(text); loc != nil {
+			nuxt = strings.TrimSpace(text[loc[1]:])
+			return false
+		}
+		return true
+	})
+	if nuxt != "" {
+		return SPANuxt, parseNuxtState(nuxt)
+	}
+
+	if doc.Find("#___gatsby").Length() > 0 && s.client != nil {
+		if data := s.gatsbyPageData(doc, pageURL); data != nil {
+			return SPAGatsby, data
+		}
+	}
+	return "", nil
+}
+
+// gatsbyPageData fetches the page-data.json Gatsby loads for a page
+func (s *spaExtractor) gatsbyPageData(doc *goquery.Selection, pageURL *url.URL) interface{} {
+	pagePath, prefix := pageURL.Path, ""
+	doc.Find("script:not([src])").Each(func(i int, script *goquery.Selection) {
+		if match := pageDataPathRe.FindStringSubmatch(script.Text()); match != nil {
+			pagePath = match[1]
+		}
+		if match := gatsbyPrefixRe.FindStringSubmatch(script.Text()); match != nil {
+			prefix = match[1]
+		}
+	})
+	pagePath = strings.TrimPrefix(pagePath, prefix)
+
+	dataPath := strings.Trim(pagePath, "/")
+	if dataPath == "" {
+		dataPath = "index"
+	}
+	dataURL := pageURL.ResolveReference(&url.URL{Path: prefix + "/page-data/" + dataPath + "/page-data.json"})
+
+	req, err := http.NewRequest(http.MethodGet, dataURL.String(), nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	var data interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxPageDataSize)).Decode(&data); err != nil {
+		return nil
+	}
+	if result := lookupPath(data, []string{"result"}); result != nil {
+		return result
+	}
+	return data
+}
+
+// parseNuxtState reads a Nuxt 2 window.__NUXT__ assignment. Plain object
+// literals are decoded as JSON; the minified function form can't be
+// evaluated, so its quoted property values are collected instead.
+func parseNuxtState(script string) interface{} {
+	script = strings.TrimSuffix(strings.TrimSpace(script), ";")
+	var data interface{}
+	if json.Unmarshal([]byte(script), &data) == nil {
+		return data
+	}
+
+	state := make(map[string]interface{})
+	var values []interface{}
+	for _, match := range jsPropertyRe.FindAllStringSubmatch(script, -1) {
+		var value string
+		if json.Unmarshal([]byte(match[2]), &value) != nil {
+			continue
+		}
+		values = append(values, map[string]interface{}{match[1]: value})
+	}
+	state["properties"] = values
+	return state
+}
+
+// unflattenDevalue rebuilds the value serialized by devalue, the format of
+// Nuxt 3's __NUXT_DATA__: a flat array where objects and arrays refer to
+// other entries by index, and reactive wrappers are tagged arrays
+func unflattenDevalue(flat []interface{}) interface{} {
+	if len(flat) == 0 {
+		return nil
+	}
+
+	hydrated := make(map[int]interface{})
+	var hydrate func(index int, depth int) interface{}
+	hydrate = func(index int, depth int) interface{} {
+		if index < 0 || index >= len(flat) || depth > 64 {
+			return nil
+		}
+		if value, ok := hydrated[index]; ok {
+			return value
+		}
+
+		var result interface{}
+		switch value := flat[index].(type) {
+		case map[string]interface{}:
+			obj := make(map[string]interface{}, len(value))
+			hydrated[index] = obj
+			for key, ref := range value {
+				if i, ok := ref.(float64); ok {
+					obj[key] = hydrate(int(i), depth+1)
+				}
+			}
+			return obj
+		case []interface{}:
+			if isDevalueTag(value) {
+				// Reactive, ShallowReactive, Ref, ... wrap a single value;
+				// Date, Set and other built-ins aren't content
+				if len(value) == 2 {
+					if i, ok := value[1].(float64); ok {
+						result = hydrate(int(i), depth+1)
+					}
+				}
+				break
+			}
+			items := make([]interface{}, 0, len(value))
+			for _, ref := range value {
+				if i, ok := ref.(float64); ok {
+					items = append(items, hydrate(int(i), depth+1))
+				}
+			}
+			result = items
+		default:
+			result = value
+		}
+		hydrated[index] = result
+		return result
+	}
+
+	return hydrate(0, 0)
+}
+
+// isDevalueTag reports whether a devalue array is a tagged value rather than a list
+func isDevalueTag(values []interface{}) bool {
+	if len(values) == 0 {
+		return false
+	}
+	_, ok := values[0].(string)
+	return ok
+}
+
+// payloadCandidate is a string field that may hold the page content
+type payloadCandidate struct {
+	key   string
+	value string
+	html  bool
+}
+
+// findPayloadContent picks the longest field of a payload that holds HTML,
+// Markdown or text, along with the nearest title
+func findPayloadContent(data interface{}) *spaPayload {
+	var candidates []payloadCandidate
+	var title string
+
+	var walk func(v interface{}, depth int)
+	walk = func(v interface{}, depth int) {
+		if depth > 32 {
+			return
+		}
+		switch value := v.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(value))
+			for key := range value {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				field := value[key]
+				text, ok := field.(string)
+				if !ok {
+					walk(field, depth+1)
+					continue
+				}
+				lower := strings.ToLower(key)
+				if lower == "title" && title == "" {
+					title = strings.TrimSpace(text)
+				}
+				if len(text) < minPayloadLength {
+					continue
+				}
+				looksHTML := htmlFragmentRe.MatchString(text)
+				switch {
+				case payloadHTMLKeys[lower] || (payloadTextKeys[lower] && looksHTML):
+					candidates = append(candidates, payloadCandidate{key: key, value: text, html: true})
+				case payloadTextKeys[lower] && !compiledMDXRe.MatchString(text):
+					candidates = append(candidates, payloadCandidate{key: key, value: text})
+				}
+			}
+		case []interface{}:
+			for _, item := range value {
+				walk(item, depth+1)
+			}
+		}
+	}
+	walk(data, 0)
+
+	if len(candidates) == 0 {
+		return nil
+	}
+	best := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if len(candidate.value) > len(best.value) {
+			best = candidate
+		}
+	}
+
+	payload := &spaPayload{Title: title}
+	if best.html {
+		payload.HTML = best.value
+	} else {
+		payload.Markdown = cleanMDXSource(best.value)
+	}
+	return payload
+}
+
+// cleanMDXSource drops the front matter and import/export statements of MDX
+// source, leaving the Markdown body
+func cleanMDXSource(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = frontMatterRe.ReplaceAllString(source, "")
+	source = mdxStatementRe.ReplaceAllString(source, "")
+	return strings.TrimSpace(source)
+}
+
+// lookupPath follows a path of object keys through decoded JSON
+func lookupPath(data interface{}, path []string) interface{} {
+	for _, key := range path {
+		obj, ok := data.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if data, ok = obj[key]; !ok {
+			return nil
+		}
+	}
+	return data
+}
+
+// String describes the payload for logging
+func (p *spaPayload) String() string {
+	kind := "markdown"
+	if p.HTML != "" {
+		kind = "html"
+	}
+	return fmt.Sprintf("%s %s payload", p.Framework, kind)
+}
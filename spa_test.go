@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestSPAExtract(t *testing.T) {
+	body := strings.Repeat("Configure the client before the first request. ", 6)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page-data/docs/setup/page-data.json" {
+			w.Write([]byte(`{"componentChunkName":"component---src-templates-doc-js","path":"/docs/setup/",
+				"result":{"data":{"markdownRemark":{"frontmatter":{"title":"Setup"},"html":"<h2>Setup</h2><p>` + body + `</p>"}}}}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		html      string
+		framework string
+		title     string
+		htmlBody  bool
+		contains  string
+	}{
+		{
+			name: "Next.js MDX source",
+			html: `<div id="__next"></div><script id="__NEXT_DATA__" type="application/json">{"props":{"pageProps":{
+				"title":"Install","mdx":"---\ntitle: Install\n---\nimport { Tabs } from 'nextra'\n\n# Install\n\n` + body + `"}},"page":"/docs/[slug]"}</script>`,
+			framework: SPANext,
+			title:     "Install",
+			contains:  "# Install\n\nConfigure",
+		},
+		{
+			name: "Next.js compiled MDX is skipped for HTML",
+			html: `<div id="__next"></div><script id="__NEXT_DATA__" type="application/json">{"props":{"pageProps":{
+				"compiled":{"source":"function MDXContent(props){return _jsx(\"p\",{children:\"` + body + `\"})}"},
+				"post":{"contentHtml":"<p>` + body + `</p>"}}}}</script>`,
+			framework: SPANext,
+			htmlBody:  true,
+			contains:  "<p>Configure",
+		},
+		{
+			name:      "Nuxt 3 devalue payload",
+			html:      `<div id="__nuxt"></div><script type="application/json" id="__NUXT_DATA__">[["ShallowReactive",1],{"data":2},["ShallowReactive",3],{"page":4},{"title":5,"body":6},"Routing","` + body + `"]</script>`,
+			framework: SPANuxt,
+			title:     "Routing",
+			contains:  "Configure the client",
+		},
+		{
+			name:      "Nuxt 2 function payload",
+			html:      `<div id="__nuxt"></div><script>window.__NUXT__=(function(a,b){return {data:[{page:{title:"Guide",html:"<p>` + body + `</p>"}}],state:a}}(null,false));</script>`,
+			framework: SPANuxt,
+			title:     "Guide",
+			htmlBody:  true,
+			contains:  "<p>Configure",
+		},
+		{
+			name:      "Gatsby page data",
+			html:      `<div id="___gatsby"></div><script>window.pagePath="/docs/setup/";</script>`,
+			framework: SPAGatsby,
+			title:     "Setup",
+			htmlBody:  true,
+			contains:  "<h2>Setup</h2>",
+		},
+	}
+
+	extractor := newSPAExtractor(server.Client(), "test")
+	pageURL, _ := url.Parse(server.URL + "/docs/setup/")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + tt.html + "</body></html>"))
+			if err != nil {
+				t.Fatalf("failed to parse HTML: %v", err)
+			}
+			payload := extractor.Extract(doc.Selection, pageURL)
+			if payload == nil {
+				t.Fatal("Extract() = nil")
+			}
+			if payload.Framework != tt.framework {
+				t.Errorf("Framework = %q, want %q", payload.Framework, tt.framework)
+			}
+			if payload.Title != tt.title {
+				t.Errorf("Title = %q, want %q", payload.Title, tt.title)
+			}
+			content := payload.Markdown
+			if tt.htmlBody {
+				content = payload.HTML
+			}
+			if (payload.HTML != "") != tt.htmlBody || !strings.Contains(content, tt.contains) {
+				t.Errorf("content = %q (html: %v), want it to contain %q", content, payload.HTML != "", tt.contains)
+			}
+			if strings.Contains(payload.Markdown, "import") || strings.Contains(payload.Markdown, "title:") {
+				t.Errorf("MDX statements or front matter left in %q", payload.Markdown)
+			}
+		})
+	}
+}
+
+func TestSPAExtractWithoutPayload(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body><div id="root"></div></body></html>`))
+	if err != nil {
+		t.Fatalf("failed to parse HTML: %v", err)
+	}
+	if payload := newSPAExtractor(nil, "").Extract(doc.Selection, &url.URL{}); payload != nil {
+		t.Errorf("Extract() = %+v, want nil", payload)
+	}
+}
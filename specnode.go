@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of specNode
+const (
+	specScalar = iota
+	specObject
+	specArray
+)
+
+// specNode is a JSON or YAML value that keeps the order of object keys, so
+// operations and properties are rendered in the order the spec lists them.
+// Its methods are safe to call on nil.
+type specNode struct {
+	kind   int
+	value  any // string, number, bool or nil
+	keys   []string
+	fields map[string]*specNode
+	items  []*specNode
+}
+
+// scalarNode wraps a string, number, bool or nil
+func scalarNode(value any) *specNode {
+	return &specNode{kind: specScalar, value: value}
+}
+
+// Get returns a field of an object, or nil
+func (n *specNode) Get(key string) *specNode {
+	if n == nil || n.kind != specObject {
+		return nil
+	}
+	return n.fields[key]
+}
+
+// Keys returns the keys of an object in document order
+func (n *specNode) Keys() []string {
+	if n == nil || n.kind != specObject {
+		return nil
+	}
+	return n.keys
+}
+
+// Items returns the elements of an array
+func (n *specNode) Items() []*specNode {
+	if n == nil || n.kind != specArray {
+		return nil
+	}
+	return n.items
+}
+
+// String returns a scalar as text; objects, arrays and null are ""
+func (n *specNode) String() string {
+	if n == nil || n.kind != specScalar || n.value == nil {
+		return ""
+	}
+	switch value := n.value.(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	}
+	return fmt.Sprint(n.value)
+}
+
+// Bool reports whether a scalar is true
+func (n *specNode) Bool() bool {
+	if n == nil {
+		return false
+	}
+	value, _ := n.value.(bool)
+	return value
+}
+
+// JSON renders the value as indented JSON
+func (n *specNode) JSON() (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(n); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// MarshalJSON encodes the value with object keys in document order
+func (n *specNode) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	encode := func(value any) error {
+		if child, ok := value.(*specNode); ok && child != nil {
+			data, err := child.MarshalJSON()
+			buf.Write(data)
+			return err
+		}
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+		buf.Truncate(buf.Len() - 1) // Encode ends with a newline
+		return nil
+	}
+
+	switch n.kind {
+	case specObject:
+		buf.WriteByte('{')
+		for i, key := range n.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encode(key); err != nil {
+				return nil, err
+			}
+			buf.WriteByte(':')
+			if err := encode(n.fields[key]); err != nil {
+				return nil, err
+			}
+		}
+		buf.WriteByte('}')
+	case specArray:
+		buf.WriteByte('[')
+		for i, item := range n.items {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encode(item); err != nil {
+				return nil, err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		if err := encode(n.value); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// set adds or replaces a field of an object
+func (n *specNode) set(key string, value *specNode) {
+	if _, exists := n.fields[key]; !exists {
+		n.keys = append(n.keys, key)
+	}
+	n.fields[key] = value
+}
+
+// parseSpecJSON parses a JSON document, keeping the order of object keys
+func parseSpecJSON(data []byte) (*specNode, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return readSpecJSON(decoder)
+}
+
+// readSpecJSON reads the next value from a JSON decoder
+func readSpecJSON(decoder *json.Decoder) (*specNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		node := &specNode{kind: specObject, fields: make(map[string]*specNode)}
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := keyToken.(string)
+			value, err := readSpecJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.set(key, value)
+		}
+		_, err := decoder.Token()
+		return node, err
+	case json.Delim('['):
+		node := &specNode{kind: specArray}
+		for decoder.More() {
+			value, err := readSpecJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.items = append(node.items, value)
+		}
+		_, err := decoder.Token()
+		return node, err
+	}
+	return scalarNode(token), nil
+}
+
+// parseSpecYAML parses a YAML document, keeping the order of mapping keys
+func parseSpecYAML(data []byte) (*specNode, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return yamlSpecNode(&doc, 0), nil
+}
+
+// yamlSpecNode converts a YAML node, following aliases and merge keys.
+// depth stops alias cycles.
+func yamlSpecNode(n *yaml.Node, depth int) *specNode {
+	if n == nil || depth > 100 {
+		return scalarNode(nil)
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return scalarNode(nil)
+		}
+		return yamlSpecNode(n.Content[0], depth+1)
+	case yaml.AliasNode:
+		return yamlSpecNode(n.Alias, depth+1)
+	case yaml.MappingNode:
+		node := &specNode{kind: specObject, fields: make(map[string]*specNode)}
+		var merged []*specNode
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			value := yamlSpecNode(n.Content[i+1], depth+1)
+			if n.Content[i].Tag == "!!merge" {
+				merged = append(merged, value)
+				merged = append(merged, value.Items()...)
+				continue
+			}
+			node.set(n.Content[i].Value, value)
+		}
+		// Keys of the mapping take precedence over merged ones
+		for _, source := range merged {
+			for _, key := range source.Keys() {
+				if _, exists := node.fields[key]; !exists {
+					node.set(key, source.Get(key))
+				}
+			}
+		}
+		return node
+	case yaml.SequenceNode:
+		node := &specNode{kind: specArray}
+		for _, child := range n.Content {
+			node.items = append(node.items, yamlSpecNode(child, depth+1))
+		}
+		return node
+	}
+	var value any
+	if err := n.Decode(&value); err != nil {
+		value = n.Value
+	}
+	return scalarNode(value)
+}
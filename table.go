@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxTableSpan caps colspan/rowspan values so malformed tables can't blow up
+const maxTableSpan = 50
+
+// tableCell is one position in the expanded table grid
+type tableCell struct {
+	text    string
+	header  bool
+	align   string
+	set     bool // position has been filled
+	covered bool // filled by a colspan/rowspan from another cell
+}
+
+// tableAttrs are the attributes kept when a table falls back to HTML
+var tableAttrs = map[string]bool{
+	"colspan": true, "rowspan": true, "href": true, "src": true, "alt": true, "scope": true,
+}
+
+// renderTable renders a table as a GitHub-flavoured Markdown table, falling
+// back to simplified HTML for tables Markdown can't represent
+func (mc *markdownConverter) renderTable(n *html.Node) string {
+	if strings.EqualFold(getAttr(n, "role"), "presentation") {
+		return joinBlocks(mc.renderBlocks(n), false)
+	}
+
+	rows := tableRows(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	// Layout tables with a single cell are just containers
+	if len(rows) == 1 && len(tableCells(rows[0])) == 1 {
+		return joinBlocks(mc.renderBlocks(tableCells(rows[0])[0]), false)
+	}
+
+	var caption string
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == atom.Caption {
+			caption = cleanInline(mc.renderInlineChildren(child))
+		}
+	}
+
+	var table string
+	if needsHTMLTable(rows) {
+		table = mc.simplifiedTableHTML(n)
+	} else {
+		table = mc.renderGFMTable(rows)
+	}
+	if table == "" {
+		return ""
+	}
+	if caption != "" {
+		return "**" + caption + "**\n\n" + table
+	}
+	return table
+}
+
+// renderGFMTable lays the rows out on a grid and renders it with a single header row
+func (mc *markdownConverter) renderGFMTable(rows []*html.Node) string {
+	grid := mc.buildTableGrid(rows)
+	if len(grid) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range grid {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	if columns == 0 {
+		return ""
+	}
+	for i := range grid {
+		for len(grid[i]) < columns {
+			grid[i] = append(grid[i], tableCell{})
+		}
+	}
+
+	// Leading rows made entirely of header cells form the header;
+	// multiple header rows are merged column by column
+	headerRows := 0
+	for headerRows < len(grid) && isHeaderRow(grid[headerRows]) {
+		headerRows++
+	}
+	if headerRows == len(grid) && headerRows > 0 {
+		headerRows = 1
+	}
+
+	header := make([]string, columns)
+	aligns := make([]string, columns)
+	for col := 0; col < columns; col++ {
+		var parts []string
+		for row := 0; row < headerRows; row++ {
+			cell := grid[row][col]
+			if aligns[col] == "" {
+				aligns[col] = cell.align
+			}
+			if cell.covered || cell.text == "" {
+				continue
+			}
+			if len(parts) == 0 || parts[len(parts)-1] != cell.text {
+				parts = append(parts, cell.text)
+			}
+		}
+		header[col] = strings.Join(parts, " ")
+		for row := headerRows; row < len(grid) && aligns[col] == ""; row++ {
+			aligns[col] = grid[row][col].align
+		}
+	}
+
+	var sb strings.Builder
+	writeTableRow(&sb, header)
+
+	separators := make([]string, columns)
+	for col, align := range aligns {
+		switch align {
+		case "center":
+			separators[col] = ":---:"
+		case "right":
+			separators[col] = "---:"
+		case "left":
+			separators[col] = ":---"
+		default:
+			separators[col] = "---"
+		}
+	}
+	writeTableRow(&sb, separators)
+
+	for _, row := range grid[headerRows:] {
+		cells := make([]string, columns)
+		empty := true
+		for col, cell := range row {
+			cells[col] = cell.text
+			if cell.text != "" {
+				empty = false
+			}
+		}
+		if !empty {
+			writeTableRow(&sb, cells)
+		}
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// buildTableGrid expands colspan and rowspan into a rectangular grid
+func (mc *markdownConverter) buildTableGrid(rows []*html.Node) [][]tableCell {
+	grid := make([][]tableCell, len(rows))
+	for r, row := range rows {
+		col := 0
+		for _, cell := range tableCells(row) {
+			// Skip positions already filled by a rowspan from above
+			for col < len(grid[r]) && grid[r][col].set {
+				col++
+			}
+
+			colspan := spanAttr(cell, "colspan")
+			rowspan := spanAttr(cell, "rowspan")
+			content := tableCell{
+				text:   mc.renderTableCell(cell),
+				set:    true,
+				header: cell.DataAtom == atom.Th || parentIs(row, atom.Thead),
+				align:  cellAlign(cell),
+			}
+
+			for dr := 0; dr < rowspan && r+dr < len(rows); dr++ {
+				for dc := 0; dc < colspan; dc++ {
+					placed := tableCell{set: true, covered: true, header: content.header}
+					if dr == 0 && dc == 0 {
+						placed = content
+					}
+					setGridCell(grid, r+dr, col+dc, placed)
+				}
+			}
+			col += colspan
+		}
+	}
+	return grid
+}
+
+// renderTableCell renders cell content on a single line
+func (mc *markdownConverter) renderTableCell(cell *html.Node) string {
+	var parts []string
+	for _, block := range mc.renderBlocks(cell) {
+		text := strings.ReplaceAll(block.text, "  \n", "<br>")
+		text = strings.ReplaceAll(text, "\n", "<br>")
+		parts = append(parts, text)
+	}
+	text := strings.Join(parts, "<br>")
+	return strings.ReplaceAll(text, "|", `\|`)
+}
+
+// tableRows returns the rows of a table in document order, ignoring nested tables
+func tableRows(table *html.Node) []*html.Node {
+	var rows []*html.Node
+	for child := table.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		switch child.DataAtom {
+		case atom.Tr:
+			rows = append(rows, child)
+		case atom.Thead, atom.Tbody, atom.Tfoot:
+			for tr := child.FirstChild; tr != nil; tr = tr.NextSibling {
+				if tr.Type == html.ElementNode && tr.DataAtom == atom.Tr {
+					rows = append(rows, tr)
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// tableCells returns the th/td cells of a row
+func tableCells(row *html.Node) []*html.Node {
+	var cells []*html.Node
+	for cell := row.FirstChild; cell != nil; cell = cell.NextSibling {
+		if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+// needsHTMLTable reports whether any cell holds content a GFM cell can't:
+// nested tables or multi-line code blocks
+func needsHTMLTable(rows []*html.Node) bool {
+	for _, row := range rows {
+		for _, cell := range tableCells(row) {
+			if len(findAll(cell, atom.Table)) > 0 {
+				return true
+			}
+			for _, pre := range findAll(cell, atom.Pre) {
+				if strings.Contains(strings.TrimSpace(codeText(pre)), "\n") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// simplifiedTableHTML renders a table as HTML with presentational attributes
+// removed and links made absolute
+func (mc *markdownConverter) simplifiedTableHTML(table *html.Node) string {
+	clone := cloneNode(table)
+	var strip func(*html.Node)
+	strip = func(n *html.Node) {
+		var attrs []html.Attribute
+		for _, attr := range n.Attr {
+			if !tableAttrs[attr.Key] {
+				continue
+			}
+			if attr.Key == "href" || attr.Key == "src" {
+				attr.Val = mc.resolveURL(attr.Val)
+			}
+			attrs = append(attrs, attr)
+		}
+		n.Attr = attrs
+
+		for child := n.FirstChild; child != nil; {
+			next := child.NextSibling
+			switch {
+			case child.Type == html.CommentNode,
+				child.Type == html.ElementNode && isSkippedElement(child),
+				child.Type == html.TextNode && n.DataAtom != atom.Pre && strings.TrimSpace(child.Data) == "":
+				// Blank lines would end the HTML block in Markdown
+				n.RemoveChild(child)
+			default:
+				strip(child)
+			}
+			child = next
+		}
+	}
+	strip(clone)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, clone); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// cloneNode deep-copies a node without attaching it to a parent
+func cloneNode(n *html.Node) *html.Node {
+	clone := &html.Node{
+		Type:      n.Type,
+		DataAtom:  n.DataAtom,
+		Data:      n.Data,
+		Namespace: n.Namespace,
+		Attr:      append([]html.Attribute(nil), n.Attr...),
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		clone.AppendChild(cloneNode(child))
+	}
+	return clone
+}
+
+// isHeaderRow reports whether every filled cell in a row is a header cell
+func isHeaderRow(row []tableCell) bool {
+	filled := false
+	for _, cell := range row {
+		if !cell.set {
+			continue
+		}
+		if !cell.header {
+			return false
+		}
+		if !cell.covered {
+			filled = true
+		}
+	}
+	return filled
+}
+
+// writeTableRow writes one pipe-delimited table row
+func writeTableRow(sb *strings.Builder, cells []string) {
+	sb.WriteString("|")
+	for _, cell := range cells {
+		sb.WriteString(" ")
+		sb.WriteString(cell)
+		sb.WriteString(" |")
+	}
+	sb.WriteString("\n")
+}
+
+// setGridCell places a cell, growing the row as needed
+func setGridCell(grid [][]tableCell, row, col int, cell tableCell) {
+	for len(grid[row]) <= col {
+		grid[row] = append(grid[row], tableCell{})
+	}
+	if !grid[row][col].set {
+		grid[row][col] = cell
+	}
+}
+
+// spanAttr reads a colspan/rowspan attribute, defaulting to 1
+func spanAttr(cell *html.Node, key string) int {
+	span, err := strconv.Atoi(strings.TrimSpace(getAttr(cell, key)))
+	if err != nil || span < 1 {
+		return 1
+	}
+	if span > maxTableSpan {
+		return maxTableSpan
+	}
+	return span
+}
+
+// cellAlign reads the alignment of a cell from its align attribute or inline style
+func cellAlign(cell *html.Node) string {
+	if align := strings.ToLower(getAttr(cell, "align")); align != "" {
+		return align
+	}
+	style := strings.ToLower(strings.ReplaceAll(getAttr(cell, "style"), " ", ""))
+	for _, align := range []string{"center", "right", "left"} {
+		if strings.Contains(style, "text-align:"+align) {
+			return align
+		}
+	}
+	return ""
+}
+
+// parentIs reports whether the parent of n is the given element
+func parentIs(n *html.Node, tag atom.Atom) bool {
+	return n.Parent != nil && n.Parent.Type == html.ElementNode && n.Parent.DataAtom == tag
+}
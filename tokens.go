@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenizerEstimate is the --tokenizer value for the built-in estimate;
+// any other value is the path of a tiktoken BPE ranks file
+const TokenizerEstimate = "estimate"
+
+// maxTokenPrefixes is how many path prefixes the report lists before
+// folding the rest into "other"
+const maxTokenPrefixes = 15
+
+// tokenCounter counts how many tokens text takes up for a model
+type tokenCounter func(text string) int
+
+// loadTokenCounter returns the counter for a --tokenizer value: the
+// estimate, or a cl100k BPE tokenizer built from a ranks file such as
+// cl100k_base.tiktoken
+func loadTokenCounter(name string) (tokenCounter, error) {
+	if name == "" || name == TokenizerEstimate {
+		return estimateTokens, nil
+	}
+	tokenizer, err := loadBPETokenizer(name)
+	if err != nil {
+		return nil, err
+	}
+	return tokenizer.CountTokens, nil
+}
+
+// estimateTokens approximates how many tokens text takes up for an LLM
+// tokenizer: about four characters per token for English and code, and a
+// token per character for other scripts
+func estimateTokens(text string) int {
+	tokens := 0
+	for _, word := range strings.Fields(text) {
+		ascii := 0
+		for _, r := range word {
+			if r < utf8.RuneSelf {
+				ascii++
+			} else {
+				tokens++
+			}
+		}
+		tokens += (ascii + 3) / 4
+	}
+	return tokens
+}
+
+// bpeTokenizer counts tokens with byte-level BPE, splitting text the way
+// cl100k_base does before merging
+type bpeTokenizer struct {
+	ranks map[string]int
+}
+
+// loadBPETokenizer reads a tiktoken ranks file: one base64 token and its
+// rank per line
+func loadBPETokenizer(path string) (*bpeTokenizer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tokenizer ranks: %w", err)
+	}
+	defer file.Close()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a token and a rank", path, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid token: %w", path, line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid rank: %w", path, line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tokenizer ranks: %w", err)
+	}
+	if len(ranks) < 256 {
+		return nil, fmt.Errorf("%s: expected at least the 256 single-byte tokens, found %d tokens", path, len(ranks))
+	}
+	return &bpeTokenizer{ranks: ranks}, nil
+}
+
+// CountTokens returns the number of tokens text encodes to
+func (b *bpeTokenizer) CountTokens(text string) int {
+	count := 0
+	for _, piece := range cl100kSplit(text) {
+		count += b.countPiece([]byte(piece))
+	}
+	return count
+}
+
+// countPiece merges the bytes of a piece pairwise, lowest rank first, until
+// no adjacent pair is a token, and returns the number of tokens left. As in
+// tiktoken, the rank of each pair is kept and only the pairs next to a merge
+// are looked up again.
+func (b *bpeTokenizer) countPiece(piece []byte) int {
+	if _, ok := b.ranks[string(piece)]; ok {
+		return 1
+	}
+
+	// Token boundaries, starting with one token per byte, each with the rank
+	// of the pair of tokens that starts there
+	type boundary struct{ start, rank int }
+	bounds := make([]boundary, len(piece)+1)
+	for i := range bounds {
+		bounds[i].start = i
+	}
+	pairRank := func(i int) int {
+		if i+2 < len(bounds) {
+			if rank, ok := b.ranks[string(piece[bounds[i].start:bounds[i+2].start])]; ok {
+				return rank
+			}
+		}
+		return math.MaxInt
+	}
+	for i := range bounds {
+		bounds[i].rank = pairRank(i)
+	}
+
+	for len(bounds) > 2 {
+		best := 0
+		for i := 1; i+2 < len(bounds); i++ {
+			if bounds[i].rank < bounds[best].rank {
+				best = i
+			}
+		}
+		if bounds[best].rank == math.MaxInt {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+		bounds[best].rank = pairRank(best)
+		if best > 0 {
+			bounds[best-1].rank = pairRank(best - 1)
+		}
+	}
+	return len(bounds) - 1
+}
+
+// cl100kSplit splits text into the pieces cl100k_base encodes separately.
+// tiktoken uses the pattern
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// which needs a lookahead Go's regexp doesn't have, so it is matched by hand.
+func cl100kSplit(text string) []string {
+	runes := []rune(text)
+	var pieces []string
+	for i := 0; i < len(runes); {
+		end := cl100kPieceEnd(runes, i)
+		pieces = append(pieces, string(runes[i:end]))
+		i = end
+	}
+	return pieces
+}
+
+// cl100kPieceEnd returns where the piece starting at i ends, trying the
+// alternatives of the cl100k pattern in order
+func cl100kPieceEnd(runes []rune, i int) int {
+	n := len(runes)
+	c := runes[i]
+
+	// Contractions
+	if c == '\'' && i+1 < n {
+		switch first := unicode.ToLower(runes[i+1]); first {
+		case 's', 't', 'm', 'd':
+			return i + 2
+		case 'r', 'v', 'l':
+			if i+2 < n {
+				second := unicode.ToLower(runes[i+2])
+				if (first != 'l' && second == 'e') || (first == 'l' && second == 'l') {
+					return i + 3
+				}
+			}
+		}
+	}
+
+	// Letters, with one leading character that isn't a letter, digit or newline
+	end := i
+	if !unicode.IsLetter(c) && !unicode.IsNumber(c) && c != '\r' && c != '\n' && i+1 < n && unicode.IsLetter(runes[i+1]) {
+		end = i + 1
+	}
+	if unicode.IsLetter(runes[end]) {
+		for end < n && unicode.IsLetter(runes[end]) {
+			end++
+		}
+		return end
+	}
+
+	// Up to three digits
+	if unicode.IsNumber(c) {
+		end = i + 1
+		for end < n && end < i+3 && unicode.IsNumber(runes[end]) {
+			end++
+		}
+		return end
+	}
+
+	// Punctuation, with an optional leading space and the newlines after it
+	end = i
+	if c == ' ' && i+1 < n && isSymbolRune(runes[i+1]) {
+		end = i + 1
+	}
+	if isSymbolRune(runes[end]) {
+		for end < n && isSymbolRune(runes[end]) {
+			end++
+		}
+		for end < n && (runes[end] == '\r' || runes[end] == '\n') {
+			end++
+		}
+		return end
+	}
+
+	// Whitespace: up to the last newline if there is one, otherwise all of
+	// it except the space that goes with the next word
+	end = i
+	for end < n && unicode.IsSpace(runes[end]) {
+		end++
+	}
+	for k := end - 1; k >= i; k-- {
+		if runes[k] == '\r' || runes[k] == '\n' {
+			return k + 1
+		}
+	}
+	if end < n && end-i > 1 {
+		return end - 1
+	}
+	return end
+}
+
+// isSymbolRune reports whether r is neither whitespace, a letter nor a digit
+func isSymbolRune(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// prefixTokens is the token total of the pages under one path prefix
+type prefixTokens struct {
+	Prefix string
+	Pages  int
+	Tokens int
+}
+
+// tokensByPrefix totals page tokens by leading path segments. It starts
+// with the first segment and goes deeper while every page shares the same
+// prefix, so a site kept under /docs/ is broken down by its sections.
+func tokensByPrefix(pages []*PageInfo) []prefixTokens {
+	paths := make([][]string, len(pages))
+	for i, page := range pages {
+		if parsed, err := url.Parse(page.URL); err == nil {
+			// The last segment is the page itself
+			segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+			if !strings.HasSuffix(parsed.Path, "/") {
+				segments = segments[:len(segments)-1]
+			}
+			if len(segments) == 1 && segments[0] == "" {
+				segments = nil
+			}
+			paths[i] = segments
+		}
+	}
+
+	group := func(depth int) map[string]*prefixTokens {
+		groups := make(map[string]*prefixTokens)
+		for i, page := range pages {
+			segments := paths[i]
+			if len(segments) > depth {
+				segments = segments[:depth]
+			}
+			prefix := "/"
+			if len(segments) > 0 {
+				prefix = "/" + strings.Join(segments, "/") + "/"
+			}
+			if groups[prefix] == nil {
+				groups[prefix] = &prefixTokens{Prefix: prefix}
+			}
+			groups[prefix].Pages++
+			groups[prefix].Tokens += page.TokenCount
+		}
+		return groups
+	}
+
+	groups := group(1)
+	for depth := 2; len(groups) == 1; depth++ {
+		deeper := group(depth)
+		if len(deeper) == 1 {
+			var prefix string
+			for p := range deeper {
+				prefix = p
+			}
+			if _, same := groups[prefix]; same {
+				break
+			}
+		}
+		groups = deeper
+	}
+
+	var totals []prefixTokens
+	for _, g := range groups {
+		totals = append(totals, *g)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Tokens != totals[j].Tokens {
+			return totals[i].Tokens > totals[j].Tokens
+		}
+		return totals[i].Prefix < totals[j].Prefix
+	})
+
+	if len(totals) > maxTokenPrefixes {
+		other := prefixTokens{Prefix: "other"}
+		for _, t := range totals[maxTokenPrefixes-1:] {
+			other.Pages += t.Pages
+			other.Tokens += t.Tokens
+		}
+		totals = append(totals[:maxTokenPrefixes-1], other)
+	}
+	return totals
+}
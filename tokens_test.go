@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCl100kSplit(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"Hello world", []string{"Hello", " world"}},
+		{"I'm sure they'RE here", []string{"I", "'m", " sure", " they", "'RE", " here"}},
+		{"Version 12345", []string{"Version", " ", "123", "45"}},
+		{"foo  bar", []string{"foo", " ", " bar"}},
+		{"a\n\n  b", []string{"a", "\n\n", " ", " b"}},
+		{"x = {1};\nreturn", []string{"x", " =", " {", "1", "};\n", "return"}},
+		{"end  ", []string{"end", "  "}},
+		{"(ok)", []string{"(ok", ")"}},
+		{"日本語のテキスト", []string{"日本語のテキスト"}},
+	}
+	for _, tt := range tests {
+		if got := cl100kSplit(tt.text); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("cl100kSplit(%q) = %q, expected %q", tt.text, got, tt.expected)
+		}
+	}
+}
+
+// writeRanks writes a tiktoken ranks file with every single byte followed
+// by the given merged tokens
+func writeRanks(t *testing.T, merges ...string) string {
+	t.Helper()
+	var sb strings.Builder
+	rank := 0
+	for b := 0; b < 256; b++ {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(b)}), rank)
+		rank++
+	}
+	for _, token := range merges {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+		rank++
+	}
+	path := filepath.Join(t.TempDir(), "ranks.tiktoken")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBPETokenizer(t *testing.T) {
+	countTokens, err := loadTokenCounter(writeRanks(t, "ab", "abc", "bc", " world"))
+	if err != nil {
+		t.Fatalf("loadTokenCounter() error: %v", err)
+	}
+
+	tests := map[string]int{
+		"abc":        1, // a token of its own
+		"abcd":       2, // ab, then abc, leaving d
+		"xbc":        2,
+		" abd":       3,
+		"abc world":  2,
+		"abc worlds": 8, // " worlds" is one piece with no merges along the way
+		"abcbc":      2, // ab, then abc and bc on either side of it
+		"":           0,
+
+		strings.Repeat("ab", 5000): 5000,
+	}
+	for text, expected := range tests {
+		if got := countTokens(text); got != expected {
+			t.Errorf("countTokens(%q) = %d, expected %d", text, got, expected)
+		}
+	}
+
+	if _, err := loadTokenCounter(filepath.Join(t.TempDir(), "missing.tiktoken")); err == nil {
+		t.Error("expected an error for a missing ranks file")
+	}
+	broken := filepath.Join(t.TempDir(), "broken.tiktoken")
+	os.WriteFile(broken, []byte("YQ== 0\nnot-base64! 1\n"), 0644)
+	if _, err := loadTokenCounter(broken); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("expected an error for line 2, got %v", err)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := map[string]int{
+		"":                         0,
+		"Install the client":       5,
+		"`crawldocs --max-tokens`": 7,
+		"日本語":                      3,
+	}
+	for text, expected := range tests {
+		if got := estimateTokens(text); got != expected {
+			t.Errorf("estimateTokens(%q) = %d, expected %d", text, got, expected)
+		}
+	}
+}
+
+func TestTokensByPrefix(t *testing.T) {
+	page := func(url string, tokens int) *PageInfo {
+		return &PageInfo{URL: url, TokenCount: tokens}
+	}
+
+	tests := []struct {
+		name     string
+		pages    []*PageInfo
+		expected []prefixTokens
+	}{
+		{
+			name: "first path segment",
+			pages: []*PageInfo{
+				page("https://example.com/", 10),
+				page("https://example.com/about", 5),
+				page("https://example.com/docs/install", 100),
+				page("https://example.com/docs/guide/", 50),
+				page("https://example.com/blog/2024/post", 70),
+			},
+			expected: []prefixTokens{
+				{Prefix: "/docs/", Pages: 2, Tokens: 150},
+				{Prefix: "/blog/", Pages: 1, Tokens: 70},
+				{Prefix: "/", Pages: 2, Tokens: 15},
+			},
+		},
+		{
+			name: "shared prefix is broken down",
+			pages: []*PageInfo{
+				page("https://example.com/docs/en/guide/install", 100),
+				page("https://example.com/docs/en/guide/usage", 100),
+				page("https://example.com/docs/en/api/client", 300),
+				page("https://example.com/docs/en/", 20),
+			},
+			expected: []prefixTokens{
+				{Prefix: "/docs/en/api/", Pages: 1, Tokens: 300},
+				{Prefix: "/docs/en/guide/", Pages: 2, Tokens: 200},
+				{Prefix: "/docs/en/", Pages: 1, Tokens: 20},
+			},
+		},
+		{
+			name:     "single page",
+			pages:    []*PageInfo{page("https://example.com/docs/index.html", 42)},
+			expected: []prefixTokens{{Prefix: "/docs/", Pages: 1, Tokens: 42}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokensByPrefix(tt.pages); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("tokensByPrefix() = %+v, expected %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTokenTotals(t *testing.T) {
+	manifest := NewManifest("https://example.com/", "example.com", t.TempDir(), CrawlConfig{})
+	manifest.AddPage(&PageInfo{URL: "https://example.com/a", Status: "completed", TokenCount: 100})
+	manifest.AddPage(&PageInfo{URL: "https://example.com/b", Status: "completed", TokenCount: 40})
+	manifest.AddPage(&PageInfo{URL: "https://example.com/c", Status: "failed", TokenCount: 7})
+
+	manifest.UpdatePage("https://example.com/a", func(p *PageInfo) { p.TokenCount = 80 })
+	manifest.MarkDuplicate("https://example.com/b", "https://example.com/a", "")
+	if manifest.Statistics.TotalTokens != 80 {
+		t.Errorf("TotalTokens = %d, expected 80", manifest.Statistics.TotalTokens)
+	}
+
+	c := &Crawler{maxTokens: 100}
+	c.tokenCount = 99
+	if c.limitReached() {
+		t.Error("limit reached below the token budget")
+	}
+	c.tokenCount = 100
+	if !c.limitReached() {
+		t.Error("limit not reached at the token budget")
+	}
+}
+
+func TestTokenBudgetNotExceeded(t *testing.T) {
+	manifest := NewManifest("https://example.com/", "example.com", t.TempDir(), CrawlConfig{})
+	c := &Crawler{
+		maxTokens:  10,
+		tokens:     countWords,
+		manifest:   manifest,
+		config:     CrawlConfig{},
+		writeQueue: make(chan writeTask, 4),
+	}
+
+	c.writePage(&PageInfo{URL: "https://example.com/a"}, "one two three four five six", nil, "a.md")
+	c.writePage(&PageInfo{URL: "https://example.com/b"}, "one two three four five six", nil, "b.md")
+	if len(c.writeQueue) != 1 || c.tokenCount != 6 {
+		t.Errorf("queued %d pages with %d tokens, want 1 page with 6", len(c.writeQueue), c.tokenCount)
+	}
+	if page := manifest.Pages["https://example.com/b"]; page == nil || page.Status != "skipped" {
+		t.Errorf("page over the budget recorded as %+v", page)
+	}
+	if !c.limitReached() {
+		t.Error("limit not reached after a page didn't fit")
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// versionSwitcherSelector matches the links and options of version switchers
+// in common docs themes: Read the Docs, Docusaurus, MkDocs Material (mike),
+// PyData Sphinx and the Python docs
+const versionSwitcherSelector = ".rst-versions a[href], .rst-other-versions a[href], " +
+	".md-version__list a[href], .version-switcher__menu a[href], .version_switcher_placeholder a[href], " +
+	".navbar__item.dropdown a.dropdown__link[href], [class*='version'] a[href], [id*='version'] a[href], " +
+	"select[class*='version'] option, select[id*='version'] option"
+
+var (
+	// numericVersionRe matches version numbers such as 3, 3.11, v2.1.0 or 1.x
+	numericVersionRe = regexp.MustCompile(`(?i)^v?\d+(\.(\d+|x))*$`)
+	rtdDataVersionRe = regexp.MustCompile(`READTHEDOCS_DATA\s*=\s*\{[^}]*"version"\s*:\s*"([^"]+)"`)
+	sphinxVersionRe  = regexp.MustCompile(`DOCUMENTATION_OPTIONS\s*=\s*\{[^}]*VERSION\s*:\s*['"]([^'"]+)['"]`)
+)
+
+// versionAliases are the names docs hosts use for moving versions
+var versionAliases = map[string]bool{
+	"latest": true, "stable": true, "dev": true, "devel": true, "development": true,
+	"next": true, "nightly": true, "current": true, "master": true,
+}
+
+// isVersionSegment reports whether a path segment names a docs version
+func isVersionSegment(segment string) bool {
+	return numericVersionRe.MatchString(segment) || versionAliases[strings.ToLower(segment)]
+}
+
+// versionsMatch compares two version names, ignoring case and a leading "v"
+func versionsMatch(a, b string) bool {
+	trim := func(v string) string {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if len(v) > 1 && v[0] == 'v' && v[1] >= '0' && v[1] <= '9' {
+			return v[1:]
+		}
+		return v
+	}
+	return trim(a) == trim(b)
+}
+
+// pathVersion returns the first version segment of a URL path and the path
+// before it ("/en/" for /en/latest/install.html). Aliases such as latest
+// count anywhere. Numeric segments like 3.11 or v2 only count next to a
+// language segment or an alias (/en/3.11/), or when isKnown accepts them,
+// since /api/v1/ and /blog/2024/ aren't docs versions. isKnown may be nil.
+func pathVersion(urlPath string, isKnown func(string) bool) (version, prefix string) {
+	segments := strings.Split(strings.Trim(urlPath, "/"), "/")
+	isMarker := func(i int) bool {
+		return i >= 0 && i < len(segments) && (isLanguageSegment(segments[i]) || versionAliases[strings.ToLower(segments[i])])
+	}
+	for i, segment := range segments {
+		// The last segment is a page, unless the path ends in a slash
+		if i == len(segments)-1 && !strings.HasSuffix(urlPath, "/") {
+			break
+		}
+		isVersion := versionAliases[strings.ToLower(segment)]
+		if !isVersion && numericVersionRe.MatchString(segment) {
+			isVersion = isMarker(i-1) || isMarker(i+1) || (isKnown != nil && isKnown(segment))
+		}
+		if isVersion {
+			return segment, "/" + strings.Join(append(segments[:i:i], ""), "/")
+		}
+	}
+	return "", ""
+}
+
+// declaredVersion returns the version a page declares for Read the Docs or
+// DocSearch, which uses the same names as the site's version switcher
+func declaredVersion(doc *goquery.Selection) string {
+	for _, selector := range []string{"meta[name='docsearch:version']", "meta[name='docusaurus_version']", "meta[name='readthedocs-version-slug']"} {
+		if version := strings.TrimSpace(doc.Find(selector).AttrOr("content", "")); version != "" {
+			return version
+		}
+	}
+	var version string
+	doc.Find("script:not([src])").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if match := rtdDataVersionRe.FindStringSubmatch(s.Text()); match != nil {
+			version = match[1]
+			return false
+		}
+		return true
+	})
+	return version
+}
+
+// pageVersion works out which docs version a page belongs to: from the
+// version it declares, a version segment in the URL, the selected entry of
+// a version switcher, or Sphinx's release number
+func pageVersion(doc *goquery.Selection, pageURL *url.URL) string {
+	if version := declaredVersion(doc); version != "" {
+		return version
+	}
+	// Numeric segments count when the version switcher offers them
+	switcher := versionSwitcher(doc, func(href string) string {
+		ref, err := url.Parse(href)
+		if err != nil {
+			return href
+		}
+		return pageURL.ResolveReference(ref).String()
+	})
+	offered := func(segment string) bool {
+		for _, version := range switcher {
+			if versionsMatch(version, segment) {
+				return true
+			}
+		}
+		return false
+	}
+	if version, _ := pathVersion(pageURL.Path, offered); version != "" {
+		return version
+	}
+
+	if selected := doc.Find("select[class*='version'] option[selected], select[id*='version'] option[selected]").First(); selected.Length() > 0 {
+		if version := strings.TrimSpace(selected.Text()); version != "" {
+			return version
+		}
+	}
+	if active := doc.Find(".navbar__item.dropdown a.dropdown__link--active").First(); active.Length() > 0 {
+		if version := strings.TrimSpace(active.Text()); isVersionSegment(version) {
+			return version
+		}
+	}
+
+	var version string
+	doc.Find("script:not([src])").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if match := sphinxVersionRe.FindStringSubmatch(s.Text()); match != nil {
+			version = match[1]
+			return false
+		}
+		return true
+	})
+	return version
+}
+
+// versionSwitcher lists the versions offered by a page's version switcher,
+// named by the version segment of their URL or their label
+func versionSwitcher(doc *goquery.Selection, resolve func(string) string) []string {
+	var versions []string
+	seen := make(map[string]bool)
+	doc.Find(versionSwitcherSelector).Each(func(i int, s *goquery.Selection) {
+		target := s.AttrOr("href", s.AttrOr("value", ""))
+		label := strings.Join(strings.Fields(s.Text()), " ")
+
+		version := ""
+		if parsed, err := url.Parse(resolve(target)); err == nil && target != "" {
+			// Any version number in a switcher link is a version
+			version, _ = pathVersion(parsed.Path, numericVersionRe.MatchString)
+		}
+		if version == "" && isVersionSegment(label) {
+			version = label
+		}
+		if version == "" || seen[strings.ToLower(version)] {
+			return
+		}
+		seen[strings.ToLower(version)] = true
+		versions = append(versions, version)
+	})
+	return versions
+}
+
+// versionFilter implements --doc-version: it keeps the crawl inside the tree
+// of one docs version. The path before the version segment is learned from
+// the first URL that contains the pinned version; after that, URLs under
+// that path must have the pinned version in the same place.
+type versionFilter struct {
+	version string
+
+	mu     sync.Mutex
+	prefix string // e.g. "/en/" for /en/latest/
+	known  bool
+}
+
+// newVersionFilter creates a filter that keeps pages of version
+func newVersionFilter(version string) *versionFilter {
+	return &versionFilter{version: strings.TrimSpace(version)}
+}
+
+// Learn records where the pinned version appears in the path of rawURL
+func (f *versionFilter) Learn(rawURL string) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.known {
+		return
+	}
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	for i, segment := range segments {
+		if versionsMatch(segment, f.version) && (i < len(segments)-1 || strings.HasSuffix(parsed.Path, "/")) {
+			f.prefix = "/" + strings.Join(append(segments[:i:i], ""), "/")
+			f.known = true
+			return
+		}
+	}
+}
+
+// AllowsURL reports whether a URL is part of the pinned version's tree
+func (f *versionFilter) AllowsURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return true
+	}
+	urlPath := parsed.Path
+	if urlPath == "" {
+		urlPath = "/"
+	}
+
+	f.mu.Lock()
+	prefix, known := f.prefix, f.known
+	f.mu.Unlock()
+
+	if !known {
+		version, _ := pathVersion(urlPath, nil)
+		return version == "" || versionsMatch(version, f.version)
+	}
+	if !strings.HasPrefix(urlPath, prefix) {
+		return true
+	}
+	segment, _, _ := strings.Cut(strings.TrimPrefix(urlPath, prefix), "/")
+	return versionsMatch(segment, f.version)
+}
+
+// Allows reports whether a page that declares version should be saved;
+// pages that declare none are kept
+func (f *versionFilter) Allows(version string) bool {
+	return version == "" || versionsMatch(version, f.version)
+}
+
+// PinURL moves a start URL with a version segment to the pinned version,
+// so https://docs.python.org/3.11/ with --doc-version 3.12 starts at /3.12/.
+// A numeric segment of the start URL counts as a version when the pinned
+// version is numeric too.
+func (f *versionFilter) PinURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	var isKnown func(string) bool
+	if numericVersionRe.MatchString(f.version) {
+		isKnown = numericVersionRe.MatchString
+	}
+	version, prefix := pathVersion(parsed.Path, isKnown)
+	if version == "" || versionsMatch(version, f.version) {
+		return rawURL
+	}
+	parsed.Path = prefix + f.version + strings.TrimPrefix(parsed.Path, prefix+version)
+	parsed.RawPath = ""
+	return parsed.String()
+}
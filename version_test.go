@@ -0,0 +1,182 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestPathVersion(t *testing.T) {
+	known := func(segment string) bool { return segment == "3.11" }
+	tests := []struct {
+		path    string
+		version string
+		prefix  string
+	}{
+		{"/en/latest/install.html", "latest", "/en/"},
+		{"/en/3.11/library/os.html", "3.11", "/en/"},
+		{"/3.11/library/os.html", "3.11", "/"},
+		{"/docs/next/intro", "next", "/docs/"},
+		{"/blog/2024-release", "", ""},
+		{"/api/3.11", "", ""},
+		{"/api/v1/users/", "", ""},
+		{"/blog/2024/release/", "", ""},
+		{"/docs/v2.1.x/", "", ""},
+		{"/pt-br/2.0/intro", "2.0", "/pt-br/"},
+		{"/zh_Hant/4/", "4", "/zh_Hant/"},
+		{"/go/1/", "", ""},
+		{"/js/2/api", "", ""},
+		{"/ui/3/", "", ""},
+	}
+	for _, tt := range tests {
+		version, prefix := pathVersion(tt.path, known)
+		if version != tt.version || prefix != tt.prefix {
+			t.Errorf("pathVersion(%q) = %q, %q, want %q, %q", tt.path, version, prefix, tt.version, tt.prefix)
+		}
+	}
+}
+
+func TestPageVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		url      string
+		expected string
+	}{
+		{
+			name:     "DocSearch meta tag",
+			html:     `<html><head><meta name="docsearch:version" content="current"></head><body></body></html>`,
+			url:      "https://example.com/docs/intro",
+			expected: "current",
+		},
+		{
+			name:     "Read the Docs data",
+			html:     `<html><body><script>var READTHEDOCS_DATA = {"project": "requests", "version": "stable", "language": "en"}</script></body></html>`,
+			url:      "https://example.com/en/latest/",
+			expected: "stable",
+		},
+		{
+			name:     "URL segment",
+			html:     `<html><body><script>var DOCUMENTATION_OPTIONS = {VERSION: '3.11.4'};</script></body></html>`,
+			url:      "https://docs.example.com/en/3.11/tutorial/index.html",
+			expected: "3.11",
+		},
+		{
+			name:     "URL segment offered by the switcher",
+			html:     `<html><body><div class="version-switcher__menu"><a href="/3.11/">3.11</a><a href="/3.12/">3.12</a></div></body></html>`,
+			url:      "https://docs.python.org/3.11/tutorial/index.html",
+			expected: "3.11",
+		},
+		{
+			name:     "API version isn't a docs version",
+			html:     `<html><body></body></html>`,
+			url:      "https://example.com/api/v1/users/",
+			expected: "",
+		},
+		{
+			name:     "Version select",
+			html:     `<html><body><select id="version-select"><option value="/1.0/">1.0</option><option value="/" selected>2.0</option></select></body></html>`,
+			url:      "https://example.com/guide/",
+			expected: "2.0",
+		},
+		{
+			name:     "Sphinx release",
+			html:     `<html><body><script>var DOCUMENTATION_OPTIONS = {URL_ROOT: '', VERSION: '4.2.0'};</script></body></html>`,
+			url:      "https://example.com/index.html",
+			expected: "4.2.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatalf("failed to parse HTML: %v", err)
+			}
+			pageURL, _ := url.Parse(tt.url)
+			if version := pageVersion(doc.Selection, pageURL); version != tt.expected {
+				t.Errorf("pageVersion() = %q, want %q", version, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVersionSwitcher(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+		<div class="rst-versions"><div class="rst-other-versions"><dl>
+			<dt>Versions</dt>
+			<dd><a href="/en/latest/">latest</a></dd>
+			<dd><a href="/en/stable/">stable</a></dd>
+			<dd><a href="/en/2.0/">2.0</a></dd>
+			<dd><a href="/en/latest/">latest</a></dd>
+		</dl><dl><dt>Downloads</dt><dd><a href="/_/downloads/en/latest/pdf/">PDF</a></dd></dl></div></div>
+	</body></html>`))
+	if err != nil {
+		t.Fatalf("failed to parse HTML: %v", err)
+	}
+
+	resolve := func(href string) string { return "https://example.readthedocs.io" + href }
+	expected := []string{"latest", "stable", "2.0"}
+	if versions := versionSwitcher(doc.Selection, resolve); !reflect.DeepEqual(versions, expected) {
+		t.Errorf("versionSwitcher() = %v, want %v", versions, expected)
+	}
+}
+
+func TestVersionFilter(t *testing.T) {
+	filter := newVersionFilter("stable")
+	start := filter.PinURL("https://example.readthedocs.io/en/latest/install.html")
+	if start != "https://example.readthedocs.io/en/stable/install.html" {
+		t.Errorf("PinURL() = %q", start)
+	}
+	filter.Learn(start)
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://example.readthedocs.io/en/stable/api.html", true},
+		{"https://example.readthedocs.io/en/latest/api.html", false},
+		{"https://example.readthedocs.io/en/2.0/", false},
+		{"https://example.readthedocs.io/de/latest/", true},
+		{"https://example.readthedocs.io/_/downloads/", true},
+	}
+	for _, tt := range tests {
+		if allowed := filter.AllowsURL(tt.url); allowed != tt.allowed {
+			t.Errorf("AllowsURL(%q) = %v, want %v", tt.url, allowed, tt.allowed)
+		}
+	}
+
+	// Docusaurus serves the current version without a version segment
+	docusaurus := newVersionFilter("v1.0")
+	if !docusaurus.AllowsURL("https://example.com/docs/intro") {
+		t.Error("unversioned URL rejected before the version's location is known")
+	}
+	docusaurus.Learn("https://example.com/docs/1.0/intro")
+	if docusaurus.AllowsURL("https://example.com/docs/intro") || !docusaurus.AllowsURL("https://example.com/docs/1.0/api") {
+		t.Error("version tree not enforced after learning its location")
+	}
+	if !docusaurus.AllowsURL("https://example.com/blog/release") {
+		t.Error("URL outside the docs tree rejected")
+	}
+
+	// Numeric segments elsewhere on the site aren't versions
+	latest := newVersionFilter("latest")
+	latest.Learn("https://example.com/guide/")
+	for _, link := range []string{"https://example.com/api/v2/users", "https://example.com/blog/2024/release"} {
+		if !latest.AllowsURL(link) {
+			t.Errorf("AllowsURL(%q) = false, want true", link)
+		}
+	}
+	if latest.AllowsURL("https://example.com/en/2.0/") {
+		t.Error("URL of another version allowed")
+	}
+	if pinned := newVersionFilter("3.12").PinURL("https://docs.python.org/3.11/"); pinned != "https://docs.python.org/3.12/" {
+		t.Errorf("PinURL() = %q", pinned)
+	}
+	if docusaurus.Allows("current") || !docusaurus.Allows("1.0") || !docusaurus.Allows("") {
+		t.Error("Allows() doesn't match declared versions")
+	}
+}